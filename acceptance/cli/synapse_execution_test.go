@@ -2,8 +2,11 @@ package acceptance_test
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/anoop2811/cortex/internal/synapse"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -37,6 +40,24 @@ var _ = Describe("Synapse Execution", Label("acceptance", "synapse", "workflow")
 				Eventually(session).Should(gexec.Exit(0))
 				Eventually(session.Out).Should(gbytes.Say("Bootstrap a new synapse folder with config and file structure"))
 			})
+
+			It("should execute the new synapse before any neuron is added", func() {
+				runIn := func(args ...string) *gexec.Session {
+					cmd := exec.Command(cortexPath, args...)
+					cmd.Dir = tempDir
+					cmd.Env = append(os.Environ(), "HOME="+tempDir)
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					return session
+				}
+
+				Eventually(runIn("create-synapse", "first-synapse")).Should(gexec.Exit(0))
+				Expect(filepath.Join(tempDir, "first-synapse", "config.yml")).To(BeAnExistingFile())
+
+				session := runIn("exec", "-p", "first-synapse")
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(session.Out).To(gbytes.Say("Synapse completed successfully"))
+			})
 		})
 
 		Context("when executing a sequential synapse", func() {
@@ -142,8 +163,6 @@ neurons:
 	Describe("Error handling and retries", func() {
 		Context("when a neuron fails", func() {
 			It("should retry according to retry policy", func() {
-				Skip("Retry timing test - retry logic works but takes >1s with exponential backoff")
-
 				synapseConfig := `---
 name: resilient-check
 neurons:
@@ -151,7 +170,8 @@ neurons:
     retry:
       maxAttempts: 3
       backoff: exponential
-      initialDelay: 1s`
+      initialDelay: 1s
+      maxDelay: 100ms`
 
 				configFile := filepath.Join(synapseDir, "config.yml")
 				err := os.MkdirAll(synapseDir, 0755)
@@ -231,6 +251,163 @@ neurons:
 		})
 	})
 
+	Describe("Legacy synapse.yaml", func() {
+		BeforeEach(func() {
+			neuronDir := filepath.Join(synapseDir, "check-disk")
+			Expect(os.MkdirAll(neuronDir, 0755)).To(Succeed())
+			script := filepath.Join(neuronDir, "run.sh")
+			Expect(os.WriteFile(script, []byte("#!/bin/bash\necho disk ok\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(neuronDir, "neuron.yaml"), []byte("name: check-disk\ntype: check\nexec_file: "+script+"\n"), 0644)).To(Succeed())
+
+			legacyConfig := `name: legacy-health
+definition:
+  - neuron: check-disk
+    config:
+      path: check-disk
+plan:
+  config:
+    exit_on_first_error: true
+  steps:
+    serial:
+      - check-disk
+    parallel: []`
+			Expect(os.WriteFile(filepath.Join(synapseDir, "synapse.yaml"), []byte(legacyConfig), 0644)).To(Succeed())
+		})
+
+		It("is executed by both exec and execute-synapse", func() {
+			session := RunCortex("exec", "-p", synapseDir)
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Executing: check-disk"))
+			Expect(session.Out).To(gbytes.Say("disk ok"))
+			Expect(session.Out).To(gbytes.Say("Synapse completed successfully"))
+
+			session = RunCortex("execute-synapse", synapseDir)
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Executing: check-disk"))
+		})
+
+		It("prints execution events as JSON lines with --events", func() {
+			session := RunCortex("exec", "-p", synapseDir, "--events")
+			Eventually(session).Should(gexec.Exit(0))
+
+			var types []synapse.EventType
+			for _, line := range strings.Split(strings.TrimSpace(string(session.Out.Contents())), "\n") {
+				event, err := synapse.DecodeEvent([]byte(line))
+				Expect(err).NotTo(HaveOccurred(), line)
+				types = append(types, event.EventType())
+				if output, ok := event.(synapse.OutputLine); ok && output.Line == "disk ok" {
+					Expect(output.Neuron).To(Equal("check-disk"))
+				}
+			}
+			Expect(types).To(HaveExactElements(
+				synapse.EventExecutionStarted,
+				synapse.EventNeuronStarted,
+				synapse.EventOutputLine,
+				synapse.EventOutputLine,
+				synapse.EventNeuronFinished,
+				synapse.EventExecutionFinished,
+			))
+		})
+
+		It("is converted to config.yml by migrate-synapse", func() {
+			session := RunCortex("migrate-synapse", synapseDir)
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Migrated synapse 'legacy-health'"))
+
+			config, err := os.ReadFile(filepath.Join(synapseDir, "config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config)).To(HavePrefix("apiVersion: cortex/v1\n"))
+			Expect(string(config)).To(ContainSubstring("stopOnError: true"))
+			Expect(filepath.Join(synapseDir, "synapse.yaml.bak")).To(BeAnExistingFile())
+
+			session = RunCortex("validate-synapse", synapseDir)
+			Eventually(session).Should(gexec.Exit(0))
+		})
+	})
+
+	Describe("Synapse inputs", func() {
+		BeforeEach(func() {
+			neuronsDir := filepath.Join(synapseDir, "neurons")
+			Expect(os.MkdirAll(neuronsDir, 0755)).To(Succeed())
+			script := filepath.Join(neuronsDir, "scale.sh")
+			Expect(os.WriteFile(script, []byte("#!/bin/bash\necho \"scaling $CORTEX_INPUT_TARGET to $CORTEX_INPUT_REPLICAS\"\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(neuronsDir, "scale.yml"), []byte("name: scale\ntype: mutate\nexec_file: "+script+"\n"), 0644)).To(Succeed())
+
+			synapseConfig := `apiVersion: cortex/v1
+name: scale-deployment
+inputs:
+  - name: target
+    required: true
+  - name: replicas
+    type: int
+    default: 2
+neurons:
+  - name: scale`
+			Expect(os.WriteFile(filepath.Join(synapseDir, "config.yml"), []byte(synapseConfig), 0644)).To(Succeed())
+		})
+
+		It("exports inputs from --values and --set to neurons", func() {
+			valuesFile := filepath.Join(tempDir, "values.yaml")
+			Expect(os.WriteFile(valuesFile, []byte("target: web\nreplicas: 4\n"), 0644)).To(Succeed())
+
+			session := RunCortex("execute-synapse", synapseDir, "--values", valuesFile, "--set", "replicas=6", "--approve-all")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("scaling web to 6"))
+		})
+
+		It("refuses to execute with invalid inputs", func() {
+			session := RunCortex("execute-synapse", synapseDir, "--set", "replicas=lots")
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("input target is required"))
+			Expect(session.Out).NotTo(gbytes.Say("Executing: scale"))
+		})
+	})
+
+	Describe("Approving mutate neurons", func() {
+		// runWithAnswers runs execute-synapse with answers on stdin
+		runWithAnswers := func(answers string, args ...string) *gexec.Session {
+			cmd := exec.Command(cortexPath, append([]string{"execute-synapse", synapseDir}, args...)...)
+			cmd.Stdin = strings.NewReader(answers)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			return session
+		}
+
+		BeforeEach(func() {
+			neuronsDir := filepath.Join(synapseDir, "neurons")
+			Expect(os.MkdirAll(neuronsDir, 0755)).To(Succeed())
+			for name, kind := range map[string]string{"check-pods": "check", "restart-pods": "mutate"} {
+				script := filepath.Join(neuronsDir, name+".sh")
+				Expect(os.WriteFile(script, []byte("#!/bin/bash\necho ran "+name+"\n"), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(neuronsDir, name+".yml"), []byte("name: "+name+"\ntype: "+kind+"\nexec_file: "+script+"\n"), 0644)).To(Succeed())
+			}
+			synapseConfig := "apiVersion: cortex/v1\nname: restart-pods\nneurons: [check-pods, restart-pods]\n"
+			Expect(os.WriteFile(filepath.Join(synapseDir, "config.yml"), []byte(synapseConfig), 0644)).To(Succeed())
+		})
+
+		It("skips mutate neurons in read-only mode", func() {
+			session := runWithAnswers("", "--read-only")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("ran check-pods"))
+			Expect(session.Out).To(gbytes.Say(`Skipping: restart-pods \(not approved: read-only mode\)`))
+			Expect(string(session.Out.Contents())).NotTo(ContainSubstring("ran restart-pods"))
+		})
+
+		It("runs mutate neurons once approved at the prompt", func() {
+			session := runWithAnswers("y\n")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`Approval required: mutate neuron restart-pods of synapse restart-pods`))
+			Expect(session.Out).To(gbytes.Say("Neuron restart-pods approved by prompt"))
+			Expect(session.Out).To(gbytes.Say("ran restart-pods"))
+		})
+
+		It("does not run mutate neurons without an answer", func() {
+			session := runWithAnswers("")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`Skipping: restart-pods \(not approved: no answer\)`))
+		})
+	})
+
 	Describe("Synapse execution history", func() {
 		It("should maintain execution history", func() {
 			session := RunCortex("synapse-history", "health-check")
@@ -253,12 +430,13 @@ neurons:
 
 	Describe("Resource management", func() {
 		It("should respect memory limits", func() {
-			Skip("Resource limiting not yet implemented - TDD RED phase")
-
 			synapseConfig := `---
 name: memory-intensive-workflow
 resources:
-  memory: 256Mi`
+  memory: 256Mi
+  cpuTime: 30s
+neurons:
+  - allocate-buffers`
 
 			configFile := filepath.Join(synapseDir, "config.yml")
 			err := os.MkdirAll(synapseDir, 0755)
@@ -272,18 +450,24 @@ resources:
 		})
 
 		It("should timeout long-running synapses", func() {
-			Skip("Timeout test needs synapse with neurons to properly test timeout")
-
 			synapseConfig := `---
 name: slow-workflow
-timeout: 5s`
+timeout: 1s
+neurons:
+  - hang-forever`
 
 			configFile := filepath.Join(synapseDir, "config.yml")
-			err := os.MkdirAll(synapseDir, 0755)
+			err := os.MkdirAll(filepath.Join(synapseDir, "neurons"), 0755)
 			Expect(err).NotTo(HaveOccurred())
 			err = os.WriteFile(configFile, []byte(synapseConfig), 0644)
 			Expect(err).NotTo(HaveOccurred())
 
+			script := filepath.Join(synapseDir, "neurons", "hang-forever.sh")
+			err = os.WriteFile(script, []byte("#!/bin/bash\nsleep 60\n"), 0755)
+			Expect(err).NotTo(HaveOccurred())
+			err = os.WriteFile(filepath.Join(synapseDir, "neurons", "hang-forever.yml"), []byte("name: hang-forever\ntype: check\nexec_file: "+script+"\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
 			session := RunCortex("execute-synapse", synapseDir)
 
 			Eventually(session, "6s").Should(gexec.Exit())
@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/anoop2811/cortex/internal/synapse"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
@@ -48,19 +49,18 @@ func createSynapse(name string) {
 		os.Exit(1)
 	}
 
-	// Create synapse.yaml
-	synapseConfig := map[string]interface{}{
-		"name":       name,
-		"definition": []map[string]interface{}{},
-		"plan": map[string]interface{}{
-			"config": map[string]interface{}{
-				"exit_on_first_error": false,
-			},
-			"steps": map[string]interface{}{
-				"serial":   []string{},
-				"parallel": []string{},
-			},
-		},
+	// Create neurons directory
+	if err := os.MkdirAll(filepath.Join(name, "neurons"), 0755); err != nil {
+		fmt.Printf("Error creating neurons directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create config.yml
+	synapseConfig := yaml.MapSlice{
+		{Key: "apiVersion", Value: synapse.APIVersion},
+		{Key: "name", Value: name},
+		{Key: "neurons", Value: []string{}},
+		{Key: "execution", Value: string(synapse.ExecutionSequential)},
 	}
 
 	yamlData, err := yaml.Marshal(synapseConfig)
@@ -69,13 +69,14 @@ func createSynapse(name string) {
 		os.Exit(1)
 	}
 
-	configPath := filepath.Join(name, "synapse.yaml")
+	configPath := filepath.Join(name, synapse.ConfigFile)
 	if err := os.WriteFile(configPath, yamlData, 0644); err != nil {
-		fmt.Printf("Error writing synapse.yaml: %v\n", err)
+		fmt.Printf("Error writing %s: %v\n", synapse.ConfigFile, err)
 		os.Exit(1)
 	}
 
 	fmt.Println("Bootstrap a new synapse folder with config and file structure")
 	fmt.Printf("✓ Created synapse '%s'\n", name)
-	fmt.Printf("  - %s/synapse.yaml\n", name)
+	fmt.Printf("  - %s\n", configPath)
+	fmt.Printf("  - %s/\n", filepath.Join(name, "neurons"))
 }
@@ -16,56 +16,34 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"path/filepath"
+	"time"
 
+	"github.com/anoop2811/cortex/internal/synapse"
 	log "github.com/anoop2811/cortex/logger"
-	"github.com/anoop2811/cortex/internal/neuron"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
-type SynapseConfig struct {
-	Name       string             `yaml:"name"`
-	Definition []NeuronDefinition `yaml:"definition"`
-	Plan       PlanConfig         `yaml:"plan"`
-}
-
-type NeuronDefinition struct {
-	Neuron string       `yaml:"neuron"`
-	Config NeuronConfig `yaml:"config"`
-}
-
-type NeuronConfig struct {
-	Path string         `yaml:"path"`
-	Fix  map[int]string `yaml:"fix"`
-}
-
-type PlanConfig struct {
-	Config PlanSettings `yaml:"config"`
-	Steps  PlanSteps    `yaml:"steps"`
-}
-
-type PlanSettings struct {
-	ExitOnFirstError bool `yaml:"exit_on_first_error"`
-}
-
-type PlanSteps struct {
-	Serial   []string `yaml:"serial"`
-	Parallel []string `yaml:"parallel"`
-}
-
-var synapsePath string
+var (
+	synapsePath    string
+	execReadOnly   bool
+	execApproveAll bool
+	execEvents     bool
+	execWait       time.Duration
+	execFail       bool
+)
 
 // execCmd represents the exec command
 var execCmd = &cobra.Command{
-	Use:     "exec [path]",
-	Short:   "Execute a synapse",
-	Long:    `Execute a synapse by running all neurons in the defined plan`,
-	Args:    cobra.MaximumNArgs(1),
+	Use:   "exec [path]",
+	Short: "Execute a synapse",
+	Long: `Execute a synapse by running all neurons in the defined plan.
+Both config.yml and legacy synapse.yaml layouts are supported.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := synapsePath
 		if len(args) > 0 {
@@ -78,122 +56,75 @@ var execCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(execCmd)
 	execCmd.Flags().StringVarP(&synapsePath, "path", "p", ".", "Path to synapse directory")
+	execCmd.Flags().BoolVar(&execReadOnly, "read-only", false, "Skip every mutate neuron")
+	execCmd.Flags().BoolVar(&execApproveAll, "approve-all", false, "Run mutate neurons without asking for approval")
+	execCmd.Flags().DurationVar(&execWait, "wait", 0, "How long to wait for a running execution of the synapse to finish (0 = until it finishes)")
+	execCmd.Flags().BoolVar(&execFail, "fail-if-locked", false, "Fail at once if the synapse is already running")
+	execCmd.Flags().BoolVar(&execEvents, "events", false, "Print execution events as JSON lines instead of text")
 }
 
 func fireSynapse(path string) {
 	logger := log.NewLogger(verbose)
 
-	// Read synapse.yaml
-	synapseFile := filepath.Join(path, "synapse.yaml")
-	data, err := ioutil.ReadFile(synapseFile)
+	syn, err := synapse.LoadFromDirectory(path)
 	if err != nil {
-		fmt.Printf("Error reading synapse.yaml: %v\n", err)
+		fmt.Printf("Error loading synapse: %v\n", err)
 		os.Exit(1)
 	}
 
-	var config SynapseConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		fmt.Printf("Error parsing synapse.yaml: %v\n", err)
-		os.Exit(1)
+	historyManager, err := synapse.NewDefaultHistoryManager()
+	if err != nil {
+		logger.Errorf(err, "Failed to initialize history manager")
+		historyManager = nil
 	}
 
-	color.New(color.FgCyan, color.Bold).Printf("\n🧠 Firing synapse: %s\n\n", config.Name)
-
-	// Build neuron map for easy lookup
-	neuronMap := make(map[string]NeuronDefinition)
-	for _, def := range config.Definition {
-		neuronMap[def.Neuron] = def
+	if execEvents {
+		fireSynapseEvents(logger, historyManager, syn, path)
+		return
 	}
 
-	exitOnFirstError := config.Plan.Config.ExitOnFirstError
-	hasErrors := false
-
-	// Execute serial neurons
-	if len(config.Plan.Steps.Serial) > 0 {
-		color.New(color.FgYellow).Println("▶ Executing serial neurons...")
-		for _, neuronName := range config.Plan.Steps.Serial {
-			if err := executeNeuron(logger, neuronName, neuronMap); err != nil {
-				hasErrors = true
-				if exitOnFirstError {
-					color.New(color.FgRed).Printf("✗ Exiting due to error in neuron '%s'\n", neuronName)
-					os.Exit(1)
-				}
-			}
-		}
-	}
+	color.New(color.FgCyan, color.Bold).Printf("\n🧠 Firing synapse: %s\n\n", syn.Name)
 
-	// Execute parallel neurons
-	if len(config.Plan.Steps.Parallel) > 0 {
-		color.New(color.FgYellow).Println("\n▶ Executing parallel neurons...")
-		for _, neuronName := range config.Plan.Steps.Parallel {
-			if err := executeNeuron(logger, neuronName, neuronMap); err != nil {
-				hasErrors = true
-				if exitOnFirstError {
-					color.New(color.FgRed).Printf("✗ Exiting due to error in neuron '%s'\n", neuronName)
-					os.Exit(1)
-				}
-			}
-		}
+	executor := synapse.NewExecutor(logger, historyManager, os.Stdout)
+	if err := configureApprovals(executor, execReadOnly, execApproveAll); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := configureLocking(executor, execWait, execFail); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	record, err := executor.Run(context.Background(), syn, path)
+	if err != nil {
+		color.New(color.FgRed).Printf("✗ %v\n", err)
 	}
 
-	if hasErrors {
+	if err != nil || record.Status != "success" {
 		color.New(color.FgRed, color.Bold).Println("\n⚠ Synapse completed with errors")
 		os.Exit(1)
-	} else {
-		color.New(color.FgGreen, color.Bold).Println("\n✓ Synapse completed successfully")
 	}
+	color.New(color.FgGreen, color.Bold).Println("\n✓ Synapse completed successfully")
 }
 
-func executeNeuron(logger *log.StandardLogger, neuronName string, neuronMap map[string]NeuronDefinition) error {
-	def, exists := neuronMap[neuronName]
-	if !exists {
-		color.New(color.FgRed).Printf("✗ Neuron '%s' not found in definition\n", neuronName)
-		return fmt.Errorf("neuron not found: %s", neuronName)
+// fireSynapseEvents executes a synapse printing only its events, one JSON
+// object per line, and approval prompts; errors go to stderr
+func fireSynapseEvents(logger *log.StandardLogger, historyManager *synapse.HistoryManager, syn *synapse.Synapse, path string) {
+	executor := synapse.NewExecutor(logger, historyManager, io.Discard)
+	executor.Subscribe(synapse.NewEventEncoder(os.Stdout))
+	if err := configureApprovals(executor, execReadOnly, execApproveAll); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
-	// Check if neuron path exists
-	neuronConfigPath := filepath.Join(def.Config.Path, "neuron.yaml")
-	if _, err := os.Stat(neuronConfigPath); os.IsNotExist(err) {
-		color.New(color.FgRed).Printf("✗ Neuron config not found: %s\n", neuronConfigPath)
-		return err
+	if err := configureLocking(executor, execWait, execFail); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Load and execute neuron
-	n, err := neuron.NewNeuron(logger, neuronConfigPath)
+	record, err := executor.Run(context.Background(), syn, path)
 	if err != nil {
-		color.New(color.FgRed).Printf("✗ Failed to load neuron '%s': %v\n", neuronName, err)
-		return err
-	}
-
-	color.New(color.FgCyan).Printf("  • %s: ", neuronName)
-	exitCode, err := n.Excite(false, os.Stdout)
-
-	// Check if exit code is in the assert_exit_status list
-	isExpectedExitCode := false
-	for _, expectedCode := range n.AssertExitStatus {
-		if expectedCode == fmt.Sprintf("%d", exitCode) {
-			isExpectedExitCode = true
-			break
-		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
-
-	if err != nil || (!isExpectedExitCode && exitCode != 0) {
-		color.New(color.FgRed).Printf(" ✗ (exit code: %d)\n", exitCode)
-
-		// Check if there's a fix defined for this exit code
-		if fixNeuron, hasFix := def.Config.Fix[exitCode]; hasFix {
-			color.New(color.FgYellow).Printf("    ↳ Attempting fix with neuron: %s\n", fixNeuron)
-			if err := executeNeuron(logger, fixNeuron, neuronMap); err != nil {
-				return err
-			}
-		}
-		return fmt.Errorf("neuron failed with exit code %d", exitCode)
-	}
-
-	if exitCode != 0 {
-		color.New(color.FgYellow).Printf(" ⚠ (exit code: %d)\n", exitCode)
-	} else {
-		color.New(color.FgGreen).Println(" ✓")
+	if err != nil || record.Status != "success" {
+		os.Exit(1)
 	}
-	return nil
 }
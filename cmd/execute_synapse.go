@@ -2,24 +2,42 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/anoop2811/cortex/internal/synapse"
 	log "github.com/anoop2811/cortex/logger"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 var (
-	executeSynapseParallel bool
-	executeSynapseEnv      []string
+	executeSynapseParallel  bool
+	executeSynapseEnv       []string
+	executeSynapseSet       []string
+	executeSynapseValues    string
+	executeSynapseReadOnly  bool
+	executeSynapseApprove   bool
+	executeSynapseMaxOutput int
+	executeSynapseResume    string
+	executeSynapseDryRun    bool
+	executeSynapseOutput    string
+	executeSynapseWait      time.Duration
+	executeSynapseFail      bool
+	executeSynapseWatch     bool
+	executeSynapseDebounce  time.Duration
 )
 
 var executeSynapseCmd = &cobra.Command{
 	Use:   "execute-synapse <directory>",
 	Short: "Execute a synapse workflow",
-	Long:  "Execute a synapse workflow from a directory containing config.yml",
+	Long:  "Execute a synapse workflow from a directory containing config.yml (or a legacy synapse.yaml)",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		synapseDir := args[0]
@@ -39,19 +57,27 @@ var executeSynapseCmd = &cobra.Command{
 			historyManager = nil
 		}
 
-		// Create executor
-		executor := synapse.NewExecutor(logger, historyManager, os.Stdout)
-
-		// Parse environment variables
-		if len(executeSynapseEnv) > 0 {
-			env := make(map[string]string)
-			for _, envVar := range executeSynapseEnv {
-				parts := strings.SplitN(envVar, "=", 2)
-				if len(parts) == 2 {
-					env[parts[0]] = parts[1]
-				}
+		// Collect inputs; they are validated against the synapse before execution
+		inputs, err := synapseInputs(executeSynapseValues, executeSynapseEnv, executeSynapseSet)
+		if err != nil {
+			logger.Fatalf(err, "Invalid inputs: %v", err)
+		}
+		if _, err := syn.ResolveInputs(inputs); err != nil {
+			logger.Fatalf(err, "Cannot execute %s: %v", syn.Name, err)
+		}
+
+		if executeSynapseWatch {
+			if executeSynapseDryRun || executeSynapseResume != "" {
+				logger.Fatalf(nil, "--watch cannot be combined with --dry-run or --resume")
 			}
-			executor.SetEnvironment(env)
+			watchSynapse(logger, historyManager, synapseDir, inputs)
+			return
+		}
+
+		// Create executor
+		executor, err := newSynapseExecutor(logger, historyManager, inputs)
+		if err != nil {
+			logger.Fatalf(err, "%v", err)
 		}
 
 		// Override execution mode if --parallel flag is set
@@ -59,9 +85,25 @@ var executeSynapseCmd = &cobra.Command{
 			syn.Execution = synapse.ExecutionParallel
 		}
 
-		// Execute synapse
+		if executeSynapseDryRun {
+			plan, err := executor.Plan(syn, synapseDir)
+			if err != nil {
+				logger.Fatalf(err, "Failed to plan synapse: %v", err)
+			}
+			if err := printPlan(plan, executeSynapseOutput); err != nil {
+				logger.Fatalf(err, "Failed to print plan: %v", err)
+			}
+			return
+		}
+
+		// Execute synapse, or resume a previous execution of it
 		ctx := context.Background()
-		if err := executor.Execute(ctx, syn, synapseDir); err != nil {
+		if executeSynapseResume != "" {
+			err = executor.Resume(ctx, syn, synapseDir, executeSynapseResume)
+		} else {
+			err = executor.Execute(ctx, syn, synapseDir)
+		}
+		if err != nil {
 			logger.Fatalf(err, "Synapse execution failed: %v", err)
 		}
 
@@ -72,5 +114,269 @@ var executeSynapseCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(executeSynapseCmd)
 	executeSynapseCmd.Flags().BoolVarP(&executeSynapseParallel, "parallel", "p", false, "Execute neurons in parallel")
-	executeSynapseCmd.Flags().StringArrayVarP(&executeSynapseEnv, "env", "e", []string{}, "Set environment variables (key=value)")
+	executeSynapseCmd.Flags().StringArrayVarP(&executeSynapseEnv, "env", "e", []string{}, "Set inputs (key=value), like --set")
+	executeSynapseCmd.Flags().StringArrayVar(&executeSynapseSet, "set", []string{}, "Set an input (key=value); overrides --values")
+	executeSynapseCmd.Flags().StringVar(&executeSynapseValues, "values", "", "YAML file of input values")
+	executeSynapseCmd.Flags().BoolVar(&executeSynapseReadOnly, "read-only", false, "Skip every mutate neuron")
+	executeSynapseCmd.Flags().BoolVar(&executeSynapseApprove, "approve-all", false, "Run mutate neurons without asking for approval")
+	executeSynapseCmd.Flags().StringVar(&executeSynapseResume, "resume", "", "Resume a previous execution, re-running only neurons that did not succeed")
+	executeSynapseCmd.Flags().BoolVar(&executeSynapseDryRun, "dry-run", false, "Print the execution plan without running any neuron")
+	executeSynapseCmd.Flags().StringVarP(&executeSynapseOutput, "output", "o", "text", "Plan output format for --dry-run (text or json)")
+	executeSynapseCmd.Flags().DurationVar(&executeSynapseWait, "wait", 0, "How long to wait for a running execution of the synapse to finish (0 = until it finishes)")
+	executeSynapseCmd.Flags().BoolVar(&executeSynapseFail, "fail-if-locked", false, "Fail at once if the synapse is already running")
+	executeSynapseCmd.Flags().BoolVar(&executeSynapseWatch, "watch", false, "Run again whenever the synapse or its neurons change")
+	executeSynapseCmd.Flags().DurationVar(&executeSynapseDebounce, "debounce", synapse.DefaultWatchDebounce, "How long changes must settle before --watch runs again")
+	executeSynapseCmd.Flags().IntVar(&executeSynapseMaxOutput, "max-output-bytes", 0, "Cap on captured stdout/stderr per neuron stream (0 = 64KiB, -1 = unlimited)")
+}
+
+// newSynapseExecutor creates an executor configured by the execute-synapse
+// flags, recording executions in historyManager unless it is nil
+func newSynapseExecutor(logger *log.StandardLogger, historyManager *synapse.HistoryManager, inputs map[string]string) (*synapse.Executor, error) {
+	executor := synapse.NewExecutor(logger, historyManager, os.Stdout)
+	executor.SetMaxOutputBytes(executeSynapseMaxOutput)
+	executor.SetEnvironment(inputs)
+	if err := configureApprovals(executor, executeSynapseReadOnly, executeSynapseApprove); err != nil {
+		return nil, err
+	}
+	if err := configureLocking(executor, executeSynapseWait, executeSynapseFail); err != nil {
+		return nil, err
+	}
+	return executor, nil
+}
+
+// watchSynapse runs the synapse in synapseDir again whenever its files
+// change, until interrupted, recording each run in historyManager unless it
+// is nil
+func watchSynapse(logger *log.StandardLogger, historyManager *synapse.HistoryManager, synapseDir string, inputs map[string]string) {
+	// One executor serves every run, so that a single approval prompt reads
+	// stdin for the whole session
+	executor, err := newSynapseExecutor(logger, historyManager, inputs)
+	if err != nil {
+		logger.Fatalf(err, "%v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = synapse.Watch(ctx, synapseDir, synapse.WatchOptions{
+		Debounce: executeSynapseDebounce,
+		Out:      os.Stdout,
+		Run: func(ctx context.Context, syn *synapse.Synapse) (*synapse.ExecutionRecord, error) {
+			if executeSynapseParallel {
+				syn.Execution = synapse.ExecutionParallel
+			}
+			return executor.Run(ctx, syn, synapseDir)
+		},
+	})
+	if err != nil {
+		logger.Fatalf(err, "Failed to watch synapse: %v", err)
+	}
+}
+
+// configureApprovals makes the executor skip mutate neurons in read-only
+// mode, run them unasked with approveAll, and otherwise prompt before each
+func configureApprovals(executor *synapse.Executor, readOnly, approveAll bool) error {
+	switch {
+	case readOnly && approveAll:
+		return fmt.Errorf("--read-only and --approve-all cannot be combined")
+	case readOnly:
+		executor.SetReadOnly(true)
+	case approveAll:
+		executor.SetApprover(synapse.ApproveAll)
+	default:
+		executor.SetApprover(synapse.NewPromptApprover(os.Stdin, os.Stdout))
+	}
+	return nil
+}
+
+// configureLocking makes the executor hold the lock of the synapse in
+// ~/.cortex/locks, waiting up to wait for it or failing at once with
+// failIfLocked
+func configureLocking(executor *synapse.Executor, wait time.Duration, failIfLocked bool) error {
+	if wait != 0 && failIfLocked {
+		return fmt.Errorf("--wait and --fail-if-locked cannot be combined")
+	}
+	if wait < 0 {
+		return fmt.Errorf("--wait must not be negative")
+	}
+	locks, err := synapse.NewDefaultLockManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize synapse locks: %w", err)
+	}
+	executor.SetLocks(locks, synapse.LockPolicy{FailIfLocked: failIfLocked, Wait: wait})
+	return nil
+}
+
+// synapseInputs merges inputs from a YAML values file with key=value pairs,
+// later pairs overriding earlier ones and the file
+func synapseInputs(valuesFile string, pairs ...[]string) (map[string]string, error) {
+	inputs := make(map[string]string)
+	if valuesFile != "" {
+		data, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %w", err)
+		}
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", valuesFile, err)
+		}
+		for k, v := range values {
+			switch v.(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("value of %s in %s must be a scalar", k, valuesFile)
+			case nil:
+				inputs[k] = ""
+			default:
+				inputs[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	for _, list := range pairs {
+		for _, pair := range list {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("expected key=value, got %q", pair)
+			}
+			inputs[parts[0]] = parts[1]
+		}
+	}
+	return inputs, nil
+}
+
+// printPlan prints an execution plan as text or JSON
+func printPlan(plan *synapse.ExecutionPlan, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "text", "":
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	fmt.Printf("Execution Plan (dry run)\n\n")
+	fmt.Printf("Synapse: %s\n", plan.Synapse)
+	if plan.Execution == synapse.ExecutionParallel {
+		fmt.Printf("Execution: %s (max concurrency: %d)\n", plan.Execution, plan.MaxConcurrency)
+	} else {
+		fmt.Printf("Execution: %s\n", plan.Execution)
+	}
+	fmt.Printf("Stop on error: %v\n", plan.StopOnError)
+	if plan.Timeout != "" {
+		fmt.Printf("Timeout: %s\n", plan.Timeout)
+	}
+	if len(plan.Environment) > 0 {
+		names := make([]string, 0, len(plan.Environment))
+		for name := range plan.Environment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("\nInputs:\n")
+		for _, name := range names {
+			fmt.Printf("  %s=%s\n", name, plan.Environment[name])
+		}
+	}
+
+	fmt.Printf("\nLevels:\n")
+	for i, level := range plan.Levels {
+		fmt.Printf("  %d: %s\n", i+1, strings.Join(level, ", "))
+	}
+
+	fmt.Printf("\nNeurons:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "#\tNeuron\tType\tLevel\tDecision\tAttempts\tRetry Budget")
+	fmt.Fprintln(w, "-\t------\t----\t-----\t--------\t--------\t------------")
+	for i, n := range plan.Neurons {
+		decision := n.Decision
+		if n.Reason != "" {
+			decision = fmt.Sprintf("%s (%s)", decision, n.Reason)
+		}
+		budget := "-"
+		if n.MaxAttempts > 1 {
+			budget = fmt.Sprintf("%v %s", n.RetryBudget, n.Backoff)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			i+1, n.Name, n.Type, n.Level, decision, n.MaxAttempts, budget)
+	}
+	w.Flush()
+
+	fmt.Printf("\nDetails:\n")
+	for _, n := range plan.Neurons {
+		fmt.Printf("\n=== %s ===\n", n.Name)
+		if n.Path != "" {
+			fmt.Printf("Path: %s\n", n.Path)
+		}
+		if n.ExecFile != "" {
+			fmt.Printf("Exec file: %s\n", n.ExecFile)
+		}
+		if n.Target != "" {
+			fmt.Printf("Target: %s\n", n.Target)
+		}
+		if n.Image != "" {
+			fmt.Printf("Image: %s\n", n.Image)
+		}
+		if len(n.DependsOn) > 0 {
+			fmt.Printf("Depends on: %s (trigger rule: %s)\n", strings.Join(n.DependsOn, ", "), n.TriggerRule)
+		}
+		if n.Condition != "" {
+			fmt.Printf("Condition: %s\n", n.Condition)
+		}
+		if n.Timeout != "" {
+			fmt.Printf("Timeout: %s\n", n.Timeout)
+		}
+		if len(n.RetryDelays) > 0 {
+			if n.Jitter > 0 {
+				fmt.Printf("Retry delays: %v (±%.0f%% jitter)\n", n.RetryDelays, n.Jitter*100)
+			} else {
+				fmt.Printf("Retry delays: %v\n", n.RetryDelays)
+			}
+		}
+		if len(n.RetryOn) > 0 {
+			fmt.Printf("Retry on exit codes: %v\n", n.RetryOn)
+		}
+		if len(n.AbortOn) > 0 {
+			fmt.Printf("Abort on exit codes: %v\n", n.AbortOn)
+		}
+		if len(n.OnFailure) > 0 {
+			fmt.Printf("On failure: %s\n", strings.Join(n.OnFailure, ", "))
+		}
+		if n.Undo != "" {
+			fmt.Printf("Undo: %s\n", n.Undo)
+		}
+		for _, code := range sortedCodes(n.Fix) {
+			fmt.Printf("Fix on exit code %d: %s\n", code, n.Fix[code])
+		}
+		if n.MaxRemediations > 0 {
+			fmt.Printf("Max remediations: %d\n", n.MaxRemediations)
+		}
+		if n.PollUntil != "" {
+			fmt.Printf("Poll: every %v for up to %v until %s\n", n.PollInterval, n.PollTimeout, n.PollUntil)
+		}
+		if n.Matrix != nil {
+			pass := n.Matrix.Pass
+			if pass == "" {
+				pass = synapse.MatrixAll
+			}
+			if n.Matrix.From != "" {
+				fmt.Printf("Matrix: %d combinations for each value of %s (pass: %s)\n", n.MatrixItems, n.Matrix.From, pass)
+			} else {
+				fmt.Printf("Matrix: %d items (pass: %s)\n", n.MatrixItems, pass)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedCodes returns the exit codes of a fix map in ascending order
+func sortedCodes(fix map[int]string) []int {
+	codes := make([]int, 0, len(fix))
+	for code := range fix {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	migrateSynapseStdout bool
+	migrateSynapseForce  bool
+)
+
+var migrateSynapseCmd = &cobra.Command{
+	Use:   "migrate-synapse <directory>",
+	Short: "Convert a legacy synapse.yaml to config.yml",
+	Long: `Convert a synapse in the legacy plan format (synapse.yaml with definition
+and plan.steps) to a config.yml in the current schema. The original file is
+kept as synapse.yaml.bak.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := migrateSynapse(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateSynapseCmd)
+	migrateSynapseCmd.Flags().BoolVar(&migrateSynapseStdout, "stdout", false, "Print the migrated config instead of writing it")
+	migrateSynapseCmd.Flags().BoolVar(&migrateSynapseForce, "force", false, "Overwrite an existing config.yml")
+}
+
+func migrateSynapse(dir string) error {
+	legacyPath := filepath.Join(dir, synapse.LegacyConfigFile)
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read legacy synapse config: %w", err)
+	}
+
+	syn, err := synapse.MigrateLegacy(data)
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", legacyPath, err)
+	}
+	if err := syn.Validate(); err != nil {
+		return fmt.Errorf("migrated synapse is invalid: %w", err)
+	}
+
+	migrated, err := yaml.Marshal(syn)
+	if err != nil {
+		return fmt.Errorf("failed to marshal migrated synapse: %w", err)
+	}
+
+	if migrateSynapseStdout {
+		fmt.Print(string(migrated))
+		return nil
+	}
+
+	configPath := filepath.Join(dir, synapse.ConfigFile)
+	if _, err := os.Stat(configPath); err == nil && !migrateSynapseForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", configPath)
+	}
+	if err := os.WriteFile(configPath, migrated, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configPath, err)
+	}
+	if err := os.Rename(legacyPath, legacyPath+".bak"); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", legacyPath, err)
+	}
+
+	fmt.Printf("✓ Migrated synapse '%s' to %s\n", syn.Name, configPath)
+	fmt.Printf("  - legacy config kept as %s.bak\n", legacyPath)
+	return nil
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleCron       string
+	scheduleName       string
+	scheduleJitter     time.Duration
+	scheduleCatchUp    string
+	scheduleSet        []string
+	scheduleValues     string
+	scheduleApproveAll bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage periodic synapse executions",
+	Long: `Manage schedules that run synapses periodically. Schedules are kept in
+~/.cortex/schedules.json and run by 'cortex scheduler' or 'cortex ui --scheduler'.`,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add <directory>",
+	Short: "Schedule a synapse with a cron expression",
+	Long: `Schedule the synapse in a directory with a cron expression.
+
+Missed runs, for instance while no scheduler was running, are handled by the
+catch-up policy: skip drops them, once runs once for all of them and all runs
+every one of them. Mutate neurons are skipped unless --approve-all is given.
+
+Example:
+  cortex schedule add ./disk-check --cron "*/5 * * * *" --jitter 30s
+  cortex schedule add ./nightly-report --cron @daily --catch-up once --set env=prod`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		synapseDir, err := filepath.Abs(args[0])
+		if err != nil {
+			logger.Fatalf(err, "Invalid synapse directory: %v", err)
+		}
+		syn, err := synapse.LoadFromDirectory(synapseDir)
+		if err != nil {
+			logger.Fatalf(err, "Failed to load synapse: %v", err)
+		}
+
+		inputs, err := synapseInputs(scheduleValues, scheduleSet)
+		if err != nil {
+			logger.Fatalf(err, "Invalid inputs: %v", err)
+		}
+		if _, err := syn.ResolveInputs(inputs); err != nil {
+			logger.Fatalf(err, "Cannot schedule %s: %v", syn.Name, err)
+		}
+
+		name := scheduleName
+		if name == "" {
+			name = syn.Name
+		}
+		added, err := scheduleStore(logger).Add(schedule.Schedule{
+			Name:       name,
+			Path:       synapseDir,
+			Synapse:    syn.Name,
+			Cron:       scheduleCron,
+			Jitter:     scheduleJitter,
+			CatchUp:    schedule.CatchUp(scheduleCatchUp),
+			Inputs:     inputs,
+			ApproveAll: scheduleApproveAll,
+		}, time.Now())
+		if err != nil {
+			logger.Fatalf(err, "Failed to add schedule: %v", err)
+		}
+		fmt.Printf("✓ Scheduled '%s', next run at %s\n", added.Name, added.NextStart().Format(time.RFC3339))
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List schedules with their last and next runs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		schedules, err := scheduleStore(logger).List()
+		if err != nil {
+			logger.Fatalf(err, "Failed to list schedules: %v", err)
+		}
+		if len(schedules) == 0 {
+			fmt.Println("No schedules found")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Name\tSynapse\tCron\tNext Run\tLast Run\tLast Status")
+		fmt.Fprintln(w, "----\t-------\t----\t--------\t--------\t-----------")
+		for _, s := range schedules {
+			next := s.NextStart().Format(time.RFC3339)
+			if s.Paused {
+				next = "paused"
+			}
+			last, status := "-", "-"
+			if !s.LastRun.IsZero() {
+				last, status = s.LastRun.Format(time.RFC3339), s.LastStatus
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Synapse, s.Cron, next, last, status)
+		}
+		w.Flush()
+	},
+}
+
+var schedulePauseCmd = &cobra.Command{
+	Use:   "pause <name>",
+	Short: "Stop a schedule from running until it is resumed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		if _, err := scheduleStore(logger).Pause(args[0]); err != nil {
+			logger.Fatalf(err, "Failed to pause schedule: %v", err)
+		}
+		fmt.Printf("✓ Paused schedule '%s'\n", args[0])
+	},
+}
+
+var scheduleResumeCmd = &cobra.Command{
+	Use:   "resume <name>",
+	Short: "Resume a paused schedule, dropping the runs it missed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		resumed, err := scheduleStore(logger).Resume(args[0], time.Now())
+		if err != nil {
+			logger.Fatalf(err, "Failed to resume schedule: %v", err)
+		}
+		fmt.Printf("✓ Resumed schedule '%s', next run at %s\n", resumed.Name, resumed.NextStart().Format(time.RFC3339))
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a schedule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		if err := scheduleStore(logger).Remove(args[0]); err != nil {
+			logger.Fatalf(err, "Failed to remove schedule: %v", err)
+		}
+		fmt.Printf("✓ Removed schedule '%s'\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleAddCmd, scheduleListCmd, schedulePauseCmd, scheduleResumeCmd, scheduleRemoveCmd)
+	scheduleAddCmd.Flags().StringVar(&scheduleCron, "cron", "", "Cron expression the synapse runs on, e.g. \"*/5 * * * *\" or @daily (required)")
+	scheduleAddCmd.Flags().StringVar(&scheduleName, "name", "", "Name of the schedule (default is the synapse name)")
+	scheduleAddCmd.Flags().DurationVar(&scheduleJitter, "jitter", 0, "Start each run up to this much after its cron time")
+	scheduleAddCmd.Flags().StringVar(&scheduleCatchUp, "catch-up", string(schedule.CatchUpSkip), "What to do with missed runs: skip, once or all")
+	scheduleAddCmd.Flags().StringArrayVar(&scheduleSet, "set", []string{}, "Set an input (key=value); overrides --values")
+	scheduleAddCmd.Flags().StringVar(&scheduleValues, "values", "", "YAML file of input values")
+	scheduleAddCmd.Flags().BoolVar(&scheduleApproveAll, "approve-all", false, "Run mutate neurons; they are skipped otherwise")
+	scheduleAddCmd.MarkFlagRequired("cron")
+}
+
+// scheduleStore returns the default schedule store
+func scheduleStore(logger *log.StandardLogger) *schedule.Store {
+	store, err := schedule.DefaultStore()
+	if err != nil {
+		logger.Fatalf(err, "Failed to open schedules: %v", err)
+	}
+	return store
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	"github.com/spf13/cobra"
+)
+
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Run scheduled synapses until stopped",
+	Long: `Run the synapses of the schedules in ~/.cortex/schedules.json when they are
+due. Executions are recorded in the synapse history and hold the synapse
+lock: a run is recorded as locked instead of waiting when the synapse is
+already running. Schedules added, paused or removed while the scheduler runs
+are picked up within seconds.
+
+Example:
+  cortex scheduler
+  cortex ui --scheduler   # run the scheduler inside the web UI server`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		logger.Infof("Cortex scheduler started")
+		newScheduler(logger, os.Stdout).Run(ctx)
+		logger.Infof("Cortex scheduler stopped")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schedulerCmd)
+}
+
+// newScheduler creates a scheduler for the default schedules, history and
+// synapse locks, printing execution progress to out
+func newScheduler(logger *log.StandardLogger, out io.Writer) *schedule.Scheduler {
+	historyManager, err := synapse.NewDefaultHistoryManager()
+	if err != nil {
+		logger.Errorf(err, "Failed to initialize history manager")
+		historyManager = nil
+	}
+	locks, err := synapse.NewDefaultLockManager()
+	if err != nil {
+		logger.Fatalf(err, "Failed to initialize synapse locks: %v", err)
+	}
+	return schedule.NewScheduler(scheduleStore(logger), historyManager, locks, logger, out)
+}
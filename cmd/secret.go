@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anoop2811/cortex/internal/secret"
+	log "github.com/anoop2811/cortex/logger"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets in the encrypted local store",
+	Long: `Manage secrets in the encrypted local store (~/.cortex/secrets.enc).
+Neurons and synapses reference them from env entries with fromStore. The store
+is encrypted with ~/.cortex/secrets.key, or with a key derived from
+$CORTEX_SECRET_KEY when it is set.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Store a secret, read from stdin when no value is given",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				logger.Fatalf(err, "Failed to read secret from stdin: %v", err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+
+		if err := secretStore(logger).Set(args[0], value); err != nil {
+			logger.Fatalf(err, "Failed to store secret: %v", err)
+		}
+		fmt.Printf("✓ Stored secret '%s'\n", args[0])
+	},
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print the value of a secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		value, err := secretStore(logger).Get(args[0])
+		if err != nil {
+			logger.Fatalf(err, "Failed to read secret: %v", err)
+		}
+		fmt.Println(value)
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of stored secrets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		names, err := secretStore(logger).List()
+		if err != nil {
+			logger.Fatalf(err, "Failed to list secrets: %v", err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewLogger(verbose)
+
+		if err := secretStore(logger).Delete(args[0]); err != nil {
+			logger.Fatalf(err, "Failed to delete secret: %v", err)
+		}
+		fmt.Printf("✓ Deleted secret '%s'\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd, secretGetCmd, secretListCmd, secretDeleteCmd)
+}
+
+// secretStore returns the default secret store
+func secretStore(logger *log.StandardLogger) *secret.Store {
+	store, err := secret.DefaultStore()
+	if err != nil {
+		logger.Fatalf(err, "Failed to open secret store: %v", err)
+	}
+	return store
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"text/tabwriter"
 
@@ -45,6 +46,18 @@ var synapseLogsCmd = &cobra.Command{
 		fmt.Printf("Timestamp: %s\n", record.Timestamp.Format("2006-01-02 15:04:05"))
 		fmt.Printf("Status: %s\n", record.Status)
 		fmt.Printf("Duration: %v\n", record.Duration)
+		if record.ResumedFrom != "" {
+			fmt.Printf("Resumed from: %s\n", record.ResumedFrom)
+		}
+		if record.ReadOnly {
+			fmt.Printf("Read-only: mutate neurons were skipped\n")
+		}
+		if record.Schedule != "" {
+			fmt.Printf("Schedule: %s\n", record.Schedule)
+		}
+		if record.Lock != nil {
+			fmt.Printf("Lock: held by %s (waited %v)\n", record.Lock.Holder, record.Lock.Waited)
+		}
 
 		if record.ErrorMessage != "" {
 			fmt.Printf("Error: %s\n", record.ErrorMessage)
@@ -55,17 +68,42 @@ var synapseLogsCmd = &cobra.Command{
 		fmt.Fprintln(w, "Neuron\tStatus\tExit Code\tDuration")
 		fmt.Fprintln(w, "------\t------\t---------\t--------")
 
-		for _, result := range record.NeuronResults {
-			fmt.Fprintf(w, "%s\t%s\t%d\t%v\n",
-				result.Name, result.Status, result.ExitCode, result.Duration)
-		}
+		printNeuronResults(w, "", record.NeuronResults)
 
 		w.Flush()
 
+		if len(record.Rollbacks) > 0 {
+			fmt.Printf("\nRollbacks (%s):\n", record.RollbackStatus)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "Neuron\tFor\tStatus\tExit Code\tDuration")
+			fmt.Fprintln(w, "------\t---\t------\t---------\t--------")
+			for _, rollback := range record.Rollbacks {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%v\n",
+					rollback.Name, rollback.For, rollback.Status, rollback.ExitCode, rollback.Duration)
+			}
+			w.Flush()
+		}
+
+		if len(record.Approvals) > 0 {
+			fmt.Printf("\nApprovals:\n")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "Neuron\tDecision\tBy\tTime")
+			fmt.Fprintln(w, "------\t--------\t--\t----")
+			for _, approval := range record.Approvals {
+				decision := approval.Decision
+				if approval.Reason != "" {
+					decision = fmt.Sprintf("%s (%s)", decision, approval.Reason)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+					approval.Neuron, decision, approval.By, approval.Time.Format("15:04:05"))
+			}
+			w.Flush()
+		}
+
 		// Show detailed output for failed neurons
 		fmt.Printf("\nDetailed Output:\n")
 		for _, result := range record.NeuronResults {
-			if result.Status == "failed" || result.Stderr != "" || result.Error != "" {
+			if result.Status == "failed" || result.Status == "timed_out" || result.Stderr != "" || result.Error != "" || len(result.Fixes) > 0 {
 				fmt.Printf("\n=== %s ===\n", result.Name)
 				if result.Stdout != "" {
 					fmt.Printf("Stdout:\n%s\n", result.Stdout)
@@ -76,6 +114,19 @@ var synapseLogsCmd = &cobra.Command{
 				if result.Error != "" {
 					fmt.Printf("Error: %s\n", result.Error)
 				}
+				for _, attempt := range result.Attempts {
+					fmt.Printf("Attempt %d: exit code %d after %v", attempt.Attempt, attempt.ExitCode, attempt.Duration)
+					if attempt.Delay > 0 {
+						fmt.Printf(" (waited %v)", attempt.Delay)
+					}
+					if attempt.TimedOut {
+						fmt.Printf(" (timed out)")
+					}
+					fmt.Println()
+				}
+				for _, fix := range result.Fixes {
+					fmt.Printf("Fix %s for exit code %d: %s (exit code %d)\n", fix.Name, fix.ForExitCode, fix.Status, fix.ExitCode)
+				}
 			}
 		}
 	},
@@ -85,3 +136,29 @@ func init() {
 	rootCmd.AddCommand(synapseLogsCmd)
 	synapseLogsCmd.Flags().StringVar(&synapseLogsExecutionID, "execution-id", "", "Execution ID to show logs for (required)")
 }
+
+// printNeuronResults writes a row per neuron result, followed by the results
+// of nested synapses with their names prefixed by the step that ran them
+func printNeuronResults(w io.Writer, prefix string, results []synapse.NeuronResult) {
+	for _, result := range results {
+		status := result.Status
+		if result.Reason != "" {
+			status = fmt.Sprintf("%s (%s)", status, result.Reason)
+		}
+		if result.Reused {
+			status += " (reused)"
+		}
+		if result.Remediation != "" {
+			status += fmt.Sprintf(" (%s)", result.Remediation)
+		}
+		if result.Poll != nil {
+			status += fmt.Sprintf(" (%d polls)", result.Poll.Polls)
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%d\t%v\n",
+			prefix, result.Name, status, result.ExitCode, result.Duration)
+		if result.SubExecution != nil {
+			printNeuronResults(w, prefix+result.Name+"/", result.SubExecution.NeuronResults)
+		}
+		printNeuronResults(w, prefix+"  ", result.Items)
+	}
+}
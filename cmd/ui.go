@@ -16,6 +16,8 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -26,6 +28,7 @@ import (
 
 var port int
 var host string
+var uiScheduler bool
 
 // uiCmd represents the ui command
 var uiCmd = &cobra.Command{
@@ -39,10 +42,14 @@ The web UI provides:
 - Visual synapse builder with drag-and-drop
 - System metrics monitoring
 - Execution history
+- Listing and pausing schedules
+
+With --scheduler the server also runs scheduled synapses, like 'cortex scheduler'.
 
 Example:
   cortex ui --port 8080
-  cortex ui --host 0.0.0.0 --port 3000`,
+  cortex ui --host 0.0.0.0 --port 3000
+  cortex ui --scheduler`,
 	Run: func(cmd *cobra.Command, args []string) {
 		startWebServer()
 	},
@@ -52,6 +59,7 @@ func init() {
 	rootCmd.AddCommand(uiCmd)
 	uiCmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to run the web server on")
 	uiCmd.Flags().StringVarP(&host, "host", "H", "localhost", "Host to bind the web server to")
+	uiCmd.Flags().BoolVar(&uiScheduler, "scheduler", false, "Also run scheduled synapses")
 }
 
 func startWebServer() {
@@ -72,6 +80,19 @@ func startWebServer() {
 		}
 	}()
 
+	// Run scheduled synapses alongside the server
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	if uiScheduler {
+		go func() {
+			defer close(schedulerDone)
+			newScheduler(logger, io.Discard).Run(schedulerCtx)
+		}()
+		logger.Infof("Scheduler started")
+	} else {
+		close(schedulerDone)
+	}
+
 	logger.Infof("Cortex Web UI started successfully")
 	logger.Infof("Open your browser at http://%s:%d", host, port)
 
@@ -82,6 +103,8 @@ func startWebServer() {
 	if err := srv.Shutdown(); err != nil {
 		logger.Error(err, "Error during shutdown")
 	}
+	stopScheduler()
+	<-schedulerDone
 
 	logger.Info("Server stopped")
 }
@@ -0,0 +1,149 @@
+package neuron
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/anoop2811/cortex/logger"
+)
+
+// ContainerCLIEnvVar names the environment variable holding the docker or
+// podman compatible CLI to run neuron images with; docker, else podman, is
+// used when it is not set
+const ContainerCLIEnvVar = "CORTEX_CONTAINER_CLI"
+
+// containerFailureExitCode is the exit code docker and podman report when
+// they cannot run the container at all
+const containerFailureExitCode = 125
+
+// oomKilledExitCode is the exit code of a container killed for its memory
+const oomKilledExitCode = 137
+
+// ContainerRunner runs exec files inside a container of an image, so that the
+// tools a neuron needs come with the image. The directory of the exec file is
+// mounted at the same path and is the working directory; the neuron's env is
+// passed without appearing on the command line. Resource limits become
+// container limits. The CLI exits 125 when it cannot run the container, so
+// neurons in containers cannot use that exit code.
+type ContainerRunner struct {
+	Image   string
+	Command string // docker compatible CLI; $CORTEX_CONTAINER_CLI, docker or podman by default
+	logger  *log.StandardLogger
+}
+
+// NewContainerRunner creates a runner for image
+func NewContainerRunner(logger *log.StandardLogger, image string) *ContainerRunner {
+	command := os.Getenv(ContainerCLIEnvVar)
+	if command == "" {
+		command = "docker"
+		if _, err := exec.LookPath(command); err != nil {
+			if _, err := exec.LookPath("podman"); err == nil {
+				command = "podman"
+			}
+		}
+	}
+	return &ContainerRunner{Image: image, Command: command, logger: logger}
+}
+
+// Run runs cmd in a new container that is removed once it exits. When ctx is
+// done or the timeout expires the CLI is stopped and the container removed.
+func (r *ContainerRunner) Run(ctx context.Context, cmd Command) (*Result, error) {
+	execFile, err := filepath.Abs(cmd.ExecFile)
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to resolve exec file: %w", err)
+	}
+	if _, err := os.Stat(execFile); err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to read exec file: %w", err)
+	}
+
+	// The output file must be writable by whichever user the image runs as
+	outputDir, err := ioutil.TempDir("", "cortex-output-*")
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+	outputFile := filepath.Join(outputDir, "output")
+	if err := ioutil.WriteFile(outputFile, nil, 0666); err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to create output file: %w", err)
+	}
+	os.Chmod(outputDir, 0755)
+	os.Chmod(outputFile, 0666)
+
+	name, err := randomName("cortex-")
+	if err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+	opts := cmd.Options
+	args := r.args(name, execFile, outputDir, cmd.Env, opts.Limits)
+	memoryLimited := opts.Limits != nil && opts.Limits.Memory > 0
+	opts.Limits = nil
+
+	r.logger.Debugf("Running %s in %s as %s", execFile, r.Image, name)
+	env := append(os.Environ(), cmd.Env...)
+	result, err := runCommand(ctx, r.logger, nil, cmd.Out, cmd.Stderr, env, opts, r.Command, args...)
+	if result.TimedOut || ctx.Err() != nil {
+		r.remove(name)
+	}
+	switch {
+	case err == nil && result.ExitCode == containerFailureExitCode:
+		err = fmt.Errorf("%s could not run image %s", filepath.Base(r.Command), r.Image)
+	case err == nil && memoryLimited && result.ExitCode == oomKilledExitCode:
+		result.LimitExceeded = LimitMemory
+	}
+
+	if data, readErr := ioutil.ReadFile(outputFile); readErr == nil {
+		result.Outputs = ParseOutputs(opts.Masker.Mask(string(data)))
+	}
+	return result, err
+}
+
+// args returns the CLI arguments that run execFile in a container
+func (r *ContainerRunner) args(name, execFile, outputDir string, env []string, limits *Limits) []string {
+	dir := filepath.Dir(execFile)
+	args := []string{"run", "--rm", "--init", "--name", name,
+		"-v", dir + ":" + dir, "-w", dir,
+		"-v", outputDir + ":" + outputDir,
+		"-e", OutputEnvVar + "=" + filepath.Join(outputDir, "output"),
+	}
+	// Only names are passed; the CLI takes the values from its own environment
+	seen := make(map[string]bool)
+	for _, entry := range env {
+		key, _, ok := strings.Cut(entry, "=")
+		if ok && key != "" && !seen[key] {
+			seen[key] = true
+			args = append(args, "-e", key)
+		}
+	}
+	if limits != nil {
+		if limits.Memory > 0 {
+			args = append(args, "--memory", strconv.FormatUint(limits.Memory, 10))
+		}
+		if limits.MaxProcesses > 0 {
+			args = append(args, "--pids-limit", strconv.FormatUint(limits.MaxProcesses, 10))
+		}
+		if limits.CPUTime > 0 {
+			cpu := strconv.Itoa(seconds(limits.CPUTime))
+			args = append(args, "--ulimit", "cpu="+cpu+":"+cpu)
+		}
+		if limits.MaxOpenFiles > 0 {
+			files := strconv.FormatUint(limits.MaxOpenFiles, 10)
+			args = append(args, "--ulimit", "nofile="+files+":"+files)
+		}
+	}
+	return append(args, "--entrypoint", execFile, r.Image)
+}
+
+// remove force-removes the container, in case stopping the CLI left it running
+func (r *ContainerRunner) remove(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, r.Command, "rm", "-f", name).CombinedOutput(); err != nil {
+		r.logger.Debugf("Could not remove container %s: %v: %s", name, err, out)
+	}
+}
@@ -0,0 +1,165 @@
+package neuron_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/secret"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeContainerCLI records its arguments and runs the entrypoint locally, in
+// the working directory and with the env the container would have
+const fakeContainerCLI = `#!/bin/sh
+echo "$@" >> ARGS_LOG
+[ "$1" = rm ] && exit 0
+shift
+while [ $# -gt 1 ]; do
+  case "$1" in
+    --rm|--init) shift ;;
+    -w) cd "$2"; shift 2 ;;
+    -e) case "$2" in *=*) export "$2" ;; esac; shift 2 ;;
+    --entrypoint) entrypoint=$2; shift 2 ;;
+    *) shift 2 ;;
+  esac
+done
+[ "$1" = missing ] && { echo "Unable to find image 'missing:latest' locally" >&2; exit 125; }
+exec "$entrypoint"
+`
+
+var _ = Describe("ContainerRunner", func() {
+	var (
+		dir     string
+		argsLog string
+		runner  *neuron.ContainerRunner
+		out     *bytes.Buffer
+	)
+
+	script := func(body string) string {
+		path := filepath.Join(dir, "run.sh")
+		Expect(os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755)).To(Succeed())
+		return path
+	}
+
+	run := func(cmd neuron.Command) (*neuron.Result, error) {
+		cmd.Out, cmd.Stderr = out, out
+		if cmd.Options.Masker == nil {
+			cmd.Options.Masker = secret.NewMasker()
+		}
+		return runner.Run(context.Background(), cmd)
+	}
+
+	args := func() string {
+		data, err := os.ReadFile(argsLog)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cortex-container-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		dir, err = filepath.EvalSymlinks(dir)
+		Expect(err).NotTo(HaveOccurred())
+		argsLog = filepath.Join(dir, "args")
+		cli := filepath.Join(dir, "docker")
+		Expect(os.WriteFile(cli, []byte(strings.Replace(fakeContainerCLI, "ARGS_LOG", argsLog, 1)), 0755)).To(Succeed())
+
+		runner = neuron.NewContainerRunner(log.NewLoggerWithWriter(0, GinkgoWriter), "alpine/k8s:1.30")
+		runner.Command = cli
+		out = &bytes.Buffer{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("runs the exec file in the neuron directory of a container", func() {
+		execFile := script(`echo "hello $GREETING from $(pwd)"; echo "DONE=yes" >> "$CORTEX_OUTPUT"; exit 3`)
+		result, err := run(neuron.Command{ExecFile: execFile, Env: []string{"GREETING=world"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(Equal(3))
+		Expect(result.Stdout).To(Equal("hello world from " + dir + "\n"))
+		Expect(out.String()).To(Equal(result.Stdout))
+		Expect(result.Outputs).To(Equal(map[string]string{"DONE": "yes"}))
+
+		Expect(args()).To(MatchRegexp(`^run --rm --init --name cortex-[0-9a-f]{16} -v %[1]s:%[1]s -w %[1]s -v \S+:\S+ -e CORTEX_OUTPUT=\S+/output -e GREETING --entrypoint %[2]s alpine/k8s:1.30\n$`, dir, execFile))
+	})
+
+	It("keeps env values off the command line", func() {
+		result, err := run(neuron.Command{ExecFile: script(`echo "$TOKEN"`), Env: []string{"TOKEN=s3cr3t-t0ken"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Stdout).To(Equal("s3cr3t-t0ken\n"))
+		Expect(args()).NotTo(ContainSubstring("s3cr3t"))
+	})
+
+	It("turns resource limits into container limits", func() {
+		_, err := run(neuron.Command{
+			ExecFile: script("true"),
+			Options:  neuron.ExciteOptions{Limits: &neuron.Limits{Memory: 256 << 20, CPUTime: 30 * time.Second, MaxProcesses: 64, MaxOpenFiles: 1024}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(args()).To(ContainSubstring("--memory 268435456 --pids-limit 64 --ulimit cpu=30:30 --ulimit nofile=1024:1024 "))
+	})
+
+	It("stops and removes the container when the timeout expires", func() {
+		result, err := run(neuron.Command{
+			ExecFile: script("sleep 10"),
+			Options:  neuron.ExciteOptions{Timeout: 200 * time.Millisecond, GracePeriod: time.Second},
+		})
+		Expect(err).To(MatchError("timed out after 200ms"))
+		Expect(result.TimedOut).To(BeTrue())
+		Expect(args()).To(MatchRegexp(`\nrm -f cortex-[0-9a-f]{16}\n$`))
+	})
+
+	It("reports images that cannot be run", func() {
+		runner.Image = "missing"
+		result, err := run(neuron.Command{ExecFile: script("true")})
+		Expect(err).To(MatchError("docker could not run image missing"))
+		Expect(result.ExitCode).To(Equal(125))
+	})
+
+	It("is used for neurons with an image", func() {
+		config := filepath.Join(dir, "neuron.yml")
+		Expect(os.WriteFile(config, []byte("name: kubectl\ntype: check\nexec_file: "+script("echo in a container")+"\nimage: bitnami/kubectl:1.30\n"), 0644)).To(Succeed())
+		os.Setenv(neuron.ContainerCLIEnvVar, runner.Command)
+		defer os.Unsetenv(neuron.ContainerCLIEnvVar)
+
+		n, err := neuron.NewNeuron(log.NewLoggerWithWriter(0, GinkgoWriter), config)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Passed).To(BeTrue())
+		Expect(result.Stdout).To(Equal("in a container\n"))
+		Expect(args()).To(HaveSuffix(" bitnami/kubectl:1.30\n"))
+
+		_, err = n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{Target: &neuron.Target{Host: "web-01"}})
+		Expect(err).To(MatchError("neuron kubectl cannot run image bitnami/kubectl:1.30 on target web-01"))
+	})
+
+	// Set CORTEX_TEST_CONTAINER_IMAGE to an image with a shell to run against
+	// a real docker or podman
+	It("runs in a real container", func() {
+		image := os.Getenv("CORTEX_TEST_CONTAINER_IMAGE")
+		if image == "" {
+			Skip("CORTEX_TEST_CONTAINER_IMAGE is not set")
+		}
+		runner = neuron.NewContainerRunner(log.NewLoggerWithWriter(0, GinkgoWriter), image)
+
+		result, err := run(neuron.Command{
+			ExecFile: script(`echo "$GREETING"; echo "DONE=yes" >> "$CORTEX_OUTPUT"; exit 4`),
+			Env:      []string{"GREETING=hello"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(Equal(4))
+		Expect(result.Stdout).To(Equal("hello\n"))
+		Expect(result.Outputs).To(Equal(map[string]string{"DONE": "yes"}))
+	})
+})
@@ -0,0 +1,52 @@
+package neuron
+
+import (
+	"strings"
+	"time"
+)
+
+// Limit names reported in Result.LimitExceeded
+const (
+	LimitMemory    = "memory"
+	LimitCPUTime   = "cpu time"
+	LimitProcesses = "processes"
+	LimitOpenFiles = "open files"
+)
+
+// Limits constrains the resources a neuron process may use. Zero values are unlimited.
+type Limits struct {
+	Memory       uint64        // bytes of memory
+	CPUTime      time.Duration // user + system CPU time
+	MaxProcesses uint64        // processes and threads
+	MaxOpenFiles uint64        // file descriptors per process
+}
+
+// IsZero reports whether no limit is set
+func (l *Limits) IsZero() bool {
+	return l == nil || *l == Limits{}
+}
+
+// stderrViolation recognises the messages programs print when an rlimit
+// makes a system call fail. Kernel-enforced limits that kill the process are
+// detected from its exit status instead.
+func stderrViolation(limits *Limits, stderr string, memoryEnforced, processesEnforced bool) string {
+	msg := strings.ToLower(stderr)
+	switch {
+	case memoryEnforced && containsAny(msg, "cannot allocate memory", "out of memory", "cannot allocate", "memoryerror", "bad_alloc"):
+		return LimitMemory
+	case limits.MaxOpenFiles > 0 && containsAny(msg, "too many open files"):
+		return LimitOpenFiles
+	case processesEnforced && containsAny(msg, "resource temporarily unavailable", "cannot fork"):
+		return LimitProcesses
+	}
+	return ""
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
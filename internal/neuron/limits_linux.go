@@ -0,0 +1,257 @@
+//go:build linux
+// +build linux
+
+package neuron
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/anoop2811/cortex/logger"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// limiter enforces Limits on a single neuron process. Memory and process
+// limits use a cgroup v2 sub-group when one can be created, because cgroups
+// account for the whole process tree; otherwise they fall back to
+// RLIMIT_AS and RLIMIT_NPROC. CPU time and open files always use rlimits.
+type limiter struct {
+	limits    *Limits
+	logger    *log.StandardLogger
+	cgroup    string   // path of the sub-group, "" when cgroups are not used
+	cgroupDir *os.File // kept open so the child can be cloned straight into the group
+}
+
+func newLimiter(logger *log.StandardLogger, limits *Limits) *limiter {
+	if limits.IsZero() {
+		return nil
+	}
+	l := &limiter{limits: limits, logger: logger}
+
+	if limits.Memory > 0 || limits.MaxProcesses > 0 {
+		cgroup, err := createCgroup(limits)
+		if err != nil {
+			logger.Debugf("cgroup v2 limits unavailable, using rlimits: %v", err)
+		} else if dir, err := os.Open(cgroup); err != nil {
+			logger.Debugf("cgroup v2 limits unavailable, using rlimits: %v", err)
+			os.Remove(cgroup)
+		} else {
+			l.cgroup = cgroup
+			l.cgroupDir = dir
+		}
+	}
+	return l
+}
+
+// prepare configures cmd to start inside the cgroup
+func (l *limiter) prepare(cmd *exec.Cmd) {
+	if l == nil || l.cgroupDir == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(l.cgroupDir.Fd())
+}
+
+// started applies rlimits to the freshly started process. Children inherit
+// them, so everything the neuron spawns afterwards is limited as well.
+func (l *limiter) started(pid int) error {
+	if l == nil {
+		return nil
+	}
+	set := func(resource int, value uint64, hard uint64) error {
+		return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: value, Max: hard}, nil)
+	}
+
+	if l.limits.CPUTime > 0 {
+		// SIGXCPU at the soft limit, SIGKILL a second later if it is ignored
+		seconds := uint64((l.limits.CPUTime + time.Second - 1) / time.Second)
+		if err := set(unix.RLIMIT_CPU, seconds, seconds+1); err != nil {
+			return fmt.Errorf("failed to limit cpu time: %w", err)
+		}
+	}
+	if l.limits.MaxOpenFiles > 0 {
+		if err := set(unix.RLIMIT_NOFILE, l.limits.MaxOpenFiles, l.limits.MaxOpenFiles); err != nil {
+			return fmt.Errorf("failed to limit open files: %w", err)
+		}
+	}
+	if l.limits.Memory > 0 && l.cgroup == "" {
+		if err := set(unix.RLIMIT_AS, l.limits.Memory, l.limits.Memory); err != nil {
+			return fmt.Errorf("failed to limit memory: %w", err)
+		}
+	}
+	if l.limits.MaxProcesses > 0 && l.cgroup == "" {
+		if err := set(unix.RLIMIT_NPROC, l.limits.MaxProcesses, l.limits.MaxProcesses); err != nil {
+			return fmt.Errorf("failed to limit processes: %w", err)
+		}
+	}
+	return nil
+}
+
+// violation returns the limit that made the process fail, or ""
+func (l *limiter) violation(state *os.ProcessState, stderr string) string {
+	if l == nil || state == nil || state.Success() {
+		return ""
+	}
+
+	if l.cgroup != "" {
+		if l.limits.Memory > 0 && cgroupEvent(l.cgroup, "memory.events", "oom_kill") > 0 {
+			return LimitMemory
+		}
+		if l.limits.MaxProcesses > 0 && cgroupEvent(l.cgroup, "pids.events", "max") > 0 {
+			return LimitProcesses
+		}
+	}
+
+	if l.limits.CPUTime > 0 {
+		status, _ := state.Sys().(syscall.WaitStatus)
+		cpu := state.UserTime() + state.SystemTime()
+		switch {
+		case status.Signaled() && status.Signal() == syscall.SIGXCPU:
+			return LimitCPUTime
+		case status.Signaled() && status.Signal() == syscall.SIGKILL && cpu >= l.limits.CPUTime:
+			return LimitCPUTime
+		case status.ExitStatus() == 128+int(syscall.SIGXCPU):
+			// a shell reporting that one of its children hit the limit
+			return LimitCPUTime
+		}
+	}
+
+	return stderrViolation(l.limits, stderr, l.limits.Memory > 0 && l.cgroup == "", l.limits.MaxProcesses > 0 && l.cgroup == "")
+}
+
+// cleanup kills whatever is left in the cgroup and removes it
+func (l *limiter) cleanup() {
+	if l == nil || l.cgroup == "" {
+		return
+	}
+	l.cgroupDir.Close()
+	os.WriteFile(filepath.Join(l.cgroup, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 50; i++ {
+		if err := os.Remove(l.cgroup); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.logger.Debugf("failed to remove cgroup %s", l.cgroup)
+}
+
+// createCgroup creates a cgroup v2 sub-group of the current process' cgroup
+// with the memory and pids limits applied
+func createCgroup(limits *Limits) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+
+	self, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Join(cgroupRoot, self)
+
+	var controllers []string
+	if limits.Memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.MaxProcesses > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if err := enableControllers(parent, controllers); err != nil {
+		return "", err
+	}
+
+	cgroup, err := os.MkdirTemp(parent, "cortex-neuron-")
+	if err != nil {
+		return "", err
+	}
+
+	write := func(file string, value string) error {
+		return os.WriteFile(filepath.Join(cgroup, file), []byte(value), 0644)
+	}
+	if limits.Memory > 0 {
+		if err := write("memory.max", strconv.FormatUint(limits.Memory, 10)); err != nil {
+			os.Remove(cgroup)
+			return "", err
+		}
+		// Without swap the limit is hard; not every kernel exposes the file
+		write("memory.swap.max", "0")
+	}
+	if limits.MaxProcesses > 0 {
+		if err := write("pids.max", strconv.FormatUint(limits.MaxProcesses, 10)); err != nil {
+			os.Remove(cgroup)
+			return "", err
+		}
+	}
+	return cgroup, nil
+}
+
+// ownCgroup returns the cgroup v2 path of the current process
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path := strings.TrimPrefix(scanner.Text(), "0::"); path != scanner.Text() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// enableControllers makes sure the controllers are delegated to children of parent
+func enableControllers(parent string, controllers []string) error {
+	subtree := filepath.Join(parent, "cgroup.subtree_control")
+	for _, controller := range controllers {
+		data, err := os.ReadFile(subtree)
+		if err != nil {
+			return err
+		}
+		if containsField(string(data), controller) {
+			continue
+		}
+		if err := os.WriteFile(subtree, []byte("+"+controller), 0644); err != nil {
+			return fmt.Errorf("cannot enable %s controller in %s: %w", controller, parent, err)
+		}
+	}
+	return nil
+}
+
+// cgroupEvent reads a counter from a cgroup events file
+func cgroupEvent(cgroup, file, key string) int {
+	data, err := os.ReadFile(filepath.Join(cgroup, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
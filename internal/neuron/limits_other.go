@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package neuron
+
+import (
+	"os"
+	"os/exec"
+
+	log "github.com/anoop2811/cortex/logger"
+)
+
+// limiter only reports limits as unsupported outside Linux
+type limiter struct{}
+
+func newLimiter(logger *log.StandardLogger, limits *Limits) *limiter {
+	if !limits.IsZero() {
+		logger.Infof("Resource limits are only enforced on Linux, running without them")
+	}
+	return nil
+}
+
+func (l *limiter) prepare(cmd *exec.Cmd) {}
+
+func (l *limiter) started(pid int) error { return nil }
+
+func (l *limiter) violation(state *os.ProcessState, stderr string) string { return "" }
+
+func (l *limiter) cleanup() {}
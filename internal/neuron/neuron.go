@@ -1,25 +1,31 @@
 package neuron
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os/exec"
-	"syscall"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/anoop2811/cortex/internal/secret"
 	log "github.com/anoop2811/cortex/logger"
 	"github.com/fatih/color"
 	"gopkg.in/yaml.v2"
 )
 
 type NeuronInterface interface {
-	Excite(mutating bool) (int, error)
+	Excite(mutating bool, out io.Writer) (*Result, error)
 }
 
 func NewNeuron(logger *log.StandardLogger, configPath string) (*Neuron, error) {
 	neuronConfig, err := ioutil.ReadFile(configPath)
 	if err != nil {
-		logger.Fatalf(err, "Unable to read neuron file [%s]", configPath)
+		return nil, fmt.Errorf("unable to read neuron file [%s]: %w", configPath, err)
 	}
 
 	logger.Debugf("config data is %s", neuronConfig)
@@ -28,50 +34,229 @@ func NewNeuron(logger *log.StandardLogger, configPath string) (*Neuron, error) {
 	err = yaml.Unmarshal(neuronConfig, &neuron)
 
 	if err != nil {
-		logger.Fatalf(err, "Unable to unmarshal file [%s] to yaml", configPath)
+		return nil, fmt.Errorf("unable to unmarshal file [%s] to yaml: %w", configPath, err)
 	}
 	neuron.logger = logger
 
 	return &neuron, nil
 }
 
-func (n *Neuron) Excite(mutating bool, out io.Writer) (int, error) {
-	color.New(color.FgYellow).Fprintf(out, "===> %s", n.PreExecDebug)
-	return runCommand(n.logger, n.ExecFile)
+// ExecPath returns the absolute path of the exec file of the neuron config at
+// configPath, resolved as it is when the neuron runs
+func ExecPath(configPath string) (string, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read neuron file [%s]: %w", configPath, err)
+	}
+	var neuron Neuron
+	if err := yaml.Unmarshal(data, &neuron); err != nil {
+		return "", fmt.Errorf("unable to unmarshal file [%s] to yaml: %w", configPath, err)
+	}
+	return neuron.execPath()
+}
+
+// execPath returns the absolute path of the exec file; a relative exec_file
+// is taken from the working directory
+func (n *Neuron) execPath() (string, error) {
+	if n.ExecFile == "" {
+		return "", fmt.Errorf("neuron %s has no exec_file", n.Name)
+	}
+	path, err := filepath.Abs(n.ExecFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve exec file: %w", err)
+	}
+	return path, nil
+}
+
+// Excite runs the neuron, streaming its output to out and returning the captured result
+func (n *Neuron) Excite(mutating bool, out io.Writer) (*Result, error) {
+	return n.ExciteWithOptions(context.Background(), ExciteOptions{Mutating: mutating, Out: out})
 }
 
-func runCommand(logger *log.StandardLogger, name string, args ...string) (int, error) {
-	var outbuf, errbuf bytes.Buffer
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = &outbuf
-	cmd.Stderr = &errbuf
+// ExciteWithOptions runs the neuron with the given options
+func (n *Neuron) ExciteWithOptions(ctx context.Context, opts ExciteOptions) (*Result, error) {
+	out := opts.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	out = &syncWriter{w: out}
+	errOut := out
+	if opts.Stderr != nil {
+		errOut = &syncWriter{w: opts.Stderr}
+	}
+
+	ownEnv, secrets, err := n.Env.Resolve(opts.SecretStore)
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to resolve env: %w", err)
+	}
+	if opts.Masker == nil {
+		opts.Masker = secret.NewMasker()
+	}
+	opts.Masker.Add(secrets...)
+
+	if opts.Timeout == 0 && n.Timeout != "" {
+		timeout, err := time.ParseDuration(n.Timeout)
+		if err != nil {
+			return &Result{ExitCode: -1}, fmt.Errorf("invalid timeout %q: %w", n.Timeout, err)
+		}
+		opts.Timeout = timeout
+	}
+
+	runner, err := n.runner(opts)
+	if err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+	execFile, err := n.execPath()
+	if err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+
+	color.New(color.FgYellow).Fprintf(out, "===> %s\n", n.PreExecDebug)
+	result, err := runner.Run(ctx, Command{
+		ExecFile: execFile,
+		Env:      append(ownEnv, opts.Env...),
+		Out:      out,
+		Stderr:   errOut,
+		Options:  opts,
+	})
+	result.Passed = err == nil && n.AcceptsExitCode(result.ExitCode)
+	return result, err
+}
+
+// runner returns the runner of opts, else one for the neuron's image, its own
+// target or the target of opts, else a local runner
+func (n *Neuron) runner(opts ExciteOptions) (Runner, error) {
+	target := n.Target
+	if target == nil {
+		target = opts.Target
+	}
+	switch {
+	case opts.Runner != nil:
+		return opts.Runner, nil
+	case n.Image != "" && target != nil:
+		return nil, fmt.Errorf("neuron %s cannot run image %s on target %s", n.Name, n.Image, target)
+	case n.Image != "":
+		return NewContainerRunner(n.logger, n.Image), nil
+	case target != nil:
+		return NewSSHRunner(n.logger, *target), nil
+	}
+	return NewLocalRunner(n.logger), nil
+}
+
+// AcceptsExitCode reports whether exitCode counts as success: 0, or one of
+// the codes listed in assert_exit_status
+func (n *Neuron) AcceptsExitCode(exitCode int) bool {
+	if exitCode == 0 {
+		return true
+	}
+	for _, code := range n.AssertExitStatus {
+		if c, err := strconv.Atoi(strings.TrimSpace(code)); err == nil && c == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand runs the command in its own process group. When ctx is done or
+// opts.Timeout expires the whole group is sent SIGTERM, and SIGKILL once the
+// grace period has passed.
+func runCommand(ctx context.Context, logger *log.StandardLogger, stdin io.Reader, out, errOut io.Writer, env []string, opts ExciteOptions, name string, args ...string) (*Result, error) {
+	stdout := newCappedBuffer(opts.maxOutputBytes())
+	stderr := newCappedBuffer(opts.maxOutputBytes())
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	gracePeriod := opts.gracePeriod()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Each stream is masked separately, so that their partial lines don't mix
+	stdoutOut := secret.NewWriter(out, opts.Masker)
+	stderrOut := secret.NewWriter(errOut, opts.Masker)
+	defer stdoutOut.Flush()
+	defer stderrOut.Flush()
+
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stdout = io.MultiWriter(stdoutOut, stdout)
+	cmd.Stderr = io.MultiWriter(stderrOut, stderr)
+	setProcessGroup(cmd)
+
+	// Cancel runs on the goroutine watching ctx; Wait returns after it did
+	var cancelledAt time.Time
+	cmd.Cancel = func() error {
+		cancelledAt = time.Now()
+		logger.Debugf("Terminating %s: %v", name, ctx.Err())
+		return terminateProcessGroup(cmd.Process)
+	}
+	// Bounds how long Wait blocks on the process, or on pipes held open by
+	// its children, once it was cancelled or has exited
+	cmd.WaitDelay = gracePeriod
+
+	limiter := newLimiter(logger, opts.Limits)
+	defer limiter.cleanup()
+	limiter.prepare(cmd)
 
-	err := cmd.Run()
-	stdout := outbuf.String()
-	stderr := errbuf.String()
 	var defaultFailedCode = -1
-	var exitCode int
+	exitCode := defaultFailedCode
 
+	err := cmd.Start()
+	if err == nil {
+		if limitErr := limiter.started(cmd.Process.Pid); limitErr != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return &Result{ExitCode: defaultFailedCode}, limitErr
+		}
+		err = cmd.Wait()
+		if !cancelledAt.IsZero() {
+			reapProcessGroup(cmd.Process, cancelledAt.Add(gracePeriod))
+		}
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the process exited but a child kept its output open
+		err = nil
+	}
+
+	timedOut := !cancelledAt.IsZero() && errors.Is(ctx.Err(), context.DeadlineExceeded)
 	if err != nil {
 		// try to get the exit code
-		if exitError, ok := err.(*exec.ExitError); ok {
-			ws := exitError.Sys().(syscall.WaitStatus)
-			exitCode = ws.ExitStatus()
-			return exitCode, nil
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
+			exitCode = exitError.ExitCode()
+			err = nil
+		} else if !cancelledAt.IsZero() && cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+			err = nil
 		} else {
 			logger.Debugf("Could not get exit code for failed program: %v, %v", name, args)
-			exitCode = defaultFailedCode
-			if stderr == "" {
-				stderr = err.Error()
+			if stderr.Len() == 0 {
+				stderr.Write([]byte(err.Error()))
 			}
-			return defaultFailedCode, err
 		}
 	} else {
 		// success, exitCode should be 0 if go is ok
-		ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
-		exitCode = ws.ExitStatus()
-		return exitCode, nil
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	result := &Result{
+		ExitCode:  exitCode,
+		Stdout:    opts.Masker.Mask(stdout.String()),
+		Stderr:    opts.Masker.Mask(stderr.String()),
+		Truncated: stdout.Truncated() || stderr.Truncated(),
+		TimedOut:  timedOut,
+	}
+	result.LimitExceeded = limiter.violation(cmd.ProcessState, result.Stderr)
+	logger.Debugf("command result, stdout: %v, stderr: %v, exitCode: %v", result.Stdout, result.Stderr, result.ExitCode)
+
+	switch {
+	case timedOut && opts.Timeout > 0:
+		return result, fmt.Errorf("timed out after %v", opts.Timeout)
+	case timedOut:
+		return result, fmt.Errorf("timed out")
+	case !cancelledAt.IsZero():
+		return result, fmt.Errorf("cancelled: %w", ctx.Err())
 	}
-	logger.Debugf("command result, stdout: %v, stderr: %v, exitCode: %v", stdout, stderr, exitCode)
-	return defaultFailedCode, nil
+	return result, err
 }
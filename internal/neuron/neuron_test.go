@@ -1,10 +1,13 @@
 package neuron_test
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 
 	"github.com/anoop2811/cortex/internal/neuron"
 	log "github.com/anoop2811/cortex/logger"
@@ -72,6 +75,12 @@ post_exec_fail_debug:
 
 			Expect(neuron.Name).To(Equal("check_web_proxy_conn_config"))
 		})
+
+		It("resolves a relative exec file from the working directory", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(neuron.ExecPath(neuronConfigPath)).To(Equal(filepath.Join(wd, "run.sh")))
+		})
 	})
 
 	Context("when neuron is excited", func() {
@@ -93,9 +102,199 @@ post_exec_fail_debug:
 
 			n, err = neuron.NewNeuron(logger, neuronConfigPath)
 
-			exitCode, err := n.Excite(false, buffer)
+			result, err := n.Excite(false, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ExitCode).To(Equal(1))
+		})
+
+		It("passes only exit codes listed in assert_exit_status", func() {
+			_, err = runFile.WriteString("#!/bin/bash \n exit 110")
+			Expect(err).NotTo(HaveOccurred())
+			runFile.Close()
+
+			n, err = neuron.NewNeuron(logger, neuronConfigPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := n.Excite(false, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Passed).To(BeFalse())
+
+			n.AssertExitStatus = []string{"0", "110"}
+			result, err = n.Excite(false, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Passed).To(BeTrue())
+		})
+
+		It("captures stdout and stderr while streaming them", func() {
+			_, err = runFile.WriteString("#!/bin/bash \n echo to-stdout \n echo to-stderr >&2 \n exit 3")
+			Expect(err).NotTo(HaveOccurred())
+			runFile.Close()
+
+			n, err = neuron.NewNeuron(logger, neuronConfigPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := n.Excite(false, buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ExitCode).To(Equal(3))
+			Expect(result.Stdout).To(Equal("to-stdout\n"))
+			Expect(result.Stderr).To(Equal("to-stderr\n"))
+			Expect(result.Truncated).To(BeFalse())
+			Eventually(buffer).Should(gbytes.Say("to-stdout"))
+		})
+
+		It("collects KEY=VALUE outputs written to $CORTEX_OUTPUT", func() {
+			_, err = runFile.WriteString("#!/bin/bash \n echo POD_NAME=web-1 >> $CORTEX_OUTPUT \n echo '# comment' >> $CORTEX_OUTPUT \n echo NAMESPACE=$TARGET_NS >> $CORTEX_OUTPUT")
+			Expect(err).NotTo(HaveOccurred())
+			runFile.Close()
+
+			n, err = neuron.NewNeuron(logger, neuronConfigPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{Out: buffer, Env: []string{"TARGET_NS=prod"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Outputs).To(Equal(map[string]string{"POD_NAME": "web-1", "NAMESPACE": "prod"}))
+		})
+
+		It("streams stderr to its own writer when one is given", func() {
+			_, err = runFile.WriteString("#!/bin/bash \n echo to-stdout \n echo to-stderr >&2")
+			Expect(err).NotTo(HaveOccurred())
+			runFile.Close()
+
+			n, err = neuron.NewNeuron(logger, neuronConfigPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			stdout, stderr := gbytes.NewBuffer(), gbytes.NewBuffer()
+			_, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{Out: stdout, Stderr: stderr})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stdout.Contents())).To(ContainSubstring("to-stdout"))
+			Expect(string(stdout.Contents())).NotTo(ContainSubstring("to-stderr"))
+			Expect(string(stderr.Contents())).To(Equal("to-stderr\n"))
+		})
+
+		It("keeps the tail of output beyond the size cap", func() {
+			_, err = runFile.WriteString("#!/bin/bash \n echo 0123456789abcdef")
+			Expect(err).NotTo(HaveOccurred())
+			runFile.Close()
+
+			n, err = neuron.NewNeuron(logger, neuronConfigPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{Out: buffer, MaxOutputBytes: 7})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(exitCode).To(Equal(1))
+			Expect(result.Truncated).To(BeTrue())
+			Expect(result.Stdout).To(Equal(fmt.Sprintf(neuron.TruncationMarker, 10) + "abcdef\n"))
+		})
+
+		Context("with resource limits", func() {
+			exciteLimited := func(script string, limits neuron.Limits) *neuron.Result {
+				if runtime.GOOS != "linux" {
+					Skip("resource limits are only enforced on Linux")
+				}
+				_, err = runFile.WriteString("#!/bin/bash \n" + script)
+				Expect(err).NotTo(HaveOccurred())
+				runFile.Close()
+
+				n, err = neuron.NewNeuron(logger, neuronConfigPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				result, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{Out: buffer, Limits: &limits})
+				Expect(err).NotTo(HaveOccurred())
+				return result
+			}
+
+			It("stops processes that exceed their cpu time", func() {
+				result := exciteLimited("while :; do :; done", neuron.Limits{CPUTime: time.Second})
+				Expect(result.ExitCode).NotTo(Equal(0))
+				Expect(result.LimitExceeded).To(Equal(neuron.LimitCPUTime))
+			})
+
+			It("reports exhausted file descriptors", func() {
+				result := exciteLimited("for i in $(seq 1 40); do exec {fd}</dev/null || exit 1; done", neuron.Limits{MaxOpenFiles: 16})
+				Expect(result.ExitCode).To(Equal(1))
+				Expect(result.LimitExceeded).To(Equal(neuron.LimitOpenFiles))
+			})
+
+			It("reports exhausted memory", func() {
+				result := exciteLimited(`x=$(head -c 200000000 /dev/zero | tr "\0" a); echo ${#x}`, neuron.Limits{Memory: 64 << 20})
+				Expect(result.ExitCode).NotTo(Equal(0))
+				Expect(result.LimitExceeded).To(Equal(neuron.LimitMemory))
+			})
+
+			It("does not report a violation for processes within their limits", func() {
+				result := exciteLimited("echo fine", neuron.Limits{Memory: 256 << 20, CPUTime: 10 * time.Second, MaxOpenFiles: 64})
+				Expect(result.ExitCode).To(Equal(0))
+				Expect(result.LimitExceeded).To(BeEmpty())
+			})
+
+			It("does not blame limits for ordinary failures", func() {
+				result := exciteLimited("echo broken >&2; exit 4", neuron.Limits{CPUTime: 10 * time.Second, MaxOpenFiles: 64})
+				Expect(result.ExitCode).To(Equal(4))
+				Expect(result.LimitExceeded).To(BeEmpty())
+			})
+		})
+
+		Context("with a timeout", func() {
+			excite := func(script string, opts neuron.ExciteOptions) (*neuron.Result, time.Duration, error) {
+				_, err = runFile.WriteString("#!/bin/bash \n" + script)
+				Expect(err).NotTo(HaveOccurred())
+				runFile.Close()
+
+				n, err = neuron.NewNeuron(logger, neuronConfigPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				opts.Out = buffer
+				start := time.Now()
+				result, err := n.ExciteWithOptions(context.Background(), opts)
+				return result, time.Since(start), err
+			}
+
+			It("stops the process when the timeout expires", func() {
+				result, elapsed, err := excite("sleep 30", neuron.ExciteOptions{Timeout: 200 * time.Millisecond})
+				Expect(err).To(MatchError("timed out after 200ms"))
+				Expect(result.TimedOut).To(BeTrue())
+				Expect(result.ExitCode).NotTo(Equal(0))
+				Expect(elapsed).To(BeNumerically("<", 5*time.Second))
+			})
+
+			It("kills processes that ignore SIGTERM after the grace period", func() {
+				result, elapsed, _ := excite("trap '' TERM \n sleep 30", neuron.ExciteOptions{
+					Timeout:     200 * time.Millisecond,
+					GracePeriod: 300 * time.Millisecond,
+				})
+				Expect(result.TimedOut).To(BeTrue())
+				Expect(elapsed).To(BeNumerically(">=", 500*time.Millisecond))
+				Expect(elapsed).To(BeNumerically("<", 5*time.Second))
+			})
+
+			It("stops everything the process spawned", func() {
+				marker := filepath.Join(neuronPath, "still-running")
+				result, _, _ := excite("(sleep 1; touch "+marker+") & \n sleep 30", neuron.ExciteOptions{Timeout: 200 * time.Millisecond})
+				Expect(result.TimedOut).To(BeTrue())
+
+				Consistently(func() bool {
+					_, err := os.Stat(marker)
+					return os.IsNotExist(err)
+				}, "1500ms", "100ms").Should(BeTrue())
+			})
+
+			It("does not time out processes that finish in time", func() {
+				result, _, err := excite("echo quick", neuron.ExciteOptions{Timeout: 5 * time.Second})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.TimedOut).To(BeFalse())
+				Expect(result.ExitCode).To(Equal(0))
+			})
+
+			Context("configured in the neuron", func() {
+				BeforeEach(func() {
+					neuronConfigData += "\ntimeout: 200ms"
+				})
+
+				It("uses the neuron's own timeout", func() {
+					result, _, err := excite("sleep 30", neuron.ExciteOptions{})
+					Expect(err).To(MatchError("timed out after 200ms"))
+					Expect(result.TimedOut).To(BeTrue())
+				})
+			})
 		})
 
 		It("logs the pre exec debug statement", func() {
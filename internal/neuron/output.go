@@ -0,0 +1,111 @@
+package neuron
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// OutputEnvVar names the environment variable holding the path of the file a
+// neuron writes KEY=VALUE output lines to
+const OutputEnvVar = "CORTEX_OUTPUT"
+
+var outputKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseOutputs parses KEY=VALUE lines written to $CORTEX_OUTPUT. Blank lines,
+// comments and malformed lines are ignored; later values override earlier ones.
+func ParseOutputs(data string) map[string]string {
+	outputs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		if !outputKeyPattern.MatchString(key) {
+			continue
+		}
+		outputs[key] = parts[1]
+	}
+	if len(outputs) == 0 {
+		return nil
+	}
+	return outputs
+}
+
+// DefaultMaxOutputBytes is the default cap on captured output per stream
+const DefaultMaxOutputBytes = 64 * 1024
+
+// TruncationMarker prefixes captured output whose head was dropped to honour the size cap
+const TruncationMarker = "[... %d bytes truncated ...]\n"
+
+// cappedBuffer keeps the last max bytes written to it. The tail is kept because
+// the end of a failing script's output is usually where the diagnosis is.
+type cappedBuffer struct {
+	max     int
+	buf     []byte
+	dropped int
+	mu      sync.Mutex
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if b.max > 0 && len(b.buf) > b.max {
+		over := len(b.buf) - b.max
+		b.dropped += over
+		copy(b.buf, b.buf[over:])
+		b.buf = b.buf[:b.max]
+	}
+	return len(p), nil
+}
+
+// Len returns the number of bytes written, including dropped ones
+func (b *cappedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buf) + b.dropped
+}
+
+// Truncated reports whether any output was dropped
+func (b *cappedBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped > 0
+}
+
+// String returns the captured output, prefixed with a marker if it was truncated
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dropped > 0 {
+		return fmt.Sprintf(TruncationMarker, b.dropped) + string(b.buf)
+	}
+	return string(b.buf)
+}
+
+// syncWriter serializes writes so stdout and stderr can share one writer
+type syncWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package neuron
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts the command as the leader of a new process group,
+// so that it can be stopped together with everything it spawned
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminateProcessGroup asks every process in the group to exit
+func terminateProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+// reapProcessGroup waits until the group is gone or the deadline has passed,
+// then kills whatever is left of it
+func reapProcessGroup(p *os.Process, deadline time.Time) {
+	for time.Now().Before(deadline) {
+		if err := syscall.Kill(-p.Pid, 0); err == syscall.ESRCH {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
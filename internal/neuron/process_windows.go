@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package neuron
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup is a no-op: Windows has no process groups to signal
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills the process, Windows cannot deliver SIGTERM
+func terminateProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// reapProcessGroup is a no-op, the process was already killed
+func reapProcessGroup(p *os.Process, deadline time.Time) {}
@@ -0,0 +1,60 @@
+package neuron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	log "github.com/anoop2811/cortex/logger"
+)
+
+// Runner runs the exec file of a neuron, on this machine or elsewhere
+type Runner interface {
+	// Run runs cmd, streaming its output, and returns its exit code, captured
+	// output and $CORTEX_OUTPUT outputs. The returned result is never nil.
+	Run(ctx context.Context, cmd Command) (*Result, error)
+}
+
+// Command is an exec file for a Runner to run
+type Command struct {
+	ExecFile string
+	// Env holds the KEY=VALUE entries of the neuron, added to the environment
+	// the runner provides
+	Env []string
+	// Out and Stderr receive the live stdout and stderr
+	Out    io.Writer
+	Stderr io.Writer
+	// Options holds the timeout, grace period, limits, output cap and masker
+	Options ExciteOptions
+}
+
+// LocalRunner runs exec files as processes of this machine
+type LocalRunner struct {
+	logger *log.StandardLogger
+}
+
+// NewLocalRunner creates a runner for this machine
+func NewLocalRunner(logger *log.StandardLogger) *LocalRunner {
+	return &LocalRunner{logger: logger}
+}
+
+// Run runs cmd with the environment of this process
+func (r *LocalRunner) Run(ctx context.Context, cmd Command) (*Result, error) {
+	outputFile, err := ioutil.TempFile("", "cortex-output-*")
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to create output file: %w", err)
+	}
+	outputFile.Close()
+	defer os.Remove(outputFile.Name())
+
+	env := append(os.Environ(), cmd.Env...)
+	env = append(env, OutputEnvVar+"="+outputFile.Name())
+
+	result, err := runCommand(ctx, r.logger, nil, cmd.Out, cmd.Stderr, env, cmd.Options, cmd.ExecFile)
+	if data, readErr := ioutil.ReadFile(outputFile.Name()); readErr == nil {
+		result.Outputs = ParseOutputs(cmd.Options.Masker.Mask(string(data)))
+	}
+	return result, err
+}
@@ -0,0 +1,252 @@
+package neuron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/anoop2811/cortex/logger"
+)
+
+// SSHCommandEnvVar names the environment variable holding the ssh client to
+// run neurons on targets with; ssh is used when it is not set
+const SSHCommandEnvVar = "CORTEX_SSH"
+
+// sshFailureExitCode is the exit code of ssh when it cannot connect, and of
+// the remote script when the exec file cannot be uploaded
+const sshFailureExitCode = 255
+
+// cleanupTimeout bounds cleaning up after a neuron ran on a target or in a
+// container
+const cleanupTimeout = 30 * time.Second
+
+// Target is a host neurons run on over SSH. It is written either as a
+// destination such as deploy@web-01:2222 or as a mapping.
+type Target struct {
+	Host     string   `yaml:"host"`
+	User     string   `yaml:"user,omitempty"`
+	Port     int      `yaml:"port,omitempty"`
+	Identity string   `yaml:"identity,omitempty"` // private key file
+	Options  []string `yaml:"options,omitempty"`  // ssh -o options, e.g. StrictHostKeyChecking=accept-new
+}
+
+// ParseTarget parses a destination of the form [user@]host[:port]
+func ParseTarget(destination string) (Target, error) {
+	var target Target
+	host := destination
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		target.User, host = host[:i], host[i+1:]
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return Target{}, fmt.Errorf("invalid target %q: bad port %q", destination, port)
+		}
+		host, target.Port = h, p
+	}
+	target.Host = host
+	if err := target.Validate(); err != nil {
+		return Target{}, err
+	}
+	return target, nil
+}
+
+// UnmarshalYAML accepts both a destination string and a mapping
+func (t *Target) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var destination string
+	if err := unmarshal(&destination); err == nil {
+		target, err := ParseTarget(destination)
+		if err != nil {
+			return err
+		}
+		*t = target
+		return nil
+	}
+
+	type targetAlias Target
+	var target targetAlias
+	if err := unmarshal(&target); err != nil {
+		return err
+	}
+	*t = Target(target)
+	return t.Validate()
+}
+
+// Validate checks that the target names a host that cannot be mistaken for an
+// ssh option
+func (t Target) Validate() error {
+	switch {
+	case t.Host == "":
+		return fmt.Errorf("target has no host")
+	case strings.HasPrefix(t.Host, "-") || strings.ContainsAny(t.Host, " \t\n@"):
+		return fmt.Errorf("invalid target host %q", t.Host)
+	case strings.HasPrefix(t.User, "-") || strings.ContainsAny(t.User, " \t\n@"):
+		return fmt.Errorf("invalid target user %q", t.User)
+	case t.Port < 0 || t.Port > 65535:
+		return fmt.Errorf("invalid target port %d", t.Port)
+	}
+	return nil
+}
+
+// String returns the destination of the target, [user@]host[:port]
+func (t Target) String() string {
+	s := t.Host
+	if t.Port != 0 {
+		s = net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	}
+	if t.User != "" {
+		s = t.User + "@" + s
+	}
+	return s
+}
+
+// SSHRunner runs exec files on a target with the ssh client, so that the
+// user's ssh configuration, agent and known hosts apply. The exec file and
+// the neuron's env are uploaded over the connection to a private temporary
+// directory, which is removed once the outputs were collected. ssh exits 255
+// when it cannot connect, so remote neurons cannot use that exit code.
+// Resource limits are not enforced on the target.
+type SSHRunner struct {
+	Target  Target
+	Command string // ssh client to run; $CORTEX_SSH or ssh by default
+	logger  *log.StandardLogger
+}
+
+// NewSSHRunner creates a runner for target
+func NewSSHRunner(logger *log.StandardLogger, target Target) *SSHRunner {
+	command := os.Getenv(SSHCommandEnvVar)
+	if command == "" {
+		command = "ssh"
+	}
+	return &SSHRunner{Target: target, Command: command, logger: logger}
+}
+
+// Run uploads and runs cmd on the target. A timeout stops the local ssh
+// client; the remote script is stopped a grace period later by timeout(1),
+// if the target has it, even when the connection was lost.
+func (r *SSHRunner) Run(ctx context.Context, cmd Command) (*Result, error) {
+	script, err := os.Open(cmd.ExecFile)
+	if err != nil {
+		return &Result{ExitCode: -1}, fmt.Errorf("failed to read exec file: %w", err)
+	}
+	defer script.Close()
+
+	dir, err := randomName("/tmp/cortex-")
+	if err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+	envFile := r.envFile(cmd.Env)
+
+	opts := cmd.Options
+	if !opts.Limits.IsZero() {
+		r.logger.Debugf("Resource limits are not enforced on %s", r.Target)
+	}
+	opts.Limits = nil
+
+	r.logger.Debugf("Running %s on %s in %s", cmd.ExecFile, r.Target, dir)
+	stdin := strings.NewReader(envFile)
+	result, err := runCommand(ctx, r.logger, io.MultiReader(stdin, script), cmd.Out, cmd.Stderr, os.Environ(), opts,
+		r.Command, r.args(remoteScript(dir, len(envFile), opts))...)
+	if result.ExitCode == sshFailureExitCode {
+		if err == nil {
+			err = fmt.Errorf("ssh to %s failed", r.Target)
+		}
+		return result, err
+	}
+
+	outputs, collectErr := r.collect(dir)
+	if collectErr != nil {
+		r.logger.Debugf("Could not collect outputs from %s: %v", r.Target, collectErr)
+	} else {
+		result.Outputs = ParseOutputs(opts.Masker.Mask(outputs))
+	}
+	return result, err
+}
+
+// args returns the ssh arguments that run remoteCommand on the target
+func (r *SSHRunner) args(remoteCommand string) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if r.Target.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(r.Target.Port))
+	}
+	if r.Target.User != "" {
+		args = append(args, "-l", r.Target.User)
+	}
+	if r.Target.Identity != "" {
+		args = append(args, "-i", r.Target.Identity)
+	}
+	for _, option := range r.Target.Options {
+		args = append(args, "-o", option)
+	}
+	// The remote login shell may not be POSIX
+	return append(args, r.Target.Host, "sh -c "+shellQuote(remoteCommand))
+}
+
+// envFile returns a shell file assigning env, leaving out entries whose name
+// is not a shell variable name
+func (r *SSHRunner) envFile(env []string) string {
+	var b strings.Builder
+	for _, entry := range env {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !outputKeyPattern.MatchString(parts[0]) {
+			r.logger.Debugf("Not passing %q to %s", parts[0], r.Target)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", parts[0], shellQuote(parts[1]))
+	}
+	return b.String()
+}
+
+// collect returns what the script wrote to $CORTEX_OUTPUT and removes dir
+func (r *SSHRunner) collect(dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	quoted := shellQuote(dir)
+	cmd := exec.CommandContext(ctx, r.Command, r.args("cat "+quoted+"/output 2>/dev/null; rm -rf "+quoted)...)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// remoteScript returns the script that stores the env file and exec file
+// read from stdin in dir, then runs the exec file with that env
+func remoteScript(dir string, envSize int, opts ExciteOptions) string {
+	quoted := shellQuote(dir)
+	run := "exec " + quoted + "/run"
+	if opts.Timeout > 0 {
+		grace := opts.gracePeriod()
+		run = fmt.Sprintf("if command -v timeout >/dev/null 2>&1; then exec timeout -k %d %d %s/run; fi; %s",
+			seconds(grace), seconds(opts.Timeout+grace), quoted, run)
+	}
+	return fmt.Sprintf("umask 077 && mkdir %[1]s && dd of=%[1]s/env bs=1 count=%[2]d 2>/dev/null && "+
+		"cat > %[1]s/run && chmod 700 %[1]s/run || exit %[3]d; "+
+		"set -a && . %[1]s/env && %[4]s=%[1]s/output && set +a || exit %[3]d; %[5]s",
+		quoted, envSize, sshFailureExitCode, OutputEnvVar, run)
+}
+
+// randomName returns prefix followed by random hex digits
+func randomName(prefix string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a name: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
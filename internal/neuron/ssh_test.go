@@ -0,0 +1,191 @@
+package neuron_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/secret"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+// fakeSSH records its arguments and runs the remote command locally
+const fakeSSH = `#!/bin/sh
+printf '%s\n' "$@" > ARGS_LOG
+case "$*" in *unreachable*) echo "ssh: connect to host unreachable: No route to host" >&2; exit 255 ;; esac
+while [ $# -gt 1 ]; do shift; done
+exec sh -c "$1"
+`
+
+var _ = Describe("Target", func() {
+	It("parses destinations", func() {
+		target, err := neuron.ParseTarget("deploy@web-01:2222")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal(neuron.Target{Host: "web-01", User: "deploy", Port: 2222}))
+		Expect(target.String()).To(Equal("deploy@web-01:2222"))
+
+		target, err = neuron.ParseTarget("10.0.0.5")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal(neuron.Target{Host: "10.0.0.5"}))
+	})
+
+	It("rejects hosts that ssh would take for options", func() {
+		_, err := neuron.ParseTarget("-oProxyCommand=evil")
+		Expect(err).To(MatchError(ContainSubstring("invalid target host")))
+		_, err = neuron.ParseTarget("web-01:99999")
+		Expect(err).To(MatchError("invalid target port 99999"))
+	})
+
+	It("unmarshals from a destination or a mapping", func() {
+		var config struct {
+			Short neuron.Target `yaml:"short"`
+			Long  neuron.Target `yaml:"long"`
+		}
+		Expect(yaml.Unmarshal([]byte("short: root@db-01\nlong:\n  host: web-01\n  port: 2222\n  identity: ~/.ssh/cortex\n  options: [StrictHostKeyChecking=accept-new]\n"), &config)).To(Succeed())
+		Expect(config.Short).To(Equal(neuron.Target{Host: "db-01", User: "root"}))
+		Expect(config.Long).To(Equal(neuron.Target{Host: "web-01", Port: 2222, Identity: "~/.ssh/cortex", Options: []string{"StrictHostKeyChecking=accept-new"}}))
+
+		Expect(yaml.Unmarshal([]byte("long:\n  user: root\n"), &config)).To(MatchError("target has no host"))
+	})
+})
+
+var _ = Describe("SSHRunner", func() {
+	var (
+		dir     string
+		argsLog string
+		runner  *neuron.SSHRunner
+		out     *bytes.Buffer
+	)
+
+	script := func(body string) string {
+		path := filepath.Join(dir, "run.sh")
+		Expect(os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0644)).To(Succeed())
+		return path
+	}
+
+	run := func(cmd neuron.Command) (*neuron.Result, error) {
+		cmd.Out, cmd.Stderr = out, out
+		if cmd.Options.Masker == nil {
+			cmd.Options.Masker = secret.NewMasker()
+		}
+		return runner.Run(context.Background(), cmd)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cortex-ssh-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		argsLog = filepath.Join(dir, "args")
+		ssh := filepath.Join(dir, "ssh")
+		Expect(os.WriteFile(ssh, []byte(strings.Replace(fakeSSH, "ARGS_LOG", argsLog, 1)), 0755)).To(Succeed())
+
+		runner = neuron.NewSSHRunner(log.NewLoggerWithWriter(0, GinkgoWriter), neuron.Target{
+			Host: "web-01", User: "deploy", Port: 2222, Identity: "/keys/cortex", Options: []string{"StrictHostKeyChecking=no"},
+		})
+		runner.Command = ssh
+		out = &bytes.Buffer{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("uploads the exec file and runs it with the neuron's env", func() {
+		result, err := run(neuron.Command{
+			ExecFile: script(`echo "hello $GREETING"; echo "RAN_IN=$(dirname "$0")" >> "$CORTEX_OUTPUT"; exit 3`),
+			Env:      []string{"GREETING=it's me"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(Equal(3))
+		Expect(result.Stdout).To(Equal("hello it's me\n"))
+		Expect(out.String()).To(Equal("hello it's me\n"))
+
+		Expect(result.Outputs).To(HaveKey("RAN_IN"))
+		Expect(result.Outputs["RAN_IN"]).To(HavePrefix("/tmp/cortex-"))
+		Expect(result.Outputs["RAN_IN"]).NotTo(BeADirectory(), "the uploaded files are removed")
+
+		args, err := os.ReadFile(argsLog)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(args)).To(HavePrefix("-o\nBatchMode=yes\n-p\n2222\n-l\ndeploy\n-i\n/keys/cortex\n-o\nStrictHostKeyChecking=no\nweb-01\nsh -c "))
+	})
+
+	It("keeps env values off the remote command line", func() {
+		result, err := run(neuron.Command{ExecFile: script(`echo "$TOKEN"`), Env: []string{"TOKEN=s3cr3t-t0ken"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Stdout).To(Equal("s3cr3t-t0ken\n"))
+
+		args, err := os.ReadFile(argsLog)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(args)).NotTo(ContainSubstring("s3cr3t"))
+	})
+
+	It("stops the script when the timeout expires", func() {
+		start := time.Now()
+		result, err := run(neuron.Command{
+			ExecFile: script("sleep 10"),
+			Options:  neuron.ExciteOptions{Timeout: 200 * time.Millisecond, GracePeriod: time.Second},
+		})
+		Expect(err).To(MatchError("timed out after 200ms"))
+		Expect(result.TimedOut).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("reports connection failures", func() {
+		runner.Target.Host = "unreachable"
+		result, err := run(neuron.Command{ExecFile: script("true")})
+		Expect(err).To(MatchError("ssh to deploy@unreachable:2222 failed"))
+		Expect(result.ExitCode).To(Equal(255))
+	})
+
+	It("reports a missing exec file", func() {
+		_, err := run(neuron.Command{ExecFile: filepath.Join(dir, "missing.sh")})
+		Expect(err).To(MatchError(ContainSubstring("failed to read exec file")))
+	})
+
+	It("is used for neurons with a target", func() {
+		config := filepath.Join(dir, "neuron.yml")
+		Expect(os.WriteFile(config, []byte("name: remote\ntype: check\nexec_file: "+script("echo on $(hostname)")+"\ntarget: deploy@web-01\n"), 0644)).To(Succeed())
+		os.Setenv(neuron.SSHCommandEnvVar, runner.Command)
+		defer os.Unsetenv(neuron.SSHCommandEnvVar)
+
+		n, err := neuron.NewNeuron(log.NewLoggerWithWriter(0, GinkgoWriter), config)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := n.ExciteWithOptions(context.Background(), neuron.ExciteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Passed).To(BeTrue())
+
+		args, err := os.ReadFile(argsLog)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(args)).To(ContainSubstring("-l\ndeploy\nweb-01\n"))
+	})
+
+	// Set CORTEX_TEST_SSH_TARGET (and CORTEX_TEST_SSH_IDENTITY) to run
+	// against a real sshd, e.g. one listening on localhost:2222
+	It("runs on a real sshd", func() {
+		destination := os.Getenv("CORTEX_TEST_SSH_TARGET")
+		if destination == "" {
+			Skip("CORTEX_TEST_SSH_TARGET is not set")
+		}
+		target, err := neuron.ParseTarget(destination)
+		Expect(err).NotTo(HaveOccurred())
+		target.Identity = os.Getenv("CORTEX_TEST_SSH_IDENTITY")
+		target.Options = []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null"}
+		runner = neuron.NewSSHRunner(log.NewLoggerWithWriter(0, GinkgoWriter), target)
+
+		result, err := run(neuron.Command{
+			ExecFile: script(`echo "$GREETING"; echo "DONE=yes" >> "$CORTEX_OUTPUT"; exit 4`),
+			Env:      []string{"GREETING=hello"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(Equal(4))
+		Expect(result.Stdout).To(Equal("hello\n"))
+		Expect(result.Outputs).To(Equal(map[string]string{"DONE": "yes"}))
+	})
+})
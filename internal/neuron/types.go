@@ -1,6 +1,12 @@
 package neuron
 
-import log "github.com/anoop2811/cortex/logger"
+import (
+	"io"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/secret"
+	log "github.com/anoop2811/cortex/logger"
+)
 
 type Neuron struct {
 	logger               *log.StandardLogger
@@ -9,9 +15,13 @@ type Neuron struct {
 	Description          string         `yaml:"description"`
 	ExecFile             string         `yaml:"exec_file"`
 	PreExecDebug         string         `yaml:"pre_exec_debug"`
+	Timeout              string         `yaml:"timeout,omitempty"`
 	AssertExitStatus     []string       `yaml:"assert_exit_status"`
 	PostExecSuccessDebug string         `yaml:"post_exec_success_debug"`
 	PostExecFailDebug    map[int]string `yaml:"post_exec_fail_debug"`
+	Env                  secret.Env     `yaml:"env,omitempty"`    // added to the process environment only
+	Target               *Target        `yaml:"target,omitempty"` // host the neuron runs on over SSH, if not local
+	Image                string         `yaml:"image,omitempty"`  // container image the neuron runs in, if any
 }
 
 type Definition struct {
@@ -21,5 +31,74 @@ type Definition struct {
 
 type Config struct {
 	Path string         `yaml:"path"`
-	Fix  map[int]string `yaml:"fix"`
+	Fix  map[int]string `yaml:"fix,omitempty"`
+}
+
+// Result holds the outcome of exciting a neuron
+type Result struct {
+	ExitCode  int
+	Stdout    string
+	Stderr    string
+	Truncated bool
+	// Outputs are the KEY=VALUE pairs the neuron wrote to $CORTEX_OUTPUT
+	Outputs map[string]string
+	// LimitExceeded names the resource limit that made the process fail, if any
+	LimitExceeded string
+	// TimedOut is set when the process was stopped because its deadline passed
+	TimedOut bool
+	// Passed is set when the process exited 0 or with a code listed in
+	// assert_exit_status
+	Passed bool
+}
+
+// ExciteOptions controls how a neuron process is run
+type ExciteOptions struct {
+	Mutating bool
+	// Out receives the live stdout and stderr of the process
+	Out io.Writer
+	// Stderr receives the live stderr instead of Out, if set
+	Stderr io.Writer
+	// Env holds extra KEY=VALUE entries added to the process environment
+	Env []string
+	// MaxOutputBytes caps the captured output per stream. Zero uses
+	// DefaultMaxOutputBytes, a negative value disables the cap.
+	MaxOutputBytes int
+	// Limits constrains the resources of the process, nil for none
+	Limits *Limits
+	// Timeout stops the process after the given time; zero uses the
+	// neuron's own timeout, if any
+	Timeout time.Duration
+	// GracePeriod is how long a stopped process may take to exit after
+	// SIGTERM before it is killed. Zero uses DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Masker masks secrets in the live and captured output. The secrets of
+	// the neuron's own env are added to it.
+	Masker *secret.Masker
+	// SecretStore resolves fromStore env entries; nil uses the default store
+	SecretStore *secret.Store
+	// Target is the host neurons without a target of their own run on; nil
+	// runs them locally
+	Target *Target
+	// Runner runs the neuron instead of the runner for its target, if set
+	Runner Runner
+}
+
+// DefaultGracePeriod is how long a process may take to exit after SIGTERM
+const DefaultGracePeriod = 10 * time.Second
+
+func (o ExciteOptions) gracePeriod() time.Duration {
+	if o.GracePeriod <= 0 {
+		return DefaultGracePeriod
+	}
+	return o.GracePeriod
+}
+
+func (o ExciteOptions) maxOutputBytes() int {
+	switch {
+	case o.MaxOutputBytes == 0:
+		return DefaultMaxOutputBytes
+	case o.MaxOutputBytes < 0:
+		return 0
+	}
+	return o.MaxOutputBytes
 }
@@ -0,0 +1,58 @@
+package schedule_test
+
+import (
+	"time"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron", func() {
+	// Saturday
+	start := time.Date(2026, 10, 17, 10, 7, 30, 0, time.UTC)
+
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	DescribeTable("finds the next matching minute",
+		func(expr string, next time.Time) {
+			cron, err := schedule.ParseCron(expr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cron.Next(start)).To(Equal(next))
+		},
+		Entry("every minute", "* * * * *", at(10, 17, 10, 8)),
+		Entry("step", "*/5 * * * *", at(10, 17, 10, 10)),
+		Entry("list", "0,30 * * * *", at(10, 17, 10, 30)),
+		Entry("range with step", "0 9-17/4 * * *", at(10, 17, 13, 0)),
+		Entry("next day", "0 9 * * *", at(10, 18, 9, 0)),
+		Entry("day of week name", "30 8 * * MON", at(10, 19, 8, 30)),
+		Entry("Sunday as 7", "0 0 * * 7", at(10, 18, 0, 0)),
+		Entry("day of month", "0 0 1 * *", at(11, 1, 0, 0)),
+		Entry("either day field when both are restricted", "0 0 20 * SAT", at(10, 20, 0, 0)),
+		Entry("month name", "0 0 1 JAN *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Entry("macro", "@hourly", at(10, 17, 11, 0)),
+		Entry("leap day", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)),
+	)
+
+	It("keeps the time zone it is given", func() {
+		zone := time.FixedZone("UTC+2", 2*60*60)
+		cron, err := schedule.ParseCron("0 9 * * *")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cron.Next(start.In(zone))).To(Equal(time.Date(2026, 10, 18, 9, 0, 0, 0, zone)))
+	})
+
+	DescribeTable("rejects invalid expressions",
+		func(expr, message string) {
+			_, err := schedule.ParseCron(expr)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("too few fields", "* * * *", "expected 5 fields, got 4"),
+		Entry("out of range", "60 * * * *", "minute 60 out of range 0-59"),
+		Entry("bad step", "*/0 * * * *", "invalid step"),
+		Entry("bad name", "0 0 * * FUNDAY", `invalid day of week "FUNDAY"`),
+		Entry("reversed range", "0 17-9 * * *", "invalid range"),
+		Entry("never matching", "0 0 30 2 *", "never matches"),
+	)
+})
@@ -0,0 +1,232 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+)
+
+const (
+	// pollInterval is how often the scheduler reads the schedules again, so
+	// that changes made by other processes are picked up
+	pollInterval = 10 * time.Second
+	// missedAfter is how late a run may start before it counts as missed
+	missedAfter = time.Minute
+	// maxMissedCount bounds the counting of runs dropped by CatchUpSkip
+	maxMissedCount = 10000
+)
+
+// errScheduleChanged is returned from store updates when another process
+// changed the schedule since it was read
+var errScheduleChanged = errors.New("schedule changed")
+
+// NextStart returns when the next run starts: its cron time plus jitter
+func (s Schedule) NextStart() time.Time {
+	return s.NextRun.Add(s.jitter())
+}
+
+// jitter returns how long after its cron time the next run starts. It is
+// derived from the name and cron time, so it stays the same across restarts.
+func (s Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", s.Name, s.NextRun.Unix())
+	return time.Duration(h.Sum64() % uint64(s.Jitter))
+}
+
+// Scheduler runs the synapses of the schedules in a Store when they are due
+type Scheduler struct {
+	store   *Store
+	history *synapse.HistoryManager
+	locks   *synapse.LockManager
+	logger  *log.StandardLogger
+	out     io.Writer
+	running map[string]bool // schedules with an execution in progress
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler for the schedules in store. Executions
+// are recorded in history, hold their synapse lock in locks and print their
+// progress to out; history and locks may be nil.
+func NewScheduler(store *Store, history *synapse.HistoryManager, locks *synapse.LockManager, logger *log.StandardLogger, out io.Writer) *Scheduler {
+	if out == nil {
+		out = io.Discard
+	}
+	return &Scheduler{
+		store:   store,
+		history: history,
+		locks:   locks,
+		logger:  logger,
+		out:     out,
+		running: make(map[string]bool),
+	}
+}
+
+// Run starts due schedules until ctx is cancelled, then waits for the
+// executions in progress, which are cancelled with ctx
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.startDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs every schedule due at now and waits for them to finish
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	s.startDue(ctx, now).Wait()
+}
+
+// startDue starts an execution for every due schedule that is not running
+// yet, applying the catch-up policy of schedules that missed runs
+func (s *Scheduler) startDue(ctx context.Context, now time.Time) *sync.WaitGroup {
+	started := &sync.WaitGroup{}
+
+	schedules, err := s.store.List()
+	if err != nil {
+		s.logger.Errorf(err, "Failed to read schedules")
+		return started
+	}
+
+	for _, schedule := range schedules {
+		if schedule.Paused || s.isRunning(schedule.Name) || schedule.NextStart().After(now) {
+			continue
+		}
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			s.logger.Errorf(err, "Invalid schedule %s", schedule.Name)
+			continue
+		}
+
+		run, next, missed := true, cron.Next(schedule.NextRun), 0
+		if now.Sub(schedule.NextStart()) > missedAfter {
+			switch schedule.CatchUp {
+			case CatchUpSkip:
+				run, next = false, cron.Next(now)
+				for t := schedule.NextRun; !t.IsZero() && !t.After(now) && missed < maxMissedCount; t = cron.Next(t) {
+					missed++
+				}
+				s.logger.Infof("Schedule %s missed %d run(s), next run at %s", schedule.Name, missed, next.Format(time.RFC3339))
+			case CatchUpOnce:
+				next = cron.Next(now)
+			}
+		}
+
+		// The next run is stored before this one starts, so that no run is
+		// started twice
+		updated, err := s.store.Update(schedule.Name, func(current *Schedule) error {
+			if current.Paused || !current.NextRun.Equal(schedule.NextRun) {
+				return errScheduleChanged
+			}
+			current.NextRun = next
+			current.MissedRuns += missed
+			return nil
+		})
+		if errors.Is(err, errScheduleChanged) || errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Errorf(err, "Failed to update schedule %s", schedule.Name)
+			continue
+		}
+		if !run {
+			continue
+		}
+
+		s.setRunning(updated.Name, true)
+		s.wg.Add(1)
+		started.Add(1)
+		go func(schedule Schedule) {
+			defer s.wg.Done()
+			defer started.Done()
+			defer s.setRunning(schedule.Name, false)
+			s.execute(ctx, schedule)
+		}(*updated)
+	}
+	return started
+}
+
+// execute runs the synapse of a schedule and records the outcome on it
+func (s *Scheduler) execute(ctx context.Context, schedule Schedule) {
+	s.logger.Infof("Running schedule %s: synapse %s", schedule.Name, schedule.Synapse)
+	startedAt := time.Now()
+
+	record, err := s.runSynapse(ctx, schedule)
+
+	_, updateErr := s.store.Update(schedule.Name, func(current *Schedule) error {
+		current.LastRun = startedAt
+		current.LastStatus = "failed"
+		current.LastExecutionID = ""
+		current.LastError = ""
+		if record != nil && record.ID != "" {
+			current.LastStatus = record.Status
+			current.LastExecutionID = record.ID
+		}
+		if err != nil {
+			current.LastError = err.Error()
+		}
+		return nil
+	})
+	if updateErr != nil && !errors.Is(updateErr, ErrNotFound) {
+		s.logger.Errorf(updateErr, "Failed to update schedule %s", schedule.Name)
+	}
+	if err != nil {
+		s.logger.Errorf(err, "Schedule %s failed", schedule.Name)
+	}
+}
+
+// runSynapse executes the synapse of a schedule unattended: mutate neurons
+// only run with ApproveAll, and a synapse that is already running is not
+// waited for
+func (s *Scheduler) runSynapse(ctx context.Context, schedule Schedule) (*synapse.ExecutionRecord, error) {
+	syn, err := synapse.LoadFromDirectory(schedule.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load synapse: %w", err)
+	}
+
+	executor := synapse.NewExecutor(s.logger, s.history, s.out)
+	executor.SetEnvironment(schedule.Inputs)
+	executor.SetSchedule(schedule.Name)
+	if schedule.ApproveAll {
+		executor.SetApprover(synapse.ApproveAll)
+	} else {
+		executor.SetReadOnly(true)
+	}
+	if s.locks != nil {
+		executor.SetLocks(s.locks, synapse.LockPolicy{FailIfLocked: true})
+	}
+	return executor.Run(ctx, syn, schedule.Path)
+}
+
+func (s *Scheduler) isRunning(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[name]
+}
+
+func (s *Scheduler) setRunning(name string, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if running {
+		s.running[name] = true
+	} else {
+		delete(s.running, name)
+	}
+}
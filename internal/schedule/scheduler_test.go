@@ -0,0 +1,224 @@
+package schedule_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scheduler", func() {
+	var (
+		baseDir    string
+		synapseDir string
+		runsFile   string
+		store      *schedule.Store
+		history    *synapse.HistoryManager
+		locks      *synapse.LockManager
+		scheduler  *schedule.Scheduler
+	)
+
+	// start is a Saturday, a few seconds after 10:05
+	start := time.Date(2026, 10, 17, 10, 5, 3, 0, time.UTC)
+
+	// runs returns the inputs the synapse saw, one line per execution
+	runs := func() []string {
+		data, err := os.ReadFile(runsFile)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Fields(string(data))
+	}
+
+	add := func(s schedule.Schedule) *schedule.Schedule {
+		if s.Path == "" {
+			s.Path = synapseDir
+		}
+		s.Synapse = "nightly"
+		added, err := store.Add(s, start)
+		Expect(err).NotTo(HaveOccurred())
+		return added
+	}
+
+	get := func(name string) *schedule.Schedule {
+		s, err := store.Get(name)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	BeforeEach(func() {
+		var err error
+		baseDir, err = os.MkdirTemp("", "cortex-schedule-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		synapseDir = filepath.Join(baseDir, "nightly")
+		runsFile = filepath.Join(baseDir, "runs")
+
+		neuronsDir := filepath.Join(synapseDir, "neurons")
+		Expect(os.MkdirAll(neuronsDir, 0755)).To(Succeed())
+		script := filepath.Join(neuronsDir, "record.sh")
+		Expect(os.WriteFile(script, []byte("#!/bin/bash\necho \"${CORTEX_INPUT_ENV:-none}\" >> "+runsFile+"\n"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(neuronsDir, "record.yml"), []byte(fmt.Sprintf("name: record\ntype: check\nexec_file: %s\n", script)), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(synapseDir, synapse.ConfigFile), []byte("apiVersion: cortex/v1\nname: nightly\ninputs:\n  - name: env\n    default: dev\nneurons: [record]\n"), 0644)).To(Succeed())
+
+		store = schedule.NewStore(filepath.Join(baseDir, "cortex"))
+		history = synapse.NewHistoryManager(filepath.Join(baseDir, "history"))
+		locks = synapse.NewLockManager(filepath.Join(baseDir, "locks"))
+		scheduler = schedule.NewScheduler(store, history, locks, log.NewLoggerWithWriter(0, GinkgoWriter), GinkgoWriter)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(baseDir)
+	})
+
+	Describe("Store", func() {
+		It("persists schedules with their next run", func() {
+			add(schedule.Schedule{Name: "nightly", Cron: "0 2 * * *"})
+
+			schedules, err := schedule.NewStore(filepath.Join(baseDir, "cortex")).List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(schedules).To(HaveLen(1))
+			Expect(schedules[0].CatchUp).To(Equal(schedule.CatchUpSkip))
+			Expect(schedules[0].NextRun).To(BeTemporally("==", time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)))
+		})
+
+		It("rejects duplicate and invalid schedules", func() {
+			add(schedule.Schedule{Name: "nightly", Cron: "0 2 * * *"})
+
+			_, err := store.Add(schedule.Schedule{Name: "nightly", Path: synapseDir, Cron: "0 3 * * *"}, start)
+			Expect(err).To(MatchError("schedule nightly already exists"))
+			_, err = store.Add(schedule.Schedule{Name: "other", Path: synapseDir, Cron: "0 3 * * *", CatchUp: "sometimes"}, start)
+			Expect(err).To(MatchError(`invalid catch-up policy "sometimes" (expected skip, once or all)`))
+			_, err = store.Add(schedule.Schedule{Name: "other", Path: synapseDir, Cron: "tomorrow"}, start)
+			Expect(err).To(MatchError(ContainSubstring("expected 5 fields")))
+		})
+
+		It("removes schedules", func() {
+			add(schedule.Schedule{Name: "nightly", Cron: "0 2 * * *"})
+
+			Expect(store.Remove("nightly")).To(Succeed())
+			Expect(store.Remove("nightly")).To(MatchError(schedule.ErrNotFound))
+		})
+	})
+
+	It("runs due schedules through the executor and records the outcome", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *", Inputs: map[string]string{"env": "prod"}})
+
+		scheduler.RunDue(context.Background(), start)
+		Expect(runs()).To(BeEmpty(), "not due before 10:10")
+
+		scheduler.RunDue(context.Background(), start.Add(5*time.Minute))
+		Expect(runs()).To(Equal([]string{"prod"}))
+
+		s := get("nightly")
+		Expect(s.LastStatus).To(Equal("success"))
+		Expect(s.NextRun).To(BeTemporally("==", time.Date(2026, 10, 17, 10, 15, 0, 0, time.UTC)))
+
+		records, err := history.GetHistory("nightly")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].ID).To(Equal(s.LastExecutionID))
+		Expect(records[0].Schedule).To(Equal("nightly"))
+		Expect(records[0].ReadOnly).To(BeTrue(), "mutate neurons need approve_all")
+	})
+
+	It("does not run paused schedules and drops the runs they missed", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *"})
+		_, err := store.Pause("nightly")
+		Expect(err).NotTo(HaveOccurred())
+
+		scheduler.RunDue(context.Background(), start.Add(time.Hour))
+		Expect(runs()).To(BeEmpty())
+
+		_, err = store.Resume("nightly", start.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(get("nightly").NextRun).To(BeTemporally("==", time.Date(2026, 10, 17, 11, 10, 0, 0, time.UTC)))
+	})
+
+	It("starts runs up to the jitter after their cron time", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *", Jitter: time.Minute})
+		s := get("nightly")
+		Expect(s.NextStart()).To(BeTemporally(">=", s.NextRun))
+		Expect(s.NextStart()).To(BeTemporally("<", s.NextRun.Add(time.Minute)))
+		Expect(s.NextStart()).To(Equal(get("nightly").NextStart()), "the jitter is stable")
+
+		scheduler.RunDue(context.Background(), s.NextStart().Add(-time.Second))
+		if s.NextStart().After(s.NextRun) {
+			Expect(runs()).To(BeEmpty())
+		}
+		scheduler.RunDue(context.Background(), s.NextStart())
+		Expect(runs()).To(HaveLen(1))
+	})
+
+	DescribeTable("applies the catch-up policy to missed runs",
+		func(catchUp schedule.CatchUp, expectedRuns int, missed int) {
+			add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *", CatchUp: catchUp})
+
+			// Three runs (10:10, 10:15, 10:20) were missed by 10:22
+			late := time.Date(2026, 10, 17, 10, 22, 0, 0, time.UTC)
+			for i := 0; i < 5; i++ {
+				scheduler.RunDue(context.Background(), late)
+			}
+
+			Expect(runs()).To(HaveLen(expectedRuns))
+			s := get("nightly")
+			Expect(s.MissedRuns).To(Equal(missed))
+			Expect(s.NextRun).To(BeTemporally("==", time.Date(2026, 10, 17, 10, 25, 0, 0, time.UTC)))
+		},
+		Entry("skip", schedule.CatchUpSkip, 0, 3),
+		Entry("once", schedule.CatchUpOnce, 1, 0),
+		Entry("all", schedule.CatchUpAll, 3, 0),
+	)
+
+	It("does not run a synapse that is already running", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *"})
+		lock, err := locks.TryAcquire("nightly")
+		Expect(err).NotTo(HaveOccurred())
+		defer lock.Release()
+
+		scheduler.RunDue(context.Background(), start.Add(5*time.Minute))
+
+		Expect(runs()).To(BeEmpty())
+		s := get("nightly")
+		Expect(s.LastStatus).To(Equal("locked"))
+		Expect(s.LastError).To(ContainSubstring("already running"))
+	})
+
+	It("records schedules whose synapse cannot be loaded", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "*/5 * * * *", Path: filepath.Join(baseDir, "missing")})
+
+		scheduler.RunDue(context.Background(), start.Add(5*time.Minute))
+
+		s := get("nightly")
+		Expect(s.LastStatus).To(Equal("failed"))
+		Expect(s.LastError).To(HavePrefix("failed to load synapse"))
+	})
+
+	It("runs until it is stopped", func() {
+		add(schedule.Schedule{Name: "nightly", Cron: "* * * * *"})
+		_, err := store.Update("nightly", func(s *schedule.Schedule) error {
+			s.NextRun = time.Now().Add(-time.Second)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			scheduler.Run(ctx)
+			close(done)
+		}()
+
+		Eventually(runs).Should(HaveLen(1))
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,252 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// storeFile holds every schedule as a JSON array
+const storeFile = "schedules.json"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ErrNotFound is returned for schedules that do not exist
+var ErrNotFound = errors.New("schedule not found")
+
+// CatchUp decides what happens to runs missed while no scheduler was running
+type CatchUp string
+
+const (
+	CatchUpSkip CatchUp = "skip" // drop missed runs and wait for the next one
+	CatchUpOnce CatchUp = "once" // run once for any number of missed runs
+	CatchUpAll  CatchUp = "all"  // run once for every missed run
+)
+
+// Schedule runs a synapse periodically
+type Schedule struct {
+	Name       string            `json:"name"`
+	Path       string            `json:"path"` // synapse directory
+	Synapse    string            `json:"synapse"`
+	Cron       string            `json:"cron"`
+	Jitter     time.Duration     `json:"jitter,omitempty"` // runs start up to this much after the cron time
+	CatchUp    CatchUp           `json:"catch_up"`
+	Inputs     map[string]string `json:"inputs,omitempty"`
+	ApproveAll bool              `json:"approve_all,omitempty"` // run mutate neurons; they are skipped otherwise
+	Paused     bool              `json:"paused,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+
+	NextRun         time.Time `json:"next_run"` // cron time of the next run, before jitter
+	LastRun         time.Time `json:"last_run,omitempty"`
+	LastStatus      string    `json:"last_status,omitempty"` // status of the last execution
+	LastExecutionID string    `json:"last_execution_id,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+	MissedRuns      int       `json:"missed_runs,omitempty"` // runs dropped by the skip policy
+}
+
+// Validate checks the name, cron expression and catch-up policy
+func (s *Schedule) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid schedule name %q", s.Name)
+	}
+	if s.Path == "" {
+		return fmt.Errorf("schedule %s has no synapse directory", s.Name)
+	}
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	switch s.CatchUp {
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return fmt.Errorf("invalid catch-up policy %q (expected skip, once or all)", s.CatchUp)
+	}
+	if s.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	return nil
+}
+
+// Store keeps schedules in a JSON file, shared by the CLI, the scheduler and
+// the Web UI
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a store kept in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store in ~/.cortex
+func DefaultStore() (*Store, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewStore(filepath.Join(home, ".cortex")), nil
+}
+
+// List returns every schedule ordered by name
+func (s *Store) List() ([]Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Get returns the named schedule
+func (s *Store) Get(name string) (*Schedule, error) {
+	schedules, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		if schedules[i].Name == name {
+			return &schedules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Add stores a new schedule, computing its first run from now
+func (s *Store) Add(schedule Schedule, now time.Time) (*Schedule, error) {
+	if schedule.CatchUp == "" {
+		schedule.CatchUp = CatchUpSkip
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	cron, _ := ParseCron(schedule.Cron)
+	schedule.CreatedAt = now
+	schedule.NextRun = cron.Next(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range schedules {
+		if existing.Name == schedule.Name {
+			return nil, fmt.Errorf("schedule %s already exists", schedule.Name)
+		}
+	}
+	schedules = append(schedules, schedule)
+	if err := s.save(schedules); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// Update changes the named schedule with update and stores the result
+func (s *Store) Update(name string, update func(*Schedule) error) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		if schedules[i].Name != name {
+			continue
+		}
+		if err := update(&schedules[i]); err != nil {
+			return nil, err
+		}
+		if err := s.save(schedules); err != nil {
+			return nil, err
+		}
+		return &schedules[i], nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Pause stops the named schedule from running until it is resumed
+func (s *Store) Pause(name string) (*Schedule, error) {
+	return s.Update(name, func(schedule *Schedule) error {
+		schedule.Paused = true
+		return nil
+	})
+}
+
+// Resume lets a paused schedule run again from its next cron time after now;
+// runs it missed while paused are dropped
+func (s *Store) Resume(name string, now time.Time) (*Schedule, error) {
+	return s.Update(name, func(schedule *Schedule) error {
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			return err
+		}
+		if schedule.Paused {
+			schedule.NextRun = cron.Next(now)
+		}
+		schedule.Paused = false
+		return nil
+	})
+}
+
+// Remove deletes the named schedule
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i := range schedules {
+		if schedules[i].Name == name {
+			return s.save(append(schedules[:i], schedules[i+1:]...))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// load reads the schedules; a missing file holds none
+func (s *Store) load() ([]Schedule, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, storeFile))
+	if os.IsNotExist(err) {
+		return []Schedule{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	var schedules []Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules: %w", err)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+	return schedules, nil
+}
+
+// save writes the schedules through a temporary file, so that other
+// processes never read a partial file
+func (s *Store) save(schedules []Schedule) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedules: %w", err)
+	}
+	path := filepath.Join(s.dir, storeFile)
+	tmp := fmt.Sprintf("%s.%s.tmp", path, uuid.New().String())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	return nil
+}
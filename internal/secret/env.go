@@ -0,0 +1,131 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Value is an environment entry: a plain value, or a reference to a secret
+// kept in a file, an environment variable of cortex or the local store.
+// Plain values are written as a scalar, references as a mapping with one of
+// fromFile, fromEnv or fromStore.
+type Value struct {
+	Value     string `yaml:"value,omitempty"`
+	FromFile  string `yaml:"fromFile,omitempty"`
+	FromEnv   string `yaml:"fromEnv,omitempty"`
+	FromStore string `yaml:"fromStore,omitempty"`
+}
+
+// UnmarshalYAML accepts a scalar or a reference mapping
+func (v *Value) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var plain string
+	if err := unmarshal(&plain); err == nil {
+		*v = Value{Value: plain}
+		return nil
+	}
+	type value Value
+	return unmarshal((*value)(v))
+}
+
+// MarshalYAML writes plain values as a scalar
+func (v Value) MarshalYAML() (interface{}, error) {
+	if !v.IsSecret() {
+		return v.Value, nil
+	}
+	type value Value
+	return value(v), nil
+}
+
+// IsSecret reports whether the value references a secret
+func (v Value) IsSecret() bool {
+	return v.FromFile != "" || v.FromEnv != "" || v.FromStore != ""
+}
+
+// validate checks that at most one source is given
+func (v Value) validate() error {
+	sources := 0
+	for _, source := range []string{v.FromFile, v.FromEnv, v.FromStore} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 || (sources == 1 && v.Value != "") {
+		return fmt.Errorf("only one of value, fromFile, fromEnv or fromStore can be set")
+	}
+	return nil
+}
+
+// Resolve returns the value, reading referenced secrets. Store references
+// use store, or the default store when it is nil.
+func (v Value) Resolve(store *Store) (string, error) {
+	switch {
+	case v.FromFile != "":
+		path := v.FromFile
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + path[1:]
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case v.FromEnv != "":
+		value, ok := os.LookupEnv(v.FromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", v.FromEnv)
+		}
+		return value, nil
+	case v.FromStore != "":
+		if store == nil {
+			var err error
+			if store, err = DefaultStore(); err != nil {
+				return "", err
+			}
+		}
+		return store.Get(v.FromStore)
+	}
+	return v.Value, nil
+}
+
+// Env is a set of environment entries by variable name
+type Env map[string]Value
+
+// Validate checks every entry
+func (env Env) Validate() error {
+	for _, name := range env.names() {
+		if err := env[name].validate(); err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Resolve returns the entries as KEY=VALUE pairs, sorted by name, and the
+// values that came from secrets
+func (env Env) Resolve(store *Store) ([]string, []string, error) {
+	var vars, secrets []string
+	for _, name := range env.names() {
+		value, err := env[name].Resolve(store)
+		if err != nil {
+			return nil, nil, fmt.Errorf("env %s: %w", name, err)
+		}
+		vars = append(vars, name+"="+value)
+		if env[name].IsSecret() {
+			secrets = append(secrets, value)
+		}
+	}
+	return vars, secrets, nil
+}
+
+func (env Env) names() []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
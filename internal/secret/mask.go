@@ -0,0 +1,114 @@
+package secret
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in output
+const Mask = "***"
+
+// MinMaskLength is the length below which values are not masked, since
+// masking them would garble unrelated output
+const MinMaskLength = 4
+
+// Masker replaces known secret values with Mask
+type Masker struct {
+	mu      sync.RWMutex
+	secrets []string // longest first, so overlapping secrets are fully masked
+}
+
+// NewMasker creates a masker for the given secrets
+func NewMasker(secrets ...string) *Masker {
+	m := &Masker{}
+	m.Add(secrets...)
+	return m
+}
+
+// Add registers secrets to mask
+func (m *Masker) Add(secrets ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) < MinMaskLength || m.known(secret) {
+			continue
+		}
+		m.secrets = append(m.secrets, secret)
+	}
+	sort.SliceStable(m.secrets, func(i, j int) bool {
+		return len(m.secrets[i]) > len(m.secrets[j])
+	})
+}
+
+func (m *Masker) known(secret string) bool {
+	for _, s := range m.secrets {
+		if s == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask returns s with every secret replaced
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, secret := range m.secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	return s
+}
+
+// Writer masks secrets in everything written through it. Output is passed on
+// a line at a time, so that a secret split across writes is still masked;
+// Flush passes on a trailing partial line.
+type Writer struct {
+	w       io.Writer
+	masker  *Masker
+	pending []byte
+	mu      sync.Mutex
+}
+
+// NewWriter creates a writer that masks the secrets of masker before writing to w
+func NewWriter(w io.Writer, masker *Masker) *Writer {
+	return &Writer{w: w, masker: masker}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	end := bytes.LastIndexByte(w.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	lines := string(w.pending[:end+1])
+	w.pending = append(w.pending[:0], w.pending[end+1:]...)
+	if _, err := io.WriteString(w.w, w.masker.Mask(lines)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a pending partial line
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	line := string(w.pending)
+	w.pending = w.pending[:0]
+	_, err := io.WriteString(w.w, w.masker.Mask(line))
+	return err
+}
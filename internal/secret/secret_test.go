@@ -0,0 +1,166 @@
+package secret_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/secret"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Secrets", func() {
+	var (
+		dir   string
+		store *secret.Store
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cortex-secret-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		store = secret.NewStore(dir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("Store", func() {
+		It("keeps secrets encrypted on disk", func() {
+			Expect(store.Set("kube-token", "s3cr3t-token")).To(Succeed())
+			Expect(store.Set("db.password", "hunter22")).To(Succeed())
+
+			Expect(store.Get("kube-token")).To(Equal("s3cr3t-token"))
+			Expect(store.List()).To(Equal([]string{"db.password", "kube-token"}))
+
+			data, err := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("s3cr3t-token"))
+
+			info, err := os.Stat(filepath.Join(dir, "secrets.key"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("reports missing and deleted secrets", func() {
+			_, err := store.Get("nope")
+			Expect(err).To(MatchError(secret.ErrNotFound))
+
+			Expect(store.Set("token", "value")).To(Succeed())
+			Expect(store.Delete("token")).To(Succeed())
+			_, err = store.Get("token")
+			Expect(err).To(MatchError("secret not found: token"))
+		})
+
+		It("derives the key from $CORTEX_SECRET_KEY", func() {
+			GinkgoT().Setenv(secret.KeyEnvVar, "passphrase")
+			Expect(store.Set("token", "value")).To(Succeed())
+			Expect(filepath.Join(dir, "secrets.key")).NotTo(BeAnExistingFile())
+
+			GinkgoT().Setenv(secret.KeyEnvVar, "other")
+			_, err := store.Get("token")
+			Expect(err).To(MatchError("failed to decrypt secret store (wrong key?)"))
+		})
+
+		It("creates the store directory when the key comes from $CORTEX_SECRET_KEY", func() {
+			GinkgoT().Setenv(secret.KeyEnvVar, "passphrase")
+			store = secret.NewStore(filepath.Join(dir, "home", ".cortex"))
+			Expect(store.Set("token", "value")).To(Succeed())
+			Expect(store.Get("token")).To(Equal("value"))
+		})
+
+		It("stretches the passphrase with a salt kept in the store", func() {
+			GinkgoT().Setenv(secret.KeyEnvVar, "passphrase")
+			Expect(store.Set("token", "value")).To(Succeed())
+			first, err := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(first)).To(HavePrefix("cortex-scrypt-v1\n"))
+
+			Expect(store.Set("token", "value")).To(Succeed())
+			second, err := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second[17:33]).NotTo(Equal(first[17:33]), "every save uses a new salt")
+		})
+
+		It("rejects stores without a passphrase salt", func() {
+			Expect(store.Set("token", "value")).To(Succeed())
+
+			GinkgoT().Setenv(secret.KeyEnvVar, "passphrase")
+			_, err := store.Get("token")
+			Expect(err).To(MatchError("secret store is not encrypted with a passphrase (unset CORTEX_SECRET_KEY to use the key file)"))
+			Expect(store.Set("other", "value")).To(MatchError(ContainSubstring("not encrypted with a passphrase")))
+		})
+
+		It("rejects invalid names", func() {
+			Expect(store.Set("my token", "value")).To(MatchError(`invalid secret name "my token"`))
+		})
+	})
+
+	Describe("Env", func() {
+		It("resolves plain values and secret references", func() {
+			Expect(store.Set("kube-token", "from-store")).To(Succeed())
+			tokenFile := filepath.Join(dir, "token")
+			Expect(os.WriteFile(tokenFile, []byte("from-file\n"), 0600)).To(Succeed())
+			GinkgoT().Setenv("CORTEX_TEST_TOKEN", "from-env")
+
+			var env secret.Env
+			Expect(yaml.Unmarshal([]byte(`
+REGION: eu
+PORT: 8080
+STORE_TOKEN:
+  fromStore: kube-token
+FILE_TOKEN:
+  fromFile: `+tokenFile+`
+ENV_TOKEN:
+  fromEnv: CORTEX_TEST_TOKEN
+`), &env)).To(Succeed())
+			Expect(env.Validate()).To(Succeed())
+
+			vars, secrets, err := env.Resolve(store)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vars).To(Equal([]string{
+				"ENV_TOKEN=from-env", "FILE_TOKEN=from-file", "PORT=8080", "REGION=eu", "STORE_TOKEN=from-store",
+			}))
+			Expect(secrets).To(Equal([]string{"from-env", "from-file", "from-store"}))
+		})
+
+		It("marshals plain values as scalars", func() {
+			data, err := yaml.Marshal(secret.Env{"REGION": {Value: "eu"}, "TOKEN": {FromStore: "token"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("REGION: eu\nTOKEN:\n  fromStore: token\n"))
+		})
+
+		It("reports unresolvable references", func() {
+			env := secret.Env{"TOKEN": {FromEnv: "CORTEX_TEST_UNSET"}}
+			_, _, err := env.Resolve(store)
+			Expect(err).To(MatchError("env TOKEN: environment variable CORTEX_TEST_UNSET is not set"))
+		})
+
+		It("rejects entries with several sources", func() {
+			env := secret.Env{"TOKEN": {FromEnv: "A", FromFile: "b"}}
+			Expect(env.Validate()).To(MatchError("env TOKEN: only one of value, fromFile, fromEnv or fromStore can be set"))
+		})
+	})
+
+	Describe("Masker", func() {
+		It("masks secrets split across writes", func() {
+			out := &bytes.Buffer{}
+			w := secret.NewWriter(out, secret.NewMasker("s3cr3t-token", "abc"))
+
+			w.Write([]byte("token: s3cr"))
+			Expect(out.String()).To(BeEmpty())
+			w.Write([]byte("3t-token\nabc "))
+			Expect(out.String()).To(Equal("token: ***\n"))
+			Expect(w.Flush()).To(Succeed())
+			Expect(out.String()).To(Equal("token: ***\nabc "), "values shorter than MinMaskLength are not masked")
+		})
+
+		It("masks the longest secret first", func() {
+			masker := secret.NewMasker("token", "token-suffix")
+			Expect(masker.Mask("token-suffix and token")).To(Equal("*** and ***"))
+		})
+	})
+})
@@ -0,0 +1,297 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	storeFile = "secrets.enc" // AES-GCM encrypted JSON object of name → value
+	keyFile   = "secrets.key" // hex encoded key, created on first use
+
+	// KeyEnvVar names an environment variable holding a passphrase the store
+	// key is derived from, used instead of the key file when set
+	KeyEnvVar = "CORTEX_SECRET_KEY"
+)
+
+// A store encrypted with a passphrase starts with saltHeader and the salt of
+// its key
+const (
+	saltHeader = "cortex-scrypt-v1\n"
+	saltSize   = 16
+)
+
+// scrypt cost parameters, the recommended ones for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ErrNotFound is returned for secrets that are not in the store
+var ErrNotFound = errors.New("secret not found")
+
+// Store is an encrypted local secret store
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a store kept in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store in ~/.cortex
+func DefaultStore() (*Store, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewStore(filepath.Join(home, ".cortex")), nil
+}
+
+// Set stores the value of the named secret
+func (s *Store) Set(name, value string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load(true)
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// Get returns the value of the named secret
+func (s *Store) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load(false)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Delete removes the named secret
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load(false)
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(secrets, name)
+	return s.save(secrets)
+}
+
+// List returns the names of the stored secrets, sorted
+func (s *Store) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load(false)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Values returns the values of all stored secrets, for masking
+func (s *Store) Values() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load(false)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(secrets))
+	for _, value := range secrets {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// load decrypts the store; a missing store is empty
+func (s *Store) load(createKey bool) (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dir, storeFile))
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret store: %w", err)
+	}
+
+	key, data, err := s.openKey(data, createKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secret store is corrupt")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret store (wrong key?)")
+	}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("secret store is corrupt: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts secrets into the store
+func (s *Store) save(secrets map[string]string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create secret store directory: %w", err)
+	}
+	key, header, err := s.sealKey()
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := append(header, gcm.Seal(nonce, nonce, plaintext, nil)...)
+	if err := os.WriteFile(filepath.Join(s.dir, storeFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	return nil
+}
+
+// sealKey returns the key to encrypt the store with and the header to write
+// before the ciphertext. A passphrase from $CORTEX_SECRET_KEY is stretched
+// with a new random salt, kept in the header; otherwise the key file is used
+// and created if need be.
+func (s *Store) sealKey() (key, header []byte, err error) {
+	passphrase := os.Getenv(KeyEnvVar)
+	if passphrase == "" {
+		key, err := s.fileKey(true)
+		return key, nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key, err = passphraseKey(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, append([]byte(saltHeader), salt...), nil
+}
+
+// openKey returns the key data was encrypted with and the ciphertext that
+// follows its header. Without a passphrase the key file is used, created if
+// create is set.
+func (s *Store) openKey(data []byte, create bool) (key, ciphertext []byte, err error) {
+	passphrase := os.Getenv(KeyEnvVar)
+	if passphrase == "" {
+		key, err := s.fileKey(create)
+		return key, data, err
+	}
+	if !bytes.HasPrefix(data, []byte(saltHeader)) {
+		return nil, nil, fmt.Errorf("secret store is not encrypted with a passphrase (unset %s to use the key file)", KeyEnvVar)
+	}
+	data = data[len(saltHeader):]
+	if len(data) < saltSize {
+		return nil, nil, fmt.Errorf("secret store is corrupt")
+	}
+	key, err = passphraseKey(passphrase, data[:saltSize])
+	return key, data[saltSize:], err
+}
+
+// passphraseKey derives a store key from a passphrase with scrypt
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secret key: %w", err)
+	}
+	return key, nil
+}
+
+// newGCM returns the AES-GCM cipher of key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fileKey returns the key in the key file, which is created if create is set
+func (s *Store) fileKey(create bool) ([]byte, error) {
+	path := filepath.Join(s.dir, keyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid secret key in %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read secret key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secret store directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write secret key: %w", err)
+	}
+	return key, nil
+}
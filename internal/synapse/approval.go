@@ -0,0 +1,216 @@
+package synapse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+)
+
+// Approval decisions recorded in history
+const (
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+	ApprovalReadOnly = "read-only" // mutate neurons are skipped in read-only mode
+)
+
+// mutateType is the neuron type that changes the system and needs approval
+const mutateType = "mutate"
+
+// ErrNotApproved is returned for mutate neurons that may not run
+var ErrNotApproved = errors.New("not approved")
+
+// ApprovalRequest asks whether a mutate neuron may run
+type ApprovalRequest struct {
+	Synapse     string
+	ExecutionID string
+	Neuron      string
+	Description string
+}
+
+// ApprovalDecision answers an ApprovalRequest
+type ApprovalDecision struct {
+	Approved bool
+	By       string // what decided, e.g. prompt or approve-all
+	Reason   string
+}
+
+// Approver decides whether mutate neurons may run
+type Approver interface {
+	Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+}
+
+// ApprovalRecord is an approval decision kept in the execution record
+type ApprovalRecord struct {
+	Neuron   string    `json:"neuron"`
+	Decision string    `json:"decision"` // "approved", "denied" or "read-only"
+	By       string    `json:"by,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type approveAll struct{}
+
+func (approveAll) Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	return ApprovalDecision{Approved: true, By: "approve-all"}, nil
+}
+
+// ApproveAll approves every mutate neuron, for automation
+var ApproveAll Approver = approveAll{}
+
+// approvalPrompt is printed on a line of its own, so that a process driving
+// cortex (such as the Web UI) can recognize it and answer on stdin
+const approvalPrompt = "Approval required: mutate neuron %s of synapse %s (execution %s). Approve? [y/N]"
+
+var approvalPromptPattern = regexp.MustCompile(`^Approval required: mutate neuron (\S+) of synapse (\S+) \(execution (\S+)\)\. Approve\? \[y/N\]$`)
+
+// ParseApprovalPrompt recognizes an approval prompt line and returns the
+// request it asks about
+func ParseApprovalPrompt(line string) (ApprovalRequest, bool) {
+	m := approvalPromptPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return ApprovalRequest{}, false
+	}
+	return ApprovalRequest{Neuron: m[1], Synapse: m[2], ExecutionID: m[3]}, true
+}
+
+// PromptApprover asks for approval on out and approves when the answer read
+// from in is y or yes. Anything else, or in being closed, denies. An answer
+// may name who gave it after the y or n, as in "y web"; it defaults to prompt.
+type PromptApprover struct {
+	in    io.Reader
+	out   io.Writer
+	lines chan string
+	once  sync.Once
+	mu    sync.Mutex // one prompt at a time
+}
+
+// NewPromptApprover creates an approver that prompts on out and reads from in
+func NewPromptApprover(in io.Reader, out io.Writer) *PromptApprover {
+	return &PromptApprover{in: in, out: out, lines: make(chan string)}
+}
+
+// Approve prompts for a decision
+func (p *PromptApprover) Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Reading happens in the background so that a cancelled execution does
+	// not stay blocked on the prompt
+	p.once.Do(func() {
+		go func() {
+			scanner := bufio.NewScanner(p.in)
+			for scanner.Scan() {
+				p.lines <- scanner.Text()
+			}
+			close(p.lines)
+		}()
+	})
+
+	if request.Description != "" {
+		fmt.Fprintf(p.out, "%s: %s\n", request.Neuron, request.Description)
+	}
+	fmt.Fprintf(p.out, approvalPrompt+"\n", request.Neuron, request.Synapse, request.ExecutionID)
+
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return ApprovalDecision{By: "prompt", Reason: "no answer"}, nil
+		}
+		fields := strings.Fields(line)
+		answer, by := "", "prompt"
+		if len(fields) > 0 {
+			answer = strings.ToLower(fields[0])
+		}
+		if len(fields) > 1 {
+			by = strings.Join(fields[1:], " ")
+		}
+		switch answer {
+		case "y", "yes":
+			return ApprovalDecision{Approved: true, By: by}, nil
+		case "n", "no":
+			return ApprovalDecision{By: by}, nil
+		default:
+			return ApprovalDecision{By: by, Reason: fmt.Sprintf("answered %q", answer)}, nil
+		}
+	case <-ctx.Done():
+		return ApprovalDecision{}, ctx.Err()
+	}
+}
+
+// approve checks whether the named neuron may run. Only mutate neurons need
+// approval; in read-only mode they never run. The decision is asked for once
+// per execution and recorded in it.
+func (e *Executor) approve(ctx context.Context, run *executionRun, name string) error {
+	neuronPath, err := resolveNeuronPath(run.synapse, run.synapseDir, name)
+	if err != nil {
+		return nil // reported when the neuron is executed
+	}
+	n, err := neuron.NewNeuron(e.logger, neuronPath)
+	if err != nil || n.Type != mutateType {
+		return nil
+	}
+
+	run.approvalMu.Lock()
+	defer run.approvalMu.Unlock()
+
+	decision, decided := run.approvals[name]
+	if !decided {
+		e.mu.Lock()
+		approver, readOnly := e.approver, e.readOnly
+		e.mu.Unlock()
+
+		record := ApprovalRecord{Neuron: name, Time: time.Now()}
+		switch {
+		case readOnly:
+			decision = ApprovalDecision{By: ApprovalReadOnly, Reason: "read-only mode"}
+			record.Decision = ApprovalReadOnly
+		case approver == nil:
+			return nil
+		default:
+			decision, err = approver.Approve(ctx, ApprovalRequest{
+				Synapse:     run.synapse.Name,
+				ExecutionID: run.record.ID,
+				Neuron:      name,
+				Description: n.Description,
+			})
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrNotApproved, err)
+			}
+			record.Decision = ApprovalDenied
+			if decision.Approved {
+				record.Decision = ApprovalApproved
+			}
+		}
+		record.By = decision.By
+		record.Reason = decision.Reason
+
+		if run.approvals == nil {
+			run.approvals = make(map[string]ApprovalDecision)
+		}
+		run.approvals[name] = decision
+		run.mu.Lock()
+		run.record.Approvals = append(run.record.Approvals, record)
+		run.mu.Unlock()
+
+		if decision.Approved {
+			e.printf(run, "Neuron %s approved by %s", name, decision.By)
+		}
+	}
+
+	if decision.Approved {
+		return nil
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = "denied by " + decision.By
+	}
+	return fmt.Errorf("%w: %s", ErrNotApproved, reason)
+}
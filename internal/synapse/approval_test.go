@@ -0,0 +1,215 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeApprover answers every request with approved and remembers the requests
+type fakeApprover struct {
+	mu       sync.Mutex
+	approved bool
+	requests []synapse.ApprovalRequest
+}
+
+func (f *fakeApprover) Approve(ctx context.Context, request synapse.ApprovalRequest) (synapse.ApprovalDecision, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	return synapse.ApprovalDecision{Approved: f.approved, By: "fake"}, nil
+}
+
+var _ = Describe("Approvals", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		history    *synapse.HistoryManager
+		out        *bytes.Buffer
+		approver   *fakeApprover
+	)
+
+	// writeMutateNeuron writes a neuron of type mutate
+	writeMutateNeuron := func(name, script string) {
+		writeNeuron(synapseDir, name, script)
+		config := filepath.Join(synapseDir, "neurons", name+".yml")
+		data, err := os.ReadFile(config)
+		Expect(err).NotTo(HaveOccurred())
+		data = []byte(strings.Replace(string(data), "type: check", "type: mutate\ndescription: restarts "+name, 1))
+		Expect(os.WriteFile(config, data, 0644)).To(Succeed())
+	}
+
+	execute := func(syn *synapse.Synapse) synapse.ExecutionRecord {
+		Expect(syn.Validate()).To(Succeed())
+		executor.Execute(context.Background(), syn, synapseDir)
+
+		records, err := history.GetHistory(syn.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		return records[0]
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-approval-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-approval-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		history = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+		approver = &fakeApprover{approved: true}
+		executor.SetApprover(approver)
+
+		writeNeuron(synapseDir, "check_service", "exit 1")
+		writeMutateNeuron("restart_service", "echo restarted")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("asks before running mutate neurons and records the decision", func() {
+		record := execute(&synapse.Synapse{
+			Name:    "restart",
+			Neurons: []synapse.NeuronRef{{Name: "check_service"}, {Name: "restart_service"}},
+		})
+
+		Expect(approver.requests).To(HaveLen(1), "check neurons need no approval")
+		Expect(approver.requests[0].Neuron).To(Equal("restart_service"))
+		Expect(approver.requests[0].Synapse).To(Equal("restart"))
+		Expect(approver.requests[0].ExecutionID).To(Equal(record.ID))
+		Expect(approver.requests[0].Description).To(Equal("restarts restart_service"))
+
+		Expect(resultByName(record, "restart_service").Stdout).To(Equal("restarted\n"))
+		Expect(record.Approvals).To(HaveLen(1))
+		Expect(record.Approvals[0].Neuron).To(Equal("restart_service"))
+		Expect(record.Approvals[0].Decision).To(Equal(synapse.ApprovalApproved))
+		Expect(record.Approvals[0].By).To(Equal("fake"))
+		Expect(out.String()).To(ContainSubstring("Neuron restart_service approved by fake"))
+	})
+
+	It("skips denied mutate neurons", func() {
+		approver.approved = false
+
+		record := execute(&synapse.Synapse{
+			Name:    "restart",
+			Neurons: []synapse.NeuronRef{{Name: "restart_service"}},
+		})
+
+		result := resultByName(record, "restart_service")
+		Expect(result.Status).To(Equal("skipped"))
+		Expect(result.Reason).To(Equal("not approved: denied by fake"))
+		Expect(result.Stdout).To(BeEmpty())
+		Expect(record.Approvals[0].Decision).To(Equal(synapse.ApprovalDenied))
+	})
+
+	It("asks once per neuron even when it is retried", func() {
+		writeMutateNeuron("flaky_restart", "exit 1")
+
+		execute(&synapse.Synapse{
+			Name: "restart",
+			Neurons: []synapse.NeuronRef{
+				{Name: "flaky_restart", Retry: &synapse.RetryPolicy{MaxAttempts: 3, InitialDelay: "10ms"}},
+			},
+		})
+
+		Expect(approver.requests).To(HaveLen(1))
+	})
+
+	It("gates fix neurons as well", func() {
+		approver.approved = false
+
+		record := execute(&synapse.Synapse{
+			Name:    "restart",
+			Neurons: []synapse.NeuronRef{{Name: "check_service", Fix: map[int]string{1: "restart_service"}}},
+		})
+
+		Expect(approver.requests).To(HaveLen(1))
+		Expect(record.Approvals[0].Neuron).To(Equal("restart_service"))
+		Expect(resultByName(record, "check_service").Fixes[0].Status).To(Equal("skipped"))
+	})
+
+	Describe("read-only mode", func() {
+		BeforeEach(func() {
+			executor.SetReadOnly(true)
+		})
+
+		It("skips mutate neurons without asking", func() {
+			record := execute(&synapse.Synapse{
+				Name:    "restart",
+				Neurons: []synapse.NeuronRef{{Name: "check_service"}, {Name: "restart_service"}},
+			})
+
+			Expect(approver.requests).To(BeEmpty())
+			Expect(record.ReadOnly).To(BeTrue())
+			Expect(resultByName(record, "check_service").Status).To(Equal("failed"))
+			Expect(resultByName(record, "restart_service").Status).To(Equal("skipped"))
+			Expect(resultByName(record, "restart_service").Reason).To(Equal("not approved: read-only mode"))
+			Expect(record.Approvals[0].Decision).To(Equal(synapse.ApprovalReadOnly))
+		})
+
+		It("plans mutate neurons as skipped", func() {
+			plan, err := executor.Plan(&synapse.Synapse{
+				Name:    "restart",
+				Neurons: []synapse.NeuronRef{{Name: "check_service"}, {Name: "restart_service"}},
+			}, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(plan.Neurons[0].Decision).To(Equal(synapse.PlanRun))
+			Expect(plan.Neurons[1].Decision).To(Equal(synapse.PlanSkip))
+			Expect(plan.Neurons[1].Reason).To(Equal("read-only mode"))
+		})
+	})
+
+	Describe("PromptApprover", func() {
+		request := synapse.ApprovalRequest{Synapse: "restart", ExecutionID: "abc", Neuron: "restart_service"}
+
+		DescribeTable("decides from the answer",
+			func(answer string, approved bool, by, reason string) {
+				prompt := &bytes.Buffer{}
+				decision, err := synapse.NewPromptApprover(strings.NewReader(answer), prompt).Approve(context.Background(), request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(decision).To(Equal(synapse.ApprovalDecision{Approved: approved, By: by, Reason: reason}))
+
+				parsed, ok := synapse.ParseApprovalPrompt(strings.TrimSpace(prompt.String()))
+				Expect(ok).To(BeTrue())
+				Expect(parsed).To(Equal(request))
+			},
+			Entry("yes", "y\n", true, "prompt", ""),
+			Entry("yes with who answered", "YES web\n", true, "web", ""),
+			Entry("no", "n\n", false, "prompt", ""),
+			Entry("anything else", "maybe\n", false, "prompt", `answered "maybe"`),
+			Entry("no answer", "", false, "prompt", "no answer"),
+		)
+
+		It("stops waiting when the execution is cancelled", func() {
+			reader, writer, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			defer writer.Close()
+			defer reader.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = synapse.NewPromptApprover(reader, &bytes.Buffer{}).Approve(ctx, request)
+			Expect(err).To(MatchError(context.Canceled))
+		})
+	})
+
+	It("does not recognize other lines as prompts", func() {
+		_, ok := synapse.ParseApprovalPrompt(fmt.Sprintf("Executing: %s", "restart_service"))
+		Expect(ok).To(BeFalse())
+	})
+})
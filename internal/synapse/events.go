@@ -0,0 +1,344 @@
+package synapse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/anoop2811/cortex/logger"
+)
+
+// EventType names the kind of an Event
+type EventType string
+
+const (
+	EventExecutionStarted  EventType = "execution_started"
+	EventNeuronStarted     EventType = "neuron_started"
+	EventOutputLine        EventType = "output_line"
+	EventRetryScheduled    EventType = "retry_scheduled"
+	EventNeuronFinished    EventType = "neuron_finished"
+	EventExecutionFinished EventType = "execution_finished"
+	EventMessage           EventType = "message"
+)
+
+// Output streams of an OutputLine
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Event is something that happened during an execution. It is one of
+// ExecutionStarted, NeuronStarted, OutputLine, RetryScheduled,
+// NeuronFinished, ExecutionFinished or Message.
+type Event interface {
+	EventType() EventType
+}
+
+// ExecutionStarted is emitted when a synapse starts running, including
+// synapses run as a step of another one
+type ExecutionStarted struct {
+	ExecutionID string            `json:"executionId"`
+	Synapse     string            `json:"synapse"`
+	Parent      string            `json:"parent,omitempty"`      // execution this one is a step of
+	ResumedFrom string            `json:"resumedFrom,omitempty"` // execution this one resumes
+	Inputs      map[string]string `json:"inputs,omitempty"`
+	Time        time.Time         `json:"time"`
+}
+
+// NeuronStarted is emitted for every attempt or poll of a neuron
+type NeuronStarted struct {
+	ExecutionID string    `json:"executionId"`
+	Neuron      string    `json:"neuron"`
+	Attempt     int       `json:"attempt,omitempty"`
+	Poll        int       `json:"poll,omitempty"` // set instead of Attempt for polled neurons
+	Time        time.Time `json:"time"`
+}
+
+// OutputLine is a line a running neuron wrote to stdout or stderr
+type OutputLine struct {
+	ExecutionID string    `json:"executionId"`
+	Neuron      string    `json:"neuron"`
+	Stream      string    `json:"stream"` // StreamStdout or StreamStderr
+	Line        string    `json:"line"`
+	Time        time.Time `json:"time"`
+}
+
+// RetryScheduled is emitted when a failed neuron is about to be retried
+type RetryScheduled struct {
+	ExecutionID string        `json:"executionId"`
+	Neuron      string        `json:"neuron"`
+	Attempt     int           `json:"attempt"` // the attempt that will run next
+	MaxAttempts int           `json:"maxAttempts"`
+	Delay       time.Duration `json:"delay"`
+	ExitCode    int           `json:"exitCode"` // of the failed attempt
+	Time        time.Time     `json:"time"`
+}
+
+// NeuronFinished is emitted with the final result of a neuron, including
+// skipped and reused ones
+type NeuronFinished struct {
+	ExecutionID string       `json:"executionId"`
+	Result      NeuronResult `json:"result"`
+	Time        time.Time    `json:"time"`
+}
+
+// ExecutionFinished is emitted with the record of a finished execution, and
+// for executions refused because their synapse was locked
+type ExecutionFinished struct {
+	ExecutionID string           `json:"executionId"`
+	Synapse     string           `json:"synapse"`
+	Parent      string           `json:"parent,omitempty"`
+	Record      *ExecutionRecord `json:"record"`
+	Time        time.Time        `json:"time"`
+}
+
+// Message is progress the executor reports in words, such as a neuron being
+// skipped or fixed
+type Message struct {
+	ExecutionID string    `json:"executionId,omitempty"`
+	Text        string    `json:"text"`
+	Time        time.Time `json:"time"`
+}
+
+func (ExecutionStarted) EventType() EventType  { return EventExecutionStarted }
+func (NeuronStarted) EventType() EventType     { return EventNeuronStarted }
+func (OutputLine) EventType() EventType        { return EventOutputLine }
+func (RetryScheduled) EventType() EventType    { return EventRetryScheduled }
+func (NeuronFinished) EventType() EventType    { return EventNeuronFinished }
+func (ExecutionFinished) EventType() EventType { return EventExecutionFinished }
+func (Message) EventType() EventType           { return EventMessage }
+
+// Observer receives the events of executions. Events are delivered one at a
+// time and in order, so OnEvent should return quickly.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc adapts a function to an Observer
+type ObserverFunc func(event Event)
+
+// OnEvent calls f
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// Subscribe adds an observer that receives the events of later executions
+func (e *Executor) Subscribe(observer Observer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers = append(e.observers, observer)
+}
+
+// emit masks secrets in event and delivers it to every observer
+func (e *Executor) emit(event Event) {
+	switch ev := event.(type) {
+	case OutputLine:
+		ev.Line = e.masker.Mask(ev.Line)
+		event = ev
+	case Message:
+		ev.Text = e.masker.Mask(ev.Text)
+		event = ev
+	case NeuronFinished:
+		maskResult(&ev.Result, e.masker)
+		event = ev
+	}
+
+	e.mu.Lock()
+	observers := e.observers
+	e.mu.Unlock()
+
+	e.emitMu.Lock()
+	defer e.emitMu.Unlock()
+	for _, observer := range observers {
+		observer.OnEvent(event)
+	}
+}
+
+// printf emits a Message for run, which is nil before an execution started
+func (e *Executor) printf(run *executionRun, format string, args ...interface{}) {
+	message := Message{Text: fmt.Sprintf(format, args...), Time: time.Now()}
+	if run != nil {
+		message.ExecutionID = run.record.ID
+	}
+	e.emit(message)
+}
+
+// EventText returns the line the console shows for event, or "" for events
+// it does not show
+func EventText(event Event) string {
+	switch ev := event.(type) {
+	case NeuronStarted:
+		if ev.Poll > 0 {
+			return fmt.Sprintf("Executing: %s (poll %d)", ev.Neuron, ev.Poll)
+		}
+		return fmt.Sprintf("Executing: %s", ev.Neuron)
+	case RetryScheduled:
+		return fmt.Sprintf("Retry attempt %d/%d for %s (waiting %v)", ev.Attempt, ev.MaxAttempts, ev.Neuron, ev.Delay)
+	case OutputLine:
+		return ev.Line
+	case Message:
+		return ev.Text
+	}
+	return ""
+}
+
+// ConsolePrinter prints the progress of executions as text
+type ConsolePrinter struct {
+	out io.Writer
+}
+
+// NewConsolePrinter creates a printer writing to out
+func NewConsolePrinter(out io.Writer) *ConsolePrinter {
+	return &ConsolePrinter{out: out}
+}
+
+// OnEvent prints the text of event, if it has any
+func (p *ConsolePrinter) OnEvent(event Event) {
+	// Output lines are printed even when empty
+	text := EventText(event)
+	if _, ok := event.(OutputLine); ok || text != "" {
+		fmt.Fprintln(p.out, text)
+	}
+}
+
+// HistoryWriter records finished executions in history. Synapses run as a
+// step of another one are kept in the record of that one instead.
+type HistoryWriter struct {
+	history *HistoryManager
+	logger  *log.StandardLogger
+}
+
+// NewHistoryWriter creates a writer recording executions with history
+func NewHistoryWriter(history *HistoryManager, logger *log.StandardLogger) *HistoryWriter {
+	return &HistoryWriter{history: history, logger: logger}
+}
+
+// OnEvent saves the record of top-level ExecutionFinished events
+func (w *HistoryWriter) OnEvent(event Event) {
+	finished, ok := event.(ExecutionFinished)
+	if !ok || finished.Parent != "" || finished.Record == nil {
+		return
+	}
+	if err := w.history.AddExecution(finished.Synapse, *finished.Record); err != nil {
+		w.logger.Errorf(err, "Failed to save execution history")
+	}
+}
+
+// eventEnvelope is the JSON form of an event
+type eventEnvelope struct {
+	Type  EventType       `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+// EncodeEvent returns the JSON form of event, on a single line
+func EncodeEvent(event Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(eventEnvelope{Type: event.EventType(), Event: data})
+}
+
+// DecodeEvent parses an event encoded by EncodeEvent
+func DecodeEvent(data []byte) (Event, error) {
+	var envelope eventEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	var err error
+	switch envelope.Type {
+	case EventExecutionStarted:
+		var ev ExecutionStarted
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventNeuronStarted:
+		var ev NeuronStarted
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventOutputLine:
+		var ev OutputLine
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventRetryScheduled:
+		var ev RetryScheduled
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventNeuronFinished:
+		var ev NeuronFinished
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventExecutionFinished:
+		var ev ExecutionFinished
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	case EventMessage:
+		var ev Message
+		err = json.Unmarshal(envelope.Event, &ev)
+		event = ev
+	default:
+		return nil, fmt.Errorf("unknown event type %q", envelope.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", envelope.Type, err)
+	}
+	return event, nil
+}
+
+// EventEncoder writes every event as a line of JSON, for processes that
+// drive cortex such as the Web UI
+type EventEncoder struct {
+	out io.Writer
+}
+
+// NewEventEncoder creates an encoder writing to out
+func NewEventEncoder(out io.Writer) *EventEncoder {
+	return &EventEncoder{out: out}
+}
+
+// OnEvent writes event as a line of JSON
+func (enc *EventEncoder) OnEvent(event Event) {
+	data, err := EncodeEvent(event)
+	if err != nil {
+		return
+	}
+	enc.out.Write(append(data, '\n'))
+}
+
+// outputLines turns the live output of a neuron stream into OutputLine events
+type outputLines struct {
+	emit func(line string)
+	buf  []byte
+	mu   sync.Mutex
+}
+
+func (o *outputLines) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		o.emit(string(o.buf[:i]))
+		o.buf = o.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits a final line that did not end in a newline
+func (o *outputLines) Flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.buf) > 0 {
+		o.emit(string(o.buf))
+		o.buf = nil
+	}
+}
@@ -0,0 +1,221 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/secret"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// eventRecorder keeps every event it observes
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []synapse.Event
+}
+
+func (r *eventRecorder) OnEvent(event synapse.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// types lists the types of the recorded events, leaving out output lines and
+// messages
+func (r *eventRecorder) types() []synapse.EventType {
+	var types []synapse.EventType
+	for _, event := range r.events {
+		switch event.EventType() {
+		case synapse.EventOutputLine, synapse.EventMessage:
+			continue
+		}
+		types = append(types, event.EventType())
+	}
+	return types
+}
+
+func (r *eventRecorder) outputLines() []synapse.OutputLine {
+	var lines []synapse.OutputLine
+	for _, event := range r.events {
+		if line, ok := event.(synapse.OutputLine); ok && !strings.HasPrefix(line.Line, "===>") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var _ = Describe("Events", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		history    *synapse.HistoryManager
+		out        *bytes.Buffer
+		recorder   *eventRecorder
+	)
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-events-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-events-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		history = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+		recorder = &eventRecorder{}
+		executor.Subscribe(recorder)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("reports the progress of an execution", func() {
+		attempts := filepath.Join(synapseDir, "attempts")
+		writeNeuron(synapseDir, "flaky", `n=$(( $(cat `+attempts+` 2>/dev/null || echo 0) + 1 )); echo $n > `+attempts+`
+echo "attempt $n"; echo "oops" >&2; [ $n -gt 1 ]`)
+		writeNeuron(synapseDir, "skipped", "echo never")
+
+		record, err := executor.Run(context.Background(), &synapse.Synapse{
+			Name: "events",
+			Neurons: []synapse.NeuronRef{
+				{Name: "flaky", Retry: &synapse.RetryPolicy{MaxAttempts: 2, InitialDelay: "10ms"}},
+				{Name: "skipped", Condition: "false"},
+			},
+		}, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(recorder.types()).To(Equal([]synapse.EventType{
+			synapse.EventExecutionStarted,
+			synapse.EventNeuronStarted,
+			synapse.EventRetryScheduled,
+			synapse.EventNeuronStarted,
+			synapse.EventNeuronFinished,
+			synapse.EventNeuronFinished,
+			synapse.EventExecutionFinished,
+		}))
+
+		started := recorder.events[0].(synapse.ExecutionStarted)
+		Expect(started.ExecutionID).To(Equal(record.ID))
+		Expect(started.Synapse).To(Equal("events"))
+
+		var retry synapse.RetryScheduled
+		var finished []synapse.NeuronFinished
+		for _, event := range recorder.events {
+			switch ev := event.(type) {
+			case synapse.RetryScheduled:
+				retry = ev
+			case synapse.NeuronFinished:
+				finished = append(finished, ev)
+			}
+		}
+		Expect(retry.Neuron).To(Equal("flaky"))
+		Expect(retry.Attempt).To(Equal(2))
+		Expect(retry.MaxAttempts).To(Equal(2))
+		Expect(retry.Delay).To(Equal(10 * time.Millisecond))
+		Expect(retry.ExitCode).To(Equal(1))
+		Expect(finished[0].Result.Status).To(Equal("success"))
+		Expect(finished[1].Result.Status).To(Equal("skipped"))
+
+		var lines []string
+		for _, line := range recorder.outputLines() {
+			Expect(line.ExecutionID).To(Equal(record.ID))
+			Expect(line.Neuron).To(Equal("flaky"))
+			lines = append(lines, line.Stream+": "+line.Line)
+		}
+		Expect(lines).To(ConsistOf("stdout: attempt 1", "stderr: oops", "stdout: attempt 2", "stderr: oops"))
+
+		last := recorder.events[len(recorder.events)-1].(synapse.ExecutionFinished)
+		Expect(last.Record).To(Equal(record))
+	})
+
+	It("prints the events on the console", func() {
+		writeNeuron(synapseDir, "partial", "printf 'no newline'")
+
+		executor.Execute(context.Background(), &synapse.Synapse{
+			Name:    "console",
+			Neurons: []synapse.NeuronRef{{Name: "partial"}},
+		}, synapseDir)
+
+		Expect(out.String()).To(ContainSubstring("Executing: partial\n"))
+		Expect(out.String()).To(ContainSubstring("no newline\n"))
+	})
+
+	It("records only top-level executions in history", func() {
+		subDir := filepath.Join(synapseDir, "sub")
+		writeNeuron(subDir, "inner", "echo inner")
+		Expect(os.WriteFile(filepath.Join(subDir, synapse.ConfigFile), []byte("apiVersion: cortex/v1\nname: sub\nneurons: [inner]\n"), 0644)).To(Succeed())
+
+		executor.Execute(context.Background(), &synapse.Synapse{
+			Name:    "parent",
+			Neurons: []synapse.NeuronRef{{Name: "step", Synapse: "sub"}},
+		}, synapseDir)
+
+		var parents []string
+		for _, event := range recorder.events {
+			if finished, ok := event.(synapse.ExecutionFinished); ok {
+				parents = append(parents, finished.Synapse+":"+finished.Parent)
+			}
+		}
+		Expect(parents).To(HaveLen(2))
+		Expect(parents[0]).To(HavePrefix("sub:"))
+		Expect(parents[0]).NotTo(Equal("sub:"))
+		Expect(parents[1]).To(Equal("parent:"))
+
+		records, err := history.GetHistory("sub")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(BeEmpty())
+		records, err = history.GetHistory("parent")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+	})
+
+	It("masks secrets in events", func() {
+		tokenFile := filepath.Join(synapseDir, "token")
+		Expect(os.WriteFile(tokenFile, []byte("hunter22\n"), 0600)).To(Succeed())
+		writeNeuron(synapseDir, "leaky", `echo "token is $TOKEN"`)
+
+		executor.Execute(context.Background(), &synapse.Synapse{
+			Name:    "secrets",
+			Env:     secret.Env{"TOKEN": {FromFile: tokenFile}},
+			Neurons: []synapse.NeuronRef{{Name: "leaky"}},
+		}, synapseDir)
+
+		Expect(recorder.outputLines()).To(ContainElement(HaveField("Line", "token is ***")))
+	})
+
+	DescribeTable("encodes events as JSON lines",
+		func(event synapse.Event) {
+			data, err := synapse.EncodeEvent(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("\n"))
+
+			decoded, err := synapse.DecodeEvent(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(Equal(event))
+		},
+		Entry("ExecutionStarted", synapse.ExecutionStarted{ExecutionID: "1", Synapse: "s", Inputs: map[string]string{"env": "prod"}}),
+		Entry("NeuronStarted", synapse.NeuronStarted{ExecutionID: "1", Neuron: "n", Attempt: 2}),
+		Entry("OutputLine", synapse.OutputLine{ExecutionID: "1", Neuron: "n", Stream: synapse.StreamStderr, Line: "oops"}),
+		Entry("RetryScheduled", synapse.RetryScheduled{ExecutionID: "1", Neuron: "n", Attempt: 2, MaxAttempts: 3, Delay: time.Second, ExitCode: 1}),
+		Entry("NeuronFinished", synapse.NeuronFinished{ExecutionID: "1", Result: synapse.NeuronResult{Name: "n", Status: "failed", ExitCode: 3}}),
+		Entry("ExecutionFinished", synapse.ExecutionFinished{ExecutionID: "1", Synapse: "s", Record: &synapse.ExecutionRecord{ID: "1", Status: "success"}}),
+		Entry("Message", synapse.Message{ExecutionID: "1", Text: "Skipping: n (condition not met)"}),
+	)
+
+	It("rejects unknown events", func() {
+		_, err := synapse.DecodeEvent([]byte(`{"type":"unknown","event":{}}`))
+		Expect(err).To(MatchError(`unknown event type "unknown"`))
+	})
+})
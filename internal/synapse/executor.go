@@ -2,14 +2,18 @@ package synapse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/secret"
 	log "github.com/anoop2811/cortex/logger"
 	"github.com/google/uuid"
 )
@@ -20,33 +24,261 @@ type Executor struct {
 	historyManager *HistoryManager
 	neuronCache    map[string]*neuron.Neuron
 	environment    map[string]string
-	out            io.Writer
+	maxOutputBytes int
+	console        *secret.Writer // masks secrets in everything printed
+	masker         *secret.Masker // secrets resolved for any neuron so far
+	secretStore    *secret.Store  // resolves fromStore env entries, nil for the default store
+	approver       Approver       // asked before mutate neurons run, nil to run them unasked
+	readOnly       bool           // skip mutate neurons
+	locks          *LockManager   // locks executed synapses, nil for no locking
+	lockPolicy     LockPolicy
+	schedule       string // schedule that started the executions, if any
+	observers      []Observer
 	mu             sync.Mutex
+	emitMu         sync.Mutex // delivers one event at a time
 }
 
-// NewExecutor creates a new synapse executor
+// NewExecutor creates a new synapse executor. Progress is printed to out and
+// executions are recorded with historyManager unless it is nil; more
+// observers can be added with Subscribe.
 func NewExecutor(logger *log.StandardLogger, historyManager *HistoryManager, out io.Writer) *Executor {
 	if out == nil {
 		out = os.Stdout
 	}
-	return &Executor{
+	masker := secret.NewMasker()
+	console := secret.NewWriter(out, masker)
+	e := &Executor{
 		logger:         logger,
 		historyManager: historyManager,
 		neuronCache:    make(map[string]*neuron.Neuron),
 		environment:    make(map[string]string),
-		out:            out,
+		console:        console,
+		masker:         masker,
 	}
+	e.Subscribe(NewConsolePrinter(console))
+	if historyManager != nil {
+		e.Subscribe(NewHistoryWriter(historyManager, logger))
+	}
+	return e
 }
 
-// SetEnvironment sets environment variables for conditional evaluation
+// SetSecretStore sets the store fromStore env entries are read from
+func (e *Executor) SetSecretStore(store *secret.Store) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.secretStore = store
+}
+
+// SetEnvironment sets the inputs of executed synapses. They are checked
+// against the inputs a synapse declares, used in conditions and exported to
+// every neuron as CORTEX_INPUT_<NAME>.
 func (e *Executor) SetEnvironment(env map[string]string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.environment = env
 }
 
+// SetApprover sets the approver asked before each mutate neuron runs
+func (e *Executor) SetApprover(approver Approver) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.approver = approver
+}
+
+// SetReadOnly makes executions skip every mutate neuron
+func (e *Executor) SetReadOnly(readOnly bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.readOnly = readOnly
+}
+
+// SetLocks makes executions hold the lock of their synapse in locks, so that
+// it is not executed twice at once. policy decides what happens when it is
+// already locked.
+func (e *Executor) SetLocks(locks *LockManager, policy LockPolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.locks = locks
+	e.lockPolicy = policy
+}
+
+// SetSchedule records executions as started by the named schedule
+func (e *Executor) SetSchedule(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.schedule = name
+}
+
+// SetMaxOutputBytes caps the stdout/stderr captured per neuron stream.
+// Zero uses neuron.DefaultMaxOutputBytes, a negative value disables the cap.
+func (e *Executor) SetMaxOutputBytes(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxOutputBytes = n
+}
+
+// executionRun holds the state of a single synapse execution
+type executionRun struct {
+	synapse    *Synapse
+	synapseDir string
+	record     *ExecutionRecord
+	limits     *neuron.Limits    // applied to every neuron process
+	inputs     map[string]string // values conditions are evaluated against
+	env        []string          // resolved env of the synapse and its parents
+	// parent is the run of the synapse that references this one, if nested
+	parent *executionRun
+	// reuse holds successful results carried over from a resumed execution
+	reuse map[string]NeuronResult
+	// completed lists neurons in the order they finished, for rollback
+	completed []string
+	mu        sync.Mutex // guards record.Rollbacks and record.Approvals
+	// approvals holds the decision for each mutate neuron asked about
+	approvals  map[string]ApprovalDecision
+	approvalMu sync.Mutex // held while asking, so each neuron is asked once
+	// slots bounds the neuron processes of the execution, matrix items included
+	slots chan struct{}
+}
+
+// acquire waits for a free slot of the execution and returns its release
+func (run *executionRun) acquire() func() {
+	run.slots <- struct{}{}
+	return func() { <-run.slots }
+}
+
 // Execute executes a synapse workflow
 func (e *Executor) Execute(ctx context.Context, synapse *Synapse, synapseDir string) error {
+	_, err := e.Run(ctx, synapse, synapseDir)
+	return err
+}
+
+// Run executes a synapse workflow like Execute and also returns the record
+// of the execution, which tells whether any neuron failed
+func (e *Executor) Run(ctx context.Context, synapse *Synapse, synapseDir string) (*ExecutionRecord, error) {
+	run := &executionRun{
+		synapse:    synapse,
+		synapseDir: synapseDir,
+		record:     &ExecutionRecord{},
+	}
+	err := e.execute(ctx, run)
+	return run.record, err
+}
+
+// Resume re-executes a previous execution of the synapse, loaded from history.
+// Neurons that succeeded in that execution are not run again: their results
+// (including outputs) are reused, and only failed, skipped or unrun neurons
+// are executed. The inputs of that execution are used again unless set
+// explicitly; masked values must be set again. The new execution is recorded
+// with a link to the original.
+func (e *Executor) Resume(ctx context.Context, synapse *Synapse, synapseDir string, executionID string) error {
+	if e.historyManager == nil {
+		return fmt.Errorf("cannot resume without execution history")
+	}
+
+	previous, err := e.historyManager.GetExecutionLogs(synapse.Name, executionID)
+	if err != nil {
+		return fmt.Errorf("failed to load execution %s: %w", executionID, err)
+	}
+
+	reuse := make(map[string]NeuronResult)
+	for _, result := range previous.NeuronResults {
+		if result.Status == "success" {
+			reuse[result.Name] = result
+		}
+	}
+
+	inputs := make(map[string]string, len(previous.Inputs))
+	for k, v := range synapse.declaredInputs(previous.Inputs) {
+		if !strings.Contains(v, secret.Mask) {
+			inputs[k] = v
+		}
+	}
+
+	e.printf(nil, "Resuming execution %s (%d neurons already succeeded)", previous.ID, len(reuse))
+	return e.execute(ctx, &executionRun{
+		synapse:    synapse,
+		synapseDir: synapseDir,
+		record:     &ExecutionRecord{ResumedFrom: previous.ID},
+		inputs:     inputs,
+		reuse:      reuse,
+	})
+}
+
+// execute runs a top-level synapse execution. Inputs already set on run are
+// defaults the environment of the executor overrides.
+func (e *Executor) execute(ctx context.Context, run *executionRun) error {
+	e.mu.Lock()
+	environment := make(map[string]string, len(run.inputs)+len(e.environment))
+	for k, v := range run.inputs {
+		environment[k] = v
+	}
+	for k, v := range e.environment {
+		environment[k] = v
+	}
+	e.mu.Unlock()
+
+	inputs, err := run.synapse.ResolveInputs(environment)
+	if err != nil {
+		return err
+	}
+	run.inputs = inputs
+
+	e.mu.Lock()
+	locks, policy := e.locks, e.lockPolicy
+	e.mu.Unlock()
+	if locks != nil {
+		lock, contention, err := locks.Acquire(ctx, run.synapse.Name, policy, func(holder LockHolder) {
+			e.printf(nil, "Waiting for %s: locked by %s", run.synapse.Name, holder)
+		})
+		if err != nil {
+			e.recordLocked(run, contention, err)
+			e.console.Flush()
+			return err
+		}
+		defer lock.Release()
+		run.record.Lock = contention
+	}
+
+	executionErr := e.executeRun(ctx, run)
+	e.console.Flush()
+	return executionErr
+}
+
+// recordLocked records an execution that did not run because its synapse
+// was locked
+func (e *Executor) recordLocked(run *executionRun, contention *LockContention, err error) {
+	record := run.record
+	record.ID = uuid.New().String()
+	record.SynapseName = run.synapse.Name
+	record.Timestamp = time.Now()
+	record.Status = "failed"
+	if errors.Is(err, ErrLocked) {
+		record.Status = "locked"
+	}
+	record.ErrorMessage = err.Error()
+	record.Inputs = run.inputs
+	record.Lock = contention
+	record.NeuronResults = []NeuronResult{}
+	e.mu.Lock()
+	record.Schedule = e.schedule
+	e.mu.Unlock()
+	if contention != nil {
+		record.Duration = contention.Waited
+	}
+
+	maskRecord(record, e.masker)
+	e.emit(ExecutionFinished{
+		ExecutionID: record.ID,
+		Synapse:     record.SynapseName,
+		Record:      record,
+		Time:        time.Now(),
+	})
+}
+
+// executeRun runs the neurons of a synapse and fills in the execution record.
+// Once the record is initialized, ExecutionStarted and ExecutionFinished are
+// emitted around the run.
+func (e *Executor) executeRun(ctx context.Context, run *executionRun) error {
+	synapse := run.synapse
 	executionID := uuid.New().String()
 	startTime := time.Now()
 
@@ -65,23 +297,71 @@ func (e *Executor) Execute(ctx context.Context, synapse *Synapse, synapseDir str
 		}
 	}
 
-	// Create execution record
-	record := ExecutionRecord{
-		ID:            executionID,
-		SynapseName:   synapse.Name,
-		Timestamp:     startTime,
-		Status:        "running",
-		NeuronResults: []NeuronResult{},
+	limits, err := synapse.Resources.NeuronLimits()
+	if err != nil {
+		return err
 	}
+	if limits == nil && run.parent != nil {
+		limits = run.parent.limits
+	}
+	run.limits = limits
+	run.slots = make(chan struct{}, synapse.maxConcurrency())
+
+	e.mu.Lock()
+	store := e.secretStore
+	e.mu.Unlock()
+	env, secrets, err := synapse.Env.Resolve(store)
+	if err != nil {
+		return fmt.Errorf("failed to resolve env: %w", err)
+	}
+	e.masker.Add(secrets...)
+	if run.parent != nil {
+		env = append(append([]string{}, run.parent.env...), env...)
+	}
+	run.env = env
+
+	// Initialize execution record
+	record := run.record
+	record.ID = executionID
+	record.SynapseName = synapse.Name
+	record.Timestamp = startTime
+	record.Status = "running"
+	record.Inputs = run.inputs
+	e.mu.Lock()
+	record.ReadOnly = e.readOnly
+	if run.parent == nil {
+		record.Schedule = e.schedule
+	}
+	e.mu.Unlock()
+	record.NeuronResults = []NeuronResult{}
+
+	var parentID string
+	if run.parent != nil {
+		parentID = run.parent.record.ID
+	}
+	e.emit(ExecutionStarted{
+		ExecutionID: executionID,
+		Synapse:     synapse.Name,
+		Parent:      parentID,
+		ResumedFrom: record.ResumedFrom,
+		Inputs:      record.Inputs,
+		Time:        startTime,
+	})
 
 	var executionErr error
 
 	// Execute based on mode
 	if synapse.Execution == ExecutionParallel {
-		executionErr = e.executeParallel(ctx, synapse, synapseDir, &record)
+		executionErr = e.executeParallel(ctx, run)
 	} else {
-		executionErr = e.executeSequential(ctx, synapse, synapseDir, &record)
+		executionErr = e.executeSequential(ctx, run)
+	}
+
+	// Compensate for completed neurons if the execution failed
+	if executionErr != nil || hasFailure(record.NeuronResults) {
+		e.rollback(ctx, run)
 	}
+	record.RollbackStatus = rollbackStatus(record.Rollbacks)
 
 	// Finalize execution record
 	record.Duration = time.Since(startTime)
@@ -90,264 +370,352 @@ func (e *Executor) Execute(ctx context.Context, synapse *Synapse, synapseDir str
 		record.ErrorMessage = executionErr.Error()
 	} else {
 		// Check if any neurons failed
-		allSuccess := true
-		for _, nr := range record.NeuronResults {
-			if nr.Status == "failed" {
-				allSuccess = false
-				break
-			}
-		}
-		if allSuccess {
-			record.Status = "success"
-		} else {
+		if hasFailure(record.NeuronResults) {
 			record.Status = "partial"
+		} else {
+			record.Status = "success"
 		}
 	}
 
-	// Save execution history
-	if e.historyManager != nil {
-		if err := e.historyManager.AddExecution(synapse.Name, record); err != nil {
-			e.logger.Errorf(err, "Failed to save execution history")
-		}
-	}
+	maskRecord(record, e.masker)
+	e.emit(ExecutionFinished{
+		ExecutionID: executionID,
+		Synapse:     synapse.Name,
+		Parent:      parentID,
+		Record:      record,
+		Time:        time.Now(),
+	})
 
 	return executionErr
 }
 
 // executeSequential executes neurons sequentially
-func (e *Executor) executeSequential(ctx context.Context, synapse *Synapse, synapseDir string, record *ExecutionRecord) error {
-	for _, neuronRef := range synapse.Neurons {
+func (e *Executor) executeSequential(ctx context.Context, run *executionRun) error {
+	results := make(map[string]NeuronResult)
+
+	for _, neuronRef := range run.synapse.Neurons {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("execution timeout exceeded")
+			return fmt.Errorf("%s", contextErrorMessage(ctx))
 		default:
 		}
 
-		// Check condition
-		if !e.evaluateCondition(neuronRef.Condition) {
-			fmt.Fprintf(e.out, "Skipping: %s (condition not met)\n", neuronRef.Name)
-			record.NeuronResults = append(record.NeuronResults, NeuronResult{
-				Name:   neuronRef.Name,
-				Status: "skipped",
-			})
-			continue
-		}
-
-		// Execute neuron with retry
-		result := e.executeNeuronWithRetry(ctx, neuronRef, synapseDir)
-		record.NeuronResults = append(record.NeuronResults, result)
-
-		// Handle failure
-		if result.Status == "failed" {
-			// Execute rollback neurons if specified
-			if len(neuronRef.OnFailure) > 0 {
-				fmt.Fprintf(e.out, "Executing rollback for %s\n", neuronRef.Name)
-				for _, rollbackNeuron := range neuronRef.OnFailure {
-					e.executeNeuronByName(ctx, rollbackNeuron, synapseDir)
-				}
-			}
+		result := e.runNeuron(ctx, run, neuronRef, results)
+		run.record.NeuronResults = append(run.record.NeuronResults, result)
+		run.completed = append(run.completed, neuronRef.Name)
+		results[neuronRef.Name] = result
 
-			// Stop on error if configured
-			if synapse.StopOnError {
-				fmt.Fprintf(e.out, "Stopping execution due to error in %s\n", neuronRef.Name)
-				return fmt.Errorf("neuron %s failed: %s", neuronRef.Name, result.Error)
-			}
+		// Stop on error if configured
+		if isFailure(result.Status) && run.synapse.StopOnError {
+			e.printf(run, "Stopping execution due to error in %s", neuronRef.Name)
+			return fmt.Errorf("neuron %s failed: %s", neuronRef.Name, result.Error)
 		}
 	}
 
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s", contextErrorMessage(ctx))
+	}
 	return nil
 }
 
-// executeParallel executes neurons in parallel
-func (e *Executor) executeParallel(ctx context.Context, synapse *Synapse, synapseDir string, record *ExecutionRecord) error {
-	fmt.Fprintf(e.out, "Executing in parallel (max concurrency: %d)\n", synapse.MaxConcurrency)
+// runNeuron runs a neuron of the synapse and emits NeuronFinished with its
+// result
+func (e *Executor) runNeuron(ctx context.Context, run *executionRun, neuronRef NeuronRef, upstream map[string]NeuronResult) NeuronResult {
+	result := e.runNeuronSteps(ctx, run, neuronRef, upstream)
+	e.emit(NeuronFinished{ExecutionID: run.record.ID, Result: result, Time: time.Now()})
+	return result
+}
 
-	// Build dependency graph for topological sort
-	readyQueue := []NeuronRef{}
-	waiting := make(map[string]NeuronRef)
-	dependencies := make(map[string]map[string]bool)
-	completed := make(map[string]bool)
+// runNeuronSteps evaluates a neuron's condition, executes it with its retry
+// policy, remediates it with its fix neurons and runs its onFailure rollback
+// neurons if it still fails. upstream holds the results of the
+// neurons that completed before this one was started and is not modified.
+func (e *Executor) runNeuronSteps(ctx context.Context, run *executionRun, neuronRef NeuronRef, upstream map[string]NeuronResult) NeuronResult {
+	if previous, ok := run.reuse[neuronRef.Name]; ok {
+		e.printf(run, "Reusing: %s (succeeded in execution %s)", neuronRef.Name, run.record.ResumedFrom)
+		previous.Reused = true
+		return previous
+	}
 
-	// Initialize
-	for _, neuronRef := range synapse.Neurons {
-		if len(neuronRef.DependsOn) == 0 {
-			readyQueue = append(readyQueue, neuronRef)
-		} else {
-			waiting[neuronRef.Name] = neuronRef
-			dependencies[neuronRef.Name] = make(map[string]bool)
-			for _, dep := range neuronRef.DependsOn {
-				dependencies[neuronRef.Name][dep] = true
-			}
+	if reason := triggerSkipReason(neuronRef, upstream); reason != "" {
+		e.printf(run, "Skipping: %s (%s)", neuronRef.Name, reason)
+		return NeuronResult{
+			Name:   neuronRef.Name,
+			Status: "skipped",
+			Reason: reason,
 		}
 	}
 
-	// Concurrency control
-	maxConcurrency := synapse.MaxConcurrency
-	if maxConcurrency <= 0 {
-		maxConcurrency = 5 // default
-	}
-	semaphore := make(chan struct{}, maxConcurrency)
-
-	var wg sync.WaitGroup
-	var resultsMu sync.Mutex
-	results := []NeuronResult{}
-
-	// Process neurons
-	for len(readyQueue) > 0 || len(waiting) > 0 {
-		// Execute ready neurons
-		for len(readyQueue) > 0 {
-			neuronRef := readyQueue[0]
-			readyQueue = readyQueue[1:]
-
-			wg.Add(1)
-			go func(nr NeuronRef) {
-				defer wg.Done()
-
-				// Acquire semaphore
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-
-				// Check condition
-				if !e.evaluateCondition(nr.Condition) {
-					fmt.Fprintf(e.out, "Skipping: %s (condition not met)\n", nr.Name)
-					resultsMu.Lock()
-					results = append(results, NeuronResult{
-						Name:   nr.Name,
-						Status: "skipped",
-					})
-					completed[nr.Name] = true
-					resultsMu.Unlock()
-					return
-				}
+	shouldRun, err := e.evaluateCondition(neuronRef.Condition, run.inputs, upstream)
+	if err != nil {
+		e.printf(run, "Condition error for %s: %v", neuronRef.Name, err)
+		return NeuronResult{
+			Name:   neuronRef.Name,
+			Status: "failed",
+			Error:  fmt.Sprintf("condition evaluation failed: %v", err),
+		}
+	}
+	if !shouldRun {
+		e.printf(run, "Skipping: %s (condition not met)", neuronRef.Name)
+		return NeuronResult{
+			Name:   neuronRef.Name,
+			Status: "skipped",
+			Reason: "condition not met",
+		}
+	}
 
-				// Execute neuron
-				result := e.executeNeuronWithRetry(ctx, nr, synapseDir)
+	var result NeuronResult
+	if neuronRef.Synapse != "" {
+		result = e.runSubSynapse(ctx, run, neuronRef)
+	} else if neuronRef.Matrix != nil {
+		result = e.runMatrix(ctx, run, neuronRef, upstream)
+	} else {
+		result = e.executeNeuronWithRetry(ctx, run, neuronRef, upstream)
 
-				resultsMu.Lock()
-				results = append(results, result)
-				completed[nr.Name] = true
-				resultsMu.Unlock()
+		// Fix a failed check and verify it again
+		result = e.remediate(ctx, run, neuronRef, result, upstream)
+	}
 
-				// Handle failure
-				if result.Status == "failed" && len(nr.OnFailure) > 0 {
-					fmt.Fprintf(e.out, "Executing rollback for %s\n", nr.Name)
-					for _, rollbackNeuron := range nr.OnFailure {
-						e.executeNeuronByName(ctx, rollbackNeuron, synapseDir)
-					}
-				}
-			}(neuronRef)
+	// Execute rollback neurons if specified
+	if isFailure(result.Status) && len(neuronRef.OnFailure) > 0 {
+		e.printf(run, "Executing rollback for %s", neuronRef.Name)
+		for _, rollbackNeuron := range neuronRef.OnFailure {
+			e.runRollback(ctx, run, rollbackNeuron, neuronRef.Name, map[string]NeuronResult{neuronRef.Name: result})
 		}
+	}
 
-		// Wait for some neurons to complete
-		wg.Wait()
+	return result
+}
 
-		// Check which waiting neurons are now ready
-		resultsMu.Lock()
-		for name, neuronRef := range waiting {
-			allDepsComplete := true
-			for dep := range dependencies[name] {
-				if !completed[dep] {
-					allDepsComplete = false
-					break
-				}
-			}
-			if allDepsComplete {
-				readyQueue = append(readyQueue, neuronRef)
-				delete(waiting, name)
+// triggerRule returns the trigger rule of the neuron. Without one, a condition
+// reading the result of a dependency decides alone whether the neuron runs, so
+// that conditions such as neurons.check.exitCode != 0 can be met.
+func (n NeuronRef) triggerRule() TriggerRule {
+	if n.TriggerRule != "" || n.Condition == "" {
+		return n.TriggerRule
+	}
+	expr, err := ParseExpression(n.Condition)
+	if err != nil {
+		return n.TriggerRule
+	}
+	for _, ref := range expr.NeuronReferences() {
+		for _, dep := range n.DependsOn {
+			if ref == dep {
+				return TriggerAlways
 			}
 		}
-		resultsMu.Unlock()
+	}
+	return n.TriggerRule
+}
 
-		// Break if no progress
-		if len(readyQueue) == 0 && len(waiting) > 0 {
-			return fmt.Errorf("deadlock detected: some neurons cannot execute due to unmet dependencies")
+// triggerSkipReason applies the neuron's trigger rule to the outcome of its
+// dependencies. It returns why the neuron must be skipped, or "" if it may run.
+// Dependencies without a result (not run yet in sequential mode) are ignored.
+func triggerSkipReason(neuronRef NeuronRef, upstream map[string]NeuronResult) string {
+	var failed, skipped, seen int
+	for _, dep := range neuronRef.DependsOn {
+		result, ok := upstream[dep]
+		if !ok {
+			continue
+		}
+		seen++
+		switch result.Status {
+		case "failed", "timed_out":
+			failed++
+		case "skipped":
+			skipped++
 		}
 	}
+	if seen == 0 {
+		return ""
+	}
 
-	// Add results to record
-	record.NeuronResults = results
-
-	return nil
+	switch neuronRef.triggerRule() {
+	case TriggerAlways:
+		return ""
+	case TriggerOnFailure:
+		if failed == 0 {
+			return "no upstream failed"
+		}
+	case TriggerOnSkip:
+		if skipped == 0 {
+			return "no upstream skipped"
+		}
+	default:
+		if failed > 0 {
+			return "upstream failed"
+		}
+		if skipped > 0 {
+			return "upstream skipped"
+		}
+	}
+	return ""
 }
 
-// executeNeuronWithRetry executes a neuron with retry policy
-func (e *Executor) executeNeuronWithRetry(ctx context.Context, neuronRef NeuronRef, synapseDir string) NeuronResult {
+// executeNeuronWithRetry executes a neuron with retry policy. Outputs of the
+// neurons it depends on are taken from upstream and exported to its
+// environment, followed by extraEnv.
+func (e *Executor) executeNeuronWithRetry(ctx context.Context, run *executionRun, neuronRef NeuronRef, upstream map[string]NeuronResult, extraEnv ...string) NeuronResult {
 	result := NeuronResult{
 		Name:   neuronRef.Name,
 		Status: "success",
 	}
 
-	maxAttempts := 1
-	backoff := BackoffLinear
-	initialDelay := time.Second
+	retry := retryConfigFor(neuronRef)
 
-	if neuronRef.Retry != nil {
-		maxAttempts = neuronRef.Retry.MaxAttempts
-		if maxAttempts < 1 {
-			maxAttempts = 1
-		}
-		backoff = neuronRef.Retry.Backoff
-		if neuronRef.Retry.InitialDelay != "" {
-			if d, err := time.ParseDuration(neuronRef.Retry.InitialDelay); err == nil {
-				initialDelay = d
-			}
-		}
+	// Validate rejects malformed timeouts, zero leaves the neuron's own
+	timeout, _ := time.ParseDuration(neuronRef.Timeout)
+
+	env := append(upstreamOutputEnv(neuronRef, upstream), extraEnv...)
+
+	if err := e.approve(ctx, run, neuronRef.Name); err != nil {
+		e.printf(run, "Skipping: %s (%v)", neuronRef.Name, err)
+		result.Status = "skipped"
+		result.Reason = err.Error()
+		return result
+	}
+	if neuronRef.Poll != nil {
+		return e.pollNeuron(ctx, run, neuronRef, env)
 	}
 
 	var lastErr error
+	var timedOut bool
+	var attempts []AttemptResult
 	startTime := time.Now()
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		select {
-		case <-ctx.Done():
-			result.Status = "failed"
-			result.Error = "execution timeout exceeded"
-			result.Duration = time.Since(startTime)
-			return result
-		default:
+	// cancelled records a neuron interrupted by the synapse being stopped
+	cancelled := func() NeuronResult {
+		result.Status = "failed"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Status = "timed_out"
+		}
+		result.Error = contextErrorMessage(ctx)
+		result.Duration = time.Since(startTime)
+		if len(attempts) > 1 {
+			result.Attempts = attempts
+		}
+		return result
+	}
+
+	for attempt := 1; attempt <= retry.maxAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return cancelled()
 		}
 
+		var delay time.Duration
 		if attempt > 1 {
-			delay := e.calculateBackoff(initialDelay, attempt, backoff)
-			fmt.Fprintf(e.out, "Retry attempt %d/%d for %s (waiting %v)\n", attempt, maxAttempts, neuronRef.Name, delay)
-			time.Sleep(delay)
+			delay = retry.jittered(retry.delay(attempt - 1))
+			e.emit(RetryScheduled{
+				ExecutionID: run.record.ID,
+				Neuron:      neuronRef.Name,
+				Attempt:     attempt,
+				MaxAttempts: retry.maxAttempts,
+				Delay:       delay,
+				ExitCode:    attempts[len(attempts)-1].ExitCode,
+				Time:        time.Now(),
+			})
+			if err := waitForRetry(ctx, delay); err != nil {
+				return cancelled()
+			}
 		}
 
-		fmt.Fprintf(e.out, "Executing: %s\n", neuronRef.Name)
+		e.emit(NeuronStarted{ExecutionID: run.record.ID, Neuron: neuronRef.Name, Attempt: attempt, Time: time.Now()})
 
-		exitCode, stdout, stderr, err := e.executeNeuron(neuronRef.Name, synapseDir)
+		attemptStart := time.Now()
+		res, err := e.executeNeuron(ctx, run, neuronRef.Name, env, timeout)
+
+		attemptResult := AttemptResult{
+			Attempt:  attempt,
+			Delay:    delay,
+			ExitCode: res.ExitCode,
+			Duration: time.Since(attemptStart),
+			Stdout:   res.Stdout,
+			Stderr:   res.Stderr,
+			TimedOut: res.TimedOut,
+		}
+		if err != nil {
+			attemptResult.Error = err.Error()
+		}
+		attempts = append(attempts, attemptResult)
 
-		result.ExitCode = exitCode
-		result.Stdout = stdout
-		result.Stderr = stderr
+		result.ExitCode = res.ExitCode
+		result.Stdout = res.Stdout
+		result.Stderr = res.Stderr
+		result.OutputTruncated = res.Truncated
+		result.Outputs = res.Outputs
+		result.Reason = ""
 
-		if err == nil && exitCode == 0 {
+		if err == nil && res.Passed {
 			result.Status = "success"
 			result.Duration = time.Since(startTime)
+			if len(attempts) > 1 {
+				result.Attempts = attempts
+			}
 			return result
 		}
 
+		timedOut = res.TimedOut
+		if timedOut {
+			e.printf(run, "Neuron %s timed out", neuronRef.Name)
+		}
+		if res.LimitExceeded != "" {
+			result.Reason = fmt.Sprintf("%s limit exceeded", res.LimitExceeded)
+			e.printf(run, "Neuron %s exceeded its %s limit", neuronRef.Name, res.LimitExceeded)
+		}
+
 		lastErr = err
+
+		if attempt < retry.maxAttempts {
+			if ok, reason := retry.retryable(res.ExitCode); !ok {
+				e.printf(run, "Not retrying %s: %s", neuronRef.Name, reason)
+				if result.Reason == "" {
+					result.Reason = "not retried: " + reason
+				}
+				break
+			}
+		}
 	}
 
 	// All attempts failed
 	result.Status = "failed"
+	if timedOut {
+		result.Status = "timed_out"
+	}
 	result.Duration = time.Since(startTime)
 	if lastErr != nil {
 		result.Error = lastErr.Error()
 	}
+	if len(attempts) > 1 {
+		result.Attempts = attempts
+	}
 
 	return result
 }
 
-// executeNeuronByName executes a neuron by name (for rollback)
-func (e *Executor) executeNeuronByName(ctx context.Context, name string, synapseDir string) {
-	fmt.Fprintf(e.out, "Executing: %s\n", name)
-	e.executeNeuron(name, synapseDir)
-}
+// resolveNeuronPath finds the config file of a neuron: the path given in its
+// definition, or else the neurons directory of the synapse. A relative path is
+// taken relative to the synapse directory, falling back to the working
+// directory as legacy synapses were run from.
+func resolveNeuronPath(synapse *Synapse, synapseDir string, name string) (string, error) {
+	if def := synapse.definition(name); def != nil {
+		neuronPath := def.Config.Path
+		if !filepath.IsAbs(neuronPath) {
+			relative := filepath.Join(synapseDir, neuronPath)
+			if _, err := os.Stat(relative); err == nil {
+				neuronPath = relative
+			} else if _, err := os.Stat(neuronPath); err != nil {
+				neuronPath = relative
+			}
+		}
+		if info, err := os.Stat(neuronPath); err == nil && info.IsDir() {
+			neuronPath = filepath.Join(neuronPath, "neuron.yaml")
+		}
+		if _, err := os.Stat(neuronPath); err != nil {
+			return "", fmt.Errorf("neuron not found: %s (%s)", name, neuronPath)
+		}
+		return neuronPath, nil
+	}
 
-// executeNeuron executes a single neuron
-func (e *Executor) executeNeuron(name string, synapseDir string) (int, string, string, error) {
 	// Look for neuron in synapse directory
 	neuronPath := filepath.Join(synapseDir, "neurons", name+".yml")
 
@@ -356,54 +724,149 @@ func (e *Executor) executeNeuron(name string, synapseDir string) (int, string, s
 		// Try without .yml extension
 		neuronPath = filepath.Join(synapseDir, "neurons", name)
 		if _, err := os.Stat(neuronPath); os.IsNotExist(err) {
-			return -1, "", "", fmt.Errorf("neuron not found: %s", name)
+			return "", fmt.Errorf("neuron not found: %s", name)
 		}
 	}
+	return neuronPath, nil
+}
+
+// executeNeuron executes a single neuron, stopping it after timeout unless
+// that is zero. The returned result is never nil.
+func (e *Executor) executeNeuron(ctx context.Context, run *executionRun, name string, env []string, timeout time.Duration) (*neuron.Result, error) {
+	if err := e.approve(ctx, run, name); err != nil {
+		return &neuron.Result{ExitCode: -1}, err
+	}
+
+	neuronPath, err := resolveNeuronPath(run.synapse, run.synapseDir, name)
+	if err != nil {
+		return &neuron.Result{ExitCode: -1}, err
+	}
 
 	// Load neuron
 	n, err := neuron.NewNeuron(e.logger, neuronPath)
 	if err != nil {
-		return -1, "", "", fmt.Errorf("failed to load neuron: %w", err)
+		return &neuron.Result{ExitCode: -1}, fmt.Errorf("failed to load neuron: %w", err)
 	}
 
-	// Execute neuron
-	exitCode, err := n.Excite(false, e.out)
+	e.mu.Lock()
+	maxOutputBytes := e.maxOutputBytes
+	store := e.secretStore
+	e.mu.Unlock()
+
+	processEnv := append(append([]string{}, run.env...), inputEnv(run.inputs)...)
+
+	// Execute neuron, emitting its output live while capturing it for the record
+	stdout := e.outputLines(run, name, StreamStdout)
+	stderr := e.outputLines(run, name, StreamStderr)
+	defer stdout.Flush()
+	defer stderr.Flush()
+	return n.ExciteWithOptions(ctx, neuron.ExciteOptions{
+		Out:            stdout,
+		Stderr:         stderr,
+		Env:            append(processEnv, env...),
+		MaxOutputBytes: maxOutputBytes,
+		Limits:         run.limits,
+		Timeout:        timeout,
+		Masker:         e.masker,
+		SecretStore:    store,
+		Target:         run.target(),
+	})
+}
 
-	// For now, return empty stdout/stderr as neuron.Excite doesn't capture them
-	return exitCode, "", "", err
+// target returns the target of the synapse, else that of the nearest parent
+// with one
+func (run *executionRun) target() *neuron.Target {
+	for ; run != nil; run = run.parent {
+		if run.synapse.Target != nil {
+			return run.synapse.Target
+		}
+	}
+	return nil
 }
 
-// evaluateCondition evaluates a conditional expression
-func (e *Executor) evaluateCondition(condition string) bool {
+// outputLines emits OutputLine events for a stream of the named neuron
+func (e *Executor) outputLines(run *executionRun, name, stream string) *outputLines {
+	return &outputLines{emit: func(line string) {
+		e.emit(OutputLine{ExecutionID: run.record.ID, Neuron: name, Stream: stream, Line: line, Time: time.Now()})
+	}}
+}
+
+// upstreamOutputEnv exports the outputs of the neurons a neuron depends on.
+// Each output is available both as KEY and as CORTEX_<PRODUCER>_<KEY>; when
+// several producers write the same KEY, the one listed last in dependsOn wins.
+func upstreamOutputEnv(neuronRef NeuronRef, upstream map[string]NeuronResult) []string {
+	var env []string
+	for _, dep := range neuronRef.DependsOn {
+		result, ok := upstream[dep]
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(result.Outputs))
+		for key := range result.Outputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := result.Outputs[key]
+			env = append(env,
+				key+"="+value,
+				fmt.Sprintf("CORTEX_%s_%s=%s", envName(dep), key, value),
+			)
+		}
+	}
+	return env
+}
+
+// envName converts a neuron name into an environment variable name fragment
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// evaluateCondition evaluates a conditional expression against the inputs of
+// the execution and the results of neurons that have already run
+func (e *Executor) evaluateCondition(condition string, inputs map[string]string, results map[string]NeuronResult) (bool, error) {
 	if condition == "" {
-		return true // No condition means always execute
+		return true, nil // No condition means always execute
 	}
 
-	// Simple condition evaluation: "key == 'value'"
-	// This is a placeholder - a real implementation would use a proper expression parser
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	expr, err := ParseExpression(condition)
+	if err != nil {
+		return false, err
+	}
 
-	// For now, just check if the condition string exists in environment
-	// A proper implementation would parse expressions like "environment == 'staging'"
-	for key, value := range e.environment {
-		expected := fmt.Sprintf("%s == '%s'", key, value)
-		if condition == expected {
+	return expr.Evaluate(&EvalContext{
+		Inputs:  inputs,
+		Results: results,
+	})
+}
+
+// hasFailure reports whether any of the results is a failure
+func hasFailure(results []NeuronResult) bool {
+	for _, result := range results {
+		if isFailure(result.Status) {
 			return true
 		}
 	}
-
 	return false
 }
 
-// calculateBackoff calculates delay for retry attempts
-func (e *Executor) calculateBackoff(initialDelay time.Duration, attempt int, strategy BackoffStrategy) time.Duration {
-	if strategy == BackoffExponential {
-		// Exponential: delay * 2^(attempt-1)
-		multiplier := 1 << uint(attempt-2) // 2^(attempt-2)
-		return initialDelay * time.Duration(multiplier)
-	}
+// isFailure reports whether a neuron status counts as a failure
+func isFailure(status string) bool {
+	return status == "failed" || status == "timed_out"
+}
 
-	// Linear: delay * attempt
-	return initialDelay * time.Duration(attempt)
+// contextErrorMessage describes why ctx is done
+func contextErrorMessage(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return "execution cancelled"
+	}
+	return "execution timeout exceeded"
 }
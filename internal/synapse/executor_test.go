@@ -0,0 +1,489 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// writeNeuron creates neurons/<name>.yml and its script inside synapseDir
+func writeNeuron(synapseDir, name, script string) {
+	neuronsDir := filepath.Join(synapseDir, "neurons")
+	Expect(os.MkdirAll(neuronsDir, 0755)).To(Succeed())
+
+	scriptPath := filepath.Join(neuronsDir, name+".sh")
+	Expect(os.WriteFile(scriptPath, []byte("#!/bin/bash\n"+script+"\n"), 0755)).To(Succeed())
+
+	config := fmt.Sprintf("name: %s\ntype: check\nexec_file: %s\npre_exec_debug: running %s\n", name, scriptPath, name)
+	Expect(os.WriteFile(filepath.Join(neuronsDir, name+".yml"), []byte(config), 0644)).To(Succeed())
+}
+
+func resultByName(record synapse.ExecutionRecord, name string) synapse.NeuronResult {
+	for _, r := range record.NeuronResults {
+		if r.Name == name {
+			return r
+		}
+	}
+	Fail("no result for neuron " + name)
+	return synapse.NeuronResult{}
+}
+
+var _ = Describe("Executor", func() {
+	var (
+		synapseDir     string
+		historyDir     string
+		historyManager *synapse.HistoryManager
+		executor       *synapse.Executor
+		out            *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-executor-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-executor-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		historyManager = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), historyManager, out)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	lastRecord := func(name string) synapse.ExecutionRecord {
+		history, err := historyManager.GetHistory(name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history).NotTo(BeEmpty())
+		return history[len(history)-1]
+	}
+
+	Describe("neuron outputs", func() {
+		BeforeEach(func() {
+			writeNeuron(synapseDir, "find-pod", `echo "POD_NAME=web-1" >> "$CORTEX_OUTPUT"`)
+			writeNeuron(synapseDir, "restart-pod", `echo "restarting $POD_NAME via $CORTEX_FIND_POD_POD_NAME"`)
+		})
+
+		It("stores outputs and exports them to dependent neurons", func() {
+			syn := &synapse.Synapse{
+				Name: "outputs",
+				Neurons: []synapse.NeuronRef{
+					{Name: "find-pod"},
+					{Name: "restart-pod", DependsOn: []string{"find-pod"}, Condition: "neurons.find-pod.outputs.POD_NAME == 'web-1'"},
+				},
+			}
+			Expect(syn.Validate()).To(Succeed())
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+			record := lastRecord("outputs")
+			Expect(resultByName(record, "find-pod").Outputs).To(HaveKeyWithValue("POD_NAME", "web-1"))
+			restart := resultByName(record, "restart-pod")
+			Expect(restart.Status).To(Equal("success"))
+			Expect(restart.Stdout).To(ContainSubstring("restarting web-1 via web-1"))
+		})
+	})
+
+	Describe("parallel execution", func() {
+		It("starts a neuron as soon as its own dependencies finish", func() {
+			marker := filepath.Join(synapseDir, "fast-branch-done")
+			writeNeuron(synapseDir, "fast", "exit 0")
+			writeNeuron(synapseDir, "after-fast", "touch "+marker)
+			// slow only succeeds if after-fast ran while it was still running
+			writeNeuron(synapseDir, "slow", "for i in $(seq 50); do [ -f "+marker+" ] && exit 0; sleep 0.1; done; exit 1")
+
+			syn := &synapse.Synapse{
+				Name:      "branches",
+				Execution: synapse.ExecutionParallel,
+				Neurons: []synapse.NeuronRef{
+					{Name: "fast"},
+					{Name: "slow"},
+					{Name: "after-fast", DependsOn: []string{"fast"}},
+				},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+			record := lastRecord("branches")
+			Expect(record.Status).To(Equal("success"))
+			Expect(resultByName(record, "slow").Status).To(Equal("success"))
+		})
+
+		It("records results in declaration order", func() {
+			writeNeuron(synapseDir, "a", "sleep 0.3")
+			writeNeuron(synapseDir, "b", "exit 0")
+			writeNeuron(synapseDir, "c", "sleep 0.1")
+
+			syn := &synapse.Synapse{
+				Name:           "ordering",
+				Execution:      synapse.ExecutionParallel,
+				MaxConcurrency: 3,
+				Neurons:        []synapse.NeuronRef{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+			var names []string
+			for _, r := range lastRecord("ordering").NeuronResults {
+				names = append(names, r.Name)
+			}
+			Expect(names).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("never runs more neurons than maxConcurrency", func() {
+			counter := filepath.Join(synapseDir, "running")
+			script := "echo x >> " + counter + "; [ $(wc -l < " + counter + ") -le 1 ] || exit 1; sleep 0.2; sed -i '$d' " + counter
+			for _, name := range []string{"a", "b", "c"} {
+				writeNeuron(synapseDir, name, script)
+			}
+
+			syn := &synapse.Synapse{
+				Name:           "limited",
+				Execution:      synapse.ExecutionParallel,
+				MaxConcurrency: 1,
+				Neurons:        []synapse.NeuronRef{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			Expect(lastRecord("limited").Status).To(Equal("success"))
+		})
+
+		It("cancels remaining work when stopOnError is set", func() {
+			writeNeuron(synapseDir, "broken", "exit 2")
+			writeNeuron(synapseDir, "long", "exec sleep 10")
+			writeNeuron(synapseDir, "later", "exit 0")
+
+			syn := &synapse.Synapse{
+				Name:        "stop",
+				Execution:   synapse.ExecutionParallel,
+				StopOnError: true,
+				Neurons: []synapse.NeuronRef{
+					{Name: "broken"},
+					{Name: "long"},
+					{Name: "later", DependsOn: []string{"broken"}},
+				},
+			}
+
+			start := time.Now()
+			err := executor.Execute(context.Background(), syn, synapseDir)
+			Expect(err).To(MatchError(ContainSubstring("neuron broken failed")))
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			Expect(out.String()).To(ContainSubstring("Stopping execution due to error in broken"))
+
+			record := lastRecord("stop")
+			Expect(record.NeuronResults).To(HaveLen(2))
+			Expect(resultByName(record, "long").Status).To(Equal("failed"))
+		})
+	})
+
+	Describe("trigger rules", func() {
+		BeforeEach(func() {
+			writeNeuron(synapseDir, "check", "exit 1")
+			writeNeuron(synapseDir, "ok", "exit 0")
+		})
+
+		DescribeTable("deciding whether dependents run",
+			func(mode synapse.ExecutionMode, rule synapse.TriggerRule, expectedStatus, expectedReason string) {
+				syn := &synapse.Synapse{
+					Name:      "triggers",
+					Execution: mode,
+					Neurons: []synapse.NeuronRef{
+						{Name: "check"},
+						{Name: "ok", DependsOn: []string{"check"}, TriggerRule: rule},
+					},
+				}
+				Expect(syn.Validate()).To(Succeed())
+				Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+				result := resultByName(lastRecord("triggers"), "ok")
+				Expect(result.Status).To(Equal(expectedStatus))
+				Expect(result.Reason).To(Equal(expectedReason))
+			},
+			Entry("on-success by default", synapse.ExecutionSequential, synapse.TriggerRule(""), "skipped", "upstream failed"),
+			Entry("on-success in parallel", synapse.ExecutionParallel, synapse.TriggerOnSuccess, "skipped", "upstream failed"),
+			Entry("on-failure", synapse.ExecutionParallel, synapse.TriggerOnFailure, "success", ""),
+			Entry("always", synapse.ExecutionSequential, synapse.TriggerAlways, "success", ""),
+			Entry("on-skip without skipped upstream", synapse.ExecutionSequential, synapse.TriggerOnSkip, "skipped", "no upstream skipped"),
+		)
+
+		DescribeTable("runs remediation neurons whose condition checks for failure",
+			func(mode synapse.ExecutionMode) {
+				writeNeuron(synapseDir, "verify", "exit 0")
+				syn := &synapse.Synapse{
+					Name:      "remediation",
+					Execution: mode,
+					Neurons: []synapse.NeuronRef{
+						{Name: "check"},
+						{Name: "ok", DependsOn: []string{"check"}, Condition: "neurons.check.exitCode != 0"},
+						{Name: "verify", DependsOn: []string{"ok"}, Condition: "neurons.ok.status == 'success'"},
+					},
+				}
+				Expect(syn.Validate()).To(Succeed())
+				Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+				record := lastRecord("remediation")
+				Expect(resultByName(record, "ok").Status).To(Equal("success"))
+				Expect(resultByName(record, "verify").Status).To(Equal("success"))
+			},
+			Entry("sequential", synapse.ExecutionSequential),
+			Entry("parallel", synapse.ExecutionParallel),
+		)
+
+		It("skips remediation neurons whose condition is not met", func() {
+			writeNeuron(synapseDir, "check", "exit 0")
+			syn := &synapse.Synapse{
+				Name: "remediation",
+				Neurons: []synapse.NeuronRef{
+					{Name: "check"},
+					{Name: "ok", DependsOn: []string{"check"}, Condition: "neurons.check.exitCode != 0"},
+				},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			Expect(resultByName(lastRecord("remediation"), "ok").Reason).To(Equal("condition not met"))
+		})
+
+		It("cascades skips through on-success chains", func() {
+			writeNeuron(synapseDir, "verify", "exit 0")
+			syn := &synapse.Synapse{
+				Name:      "cascade",
+				Execution: synapse.ExecutionParallel,
+				Neurons: []synapse.NeuronRef{
+					{Name: "check"},
+					{Name: "ok", DependsOn: []string{"check"}},
+					{Name: "verify", DependsOn: []string{"ok"}},
+				},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			Expect(resultByName(lastRecord("cascade"), "verify").Reason).To(Equal("upstream skipped"))
+			Expect(out.String()).To(ContainSubstring("Skipping: ok (upstream failed)"))
+		})
+
+		It("rejects unknown trigger rules", func() {
+			syn := &synapse.Synapse{
+				Name:    "invalid",
+				Neurons: []synapse.NeuronRef{{Name: "ok", TriggerRule: "sometimes"}},
+			}
+			Expect(syn.Validate()).To(MatchError(ContainSubstring("invalid triggerRule")))
+		})
+	})
+
+	Describe("resuming an execution", func() {
+		var (
+			runs   string
+			broken string
+		)
+
+		BeforeEach(func() {
+			runs = filepath.Join(synapseDir, "runs")
+			broken = filepath.Join(synapseDir, "broken")
+			Expect(os.WriteFile(broken, nil, 0644)).To(Succeed())
+
+			writeNeuron(synapseDir, "prepare", `echo prepare >> `+runs+`; echo "TOKEN=abc" >> "$CORTEX_OUTPUT"`)
+			writeNeuron(synapseDir, "apply", `echo apply >> `+runs+`; [ ! -f `+broken+` ] && [ "$TOKEN" = abc ]`)
+			writeNeuron(synapseDir, "verify", `echo verify >> `+runs)
+		})
+
+		It("reuses successful results and re-runs the rest", func() {
+			syn := &synapse.Synapse{
+				Name: "resumable",
+				Neurons: []synapse.NeuronRef{
+					{Name: "prepare"},
+					{Name: "apply", DependsOn: []string{"prepare"}},
+					{Name: "verify", DependsOn: []string{"apply"}},
+				},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			first := lastRecord("resumable")
+			Expect(first.Status).To(Equal("partial"))
+			Expect(resultByName(first, "verify").Status).To(Equal("skipped"))
+
+			Expect(os.Remove(broken)).To(Succeed())
+			Expect(executor.Resume(context.Background(), syn, synapseDir, first.ID)).To(Succeed())
+
+			second := lastRecord("resumable")
+			Expect(second.ID).NotTo(Equal(first.ID))
+			Expect(second.ResumedFrom).To(Equal(first.ID))
+			Expect(second.Status).To(Equal("success"))
+			Expect(resultByName(second, "prepare").Reused).To(BeTrue())
+			Expect(resultByName(second, "apply").Reused).To(BeFalse())
+
+			data, err := os.ReadFile(runs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("prepare\napply\napply\nverify\n"))
+		})
+
+		It("reuses the inputs of the execution unless they are set again", func() {
+			writeNeuron(synapseDir, "apply", `echo "apply $CORTEX_INPUT_REGION $CORTEX_INPUT_REPLICAS" >> `+runs+`; [ ! -f `+broken+` ]`)
+			syn := &synapse.Synapse{
+				Name:    "resumable",
+				Inputs:  []synapse.Input{{Name: "region", Required: true}, {Name: "replicas", Default: "1"}},
+				Neurons: []synapse.NeuronRef{{Name: "apply"}},
+			}
+			executor.SetEnvironment(map[string]string{"region": "eu-west-1", "replicas": "2"})
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			first := lastRecord("resumable")
+
+			Expect(os.Remove(broken)).To(Succeed())
+			executor.SetEnvironment(map[string]string{"replicas": "3"})
+			Expect(executor.Resume(context.Background(), syn, synapseDir, first.ID)).To(Succeed())
+
+			second := lastRecord("resumable")
+			Expect(second.Status).To(Equal("success"))
+			Expect(second.Inputs).To(Equal(map[string]string{"region": "eu-west-1", "replicas": "3"}))
+			data, err := os.ReadFile(runs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("apply eu-west-1 2\napply eu-west-1 3\n"))
+		})
+
+		It("fails for unknown executions", func() {
+			syn := &synapse.Synapse{Name: "resumable", Neurons: []synapse.NeuronRef{{Name: "prepare"}}}
+			Expect(executor.Resume(context.Background(), syn, synapseDir, "missing")).To(MatchError(ContainSubstring("failed to load execution missing")))
+		})
+	})
+
+	Describe("resource limits", func() {
+		It("records limit violations as the failure reason", func() {
+			if runtime.GOOS != "linux" {
+				Skip("resource limits are only enforced on Linux")
+			}
+			writeNeuron(synapseDir, "spin", "while :; do :; done")
+			writeNeuron(synapseDir, "quick", "echo done")
+
+			syn := &synapse.Synapse{
+				Name:      "limited",
+				Resources: &synapse.ResourceLimits{Memory: "256Mi", CPUTime: "1s", MaxOpenFiles: 64},
+				Neurons:   []synapse.NeuronRef{{Name: "spin"}, {Name: "quick"}},
+			}
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+			record := lastRecord("limited")
+			spin := resultByName(record, "spin")
+			Expect(spin.Status).To(Equal("failed"))
+			Expect(spin.Reason).To(Equal("cpu time limit exceeded"))
+			Expect(resultByName(record, "quick").Status).To(Equal("success"))
+		})
+
+		DescribeTable("rejects invalid limits",
+			func(limits synapse.ResourceLimits, message string) {
+				syn := &synapse.Synapse{Name: "limited", Resources: &limits, Neurons: []synapse.NeuronRef{{Name: "quick"}}}
+				Expect(syn.Validate()).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("unknown memory unit", synapse.ResourceLimits{Memory: "12parsecs"}, `invalid memory limit "12parsecs"`),
+			Entry("missing memory amount", synapse.ResourceLimits{Memory: "Mi"}, "invalid memory limit"),
+			Entry("bad cpu time", synapse.ResourceLimits{CPUTime: "soon"}, `invalid cpuTime limit "soon"`),
+			Entry("negative processes", synapse.ResourceLimits{MaxProcesses: -1}, "invalid maxProcesses limit"),
+		)
+
+		DescribeTable("converts limits for neuron processes",
+			func(memory string, expected uint64) {
+				limits, err := (&synapse.ResourceLimits{Memory: memory}).NeuronLimits()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(limits.Memory).To(Equal(expected))
+			},
+			Entry("bytes", "512", uint64(512)),
+			Entry("binary units", "256Mi", uint64(256<<20)),
+			Entry("decimal units", "1G", uint64(1000*1000*1000)),
+			Entry("byte suffix", "2GiB", uint64(2<<30)),
+		)
+	})
+
+	Describe("targets", func() {
+		var argsLog string
+
+		BeforeEach(func() {
+			// A fake ssh client that records its arguments and runs the
+			// remote command locally
+			argsLog = filepath.Join(synapseDir, "ssh-args")
+			ssh := filepath.Join(synapseDir, "fake-ssh")
+			Expect(os.WriteFile(ssh, []byte("#!/bin/sh\necho \"$@\" >> "+argsLog+"\nwhile [ $# -gt 1 ]; do shift; done\nexec sh -c \"$1\"\n"), 0755)).To(Succeed())
+			os.Setenv(neuron.SSHCommandEnvVar, ssh)
+		})
+
+		AfterEach(func() {
+			os.Unsetenv(neuron.SSHCommandEnvVar)
+		})
+
+		It("runs neurons on the target of the synapse", func() {
+			writeNeuron(synapseDir, "uptime", `echo "UP=$GREETING" >> "$CORTEX_OUTPUT"`)
+			Expect(os.WriteFile(filepath.Join(synapseDir, synapse.ConfigFile), []byte(
+				"name: remote\ntarget: ops@db-01:2222\nenv:\n  GREETING: hello\nneurons: [uptime]\n"), 0644)).To(Succeed())
+
+			syn, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+			result := resultByName(lastRecord("remote"), "uptime")
+			Expect(result.Status).To(Equal("success"))
+			Expect(result.Outputs).To(HaveKeyWithValue("UP", "hello"))
+			Expect(os.ReadFile(argsLog)).To(ContainSubstring("-p 2222 -l ops db-01 "))
+		})
+
+		It("rejects invalid targets", func() {
+			Expect(os.WriteFile(filepath.Join(synapseDir, synapse.ConfigFile), []byte(
+				"name: remote\ntarget: -oProxyCommand=true\nneurons: [uptime]\n"), 0644)).To(Succeed())
+			_, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).To(MatchError(ContainSubstring(`invalid target host "-oProxyCommand=true"`)))
+		})
+	})
+
+	Describe("timeouts", func() {
+		It("records neurons that exceed their timeout as timed out", func() {
+			writeNeuron(synapseDir, "hang", "sleep 30")
+			writeNeuron(synapseDir, "after", "echo after")
+			writeNeuron(synapseDir, "report", "echo report")
+
+			syn := &synapse.Synapse{
+				Name: "hanging",
+				Neurons: []synapse.NeuronRef{
+					{Name: "hang", Timeout: "200ms"},
+					{Name: "after", DependsOn: []string{"hang"}},
+					{Name: "report", DependsOn: []string{"hang"}, TriggerRule: synapse.TriggerOnFailure},
+				},
+			}
+
+			start := time.Now()
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+
+			record := lastRecord("hanging")
+			Expect(record.Status).To(Equal("partial"))
+			hang := resultByName(record, "hang")
+			Expect(hang.Status).To(Equal("timed_out"))
+			Expect(hang.Error).To(Equal("timed out after 200ms"))
+			Expect(resultByName(record, "after").Reason).To(Equal("upstream failed"))
+			Expect(resultByName(record, "report").Status).To(Equal("success"))
+		})
+
+		It("stops running neurons when the synapse timeout expires", func() {
+			writeNeuron(synapseDir, "hang", "sleep 30")
+
+			syn := &synapse.Synapse{
+				Name:    "slow",
+				Timeout: "300ms",
+				Neurons: []synapse.NeuronRef{{Name: "hang"}},
+			}
+
+			start := time.Now()
+			Expect(executor.Execute(context.Background(), syn, synapseDir)).To(MatchError("execution timeout exceeded"))
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+
+			record := lastRecord("slow")
+			Expect(record.Status).To(Equal("failed"))
+			Expect(resultByName(record, "hang").Status).To(Equal("timed_out"))
+		})
+
+		It("rejects invalid timeouts", func() {
+			syn := &synapse.Synapse{Name: "slow", Neurons: []synapse.NeuronRef{{Name: "hang", Timeout: "forever"}}}
+			Expect(syn.Validate()).To(MatchError("neuron hang has invalid timeout: forever"))
+		})
+	})
+})
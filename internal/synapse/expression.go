@@ -0,0 +1,733 @@
+package synapse
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a parsed neuron condition.
+//
+// The language supports:
+//   - literals: 'string', "string", 42, 1.5, true, false, null, [list, of, values]
+//   - comparison: ==, !=, <, <=, >, >=
+//   - boolean logic: &&, ||, ! and parentheses
+//   - membership: x in [a, b], x not in [a, b] (substring match when the right side is a string)
+//   - regex match: x =~ 'pattern', x !~ 'pattern'
+//
+// Identifiers resolve against the evaluation context:
+//   - name                        synapse input (the --env map)
+//   - inputs.name                 synapse input
+//   - env.NAME                    process environment variable
+//   - neurons.<name>.status       status of a previously executed neuron
+//   - neurons.<name>.exitCode     exit code of a previously executed neuron
+//   - neurons.<name>.stdout       captured stdout of a previously executed neuron
+//   - neurons.<name>.stderr       captured stderr of a previously executed neuron
+//   - neurons.<name>.outputs.KEY  value the neuron wrote to $CORTEX_OUTPUT
+//
+// Poll criteria can also refer to the polled neuron's own exitCode, stdout,
+// stderr and outputs.KEY.
+//
+// Unknown identifiers evaluate to null, which only equals null: ordered
+// comparisons against it are false. '-' may appear in neuron names only.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// EvalContext holds the values an expression can reference
+type EvalContext struct {
+	Inputs  map[string]string
+	Results map[string]NeuronResult
+	Self    *NeuronResult // the polled neuron, for poll criteria
+}
+
+// ParseExpression parses a condition expression
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the original expression source
+func (x *Expression) String() string {
+	return x.source
+}
+
+// Evaluate evaluates the expression and returns its truthiness
+func (x *Expression) Evaluate(ctx *EvalContext) (bool, error) {
+	if ctx == nil {
+		ctx = &EvalContext{}
+	}
+	v, err := x.root.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// NeuronReferences returns the names of neurons referenced through neurons.<name>
+func (x *Expression) NeuronReferences() []string {
+	var refs []string
+	seen := make(map[string]bool)
+	walkExpr(x.root, func(n exprNode) {
+		id, ok := n.(*identNode)
+		if !ok || len(id.path) < 2 || id.path[0] != "neurons" {
+			return
+		}
+		if !seen[id.path[1]] {
+			seen[id.path[1]] = true
+			refs = append(refs, id.path[1])
+		}
+	})
+	return refs
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "=~", "!~", "<", ">", "!"}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case c == '\'' || c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(src) {
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if rune(src[i]) == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			i++
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (isIdentChar(rune(src[i])) || src[i] == '-' && inNeuronName(src[start:i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	tokens = append(tokens, token{tokEOF, "end of expression", len(src)})
+	return tokens, nil
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
+
+// inNeuronName reports whether ident, an identifier being lexed, ends in the
+// neuron name of neurons.<name>, the only place '-' is allowed; elsewhere a-b
+// is rejected rather than read as one identifier
+func inNeuronName(ident string) bool {
+	name, ok := strings.CutPrefix(ident, "neurons.")
+	return ok && name != "" && !strings.Contains(name, ".")
+}
+
+// Parser
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOp && tok.text != "!" && tok.text != "&&" && tok.text != "||":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		node := &compareNode{op: tok.text, left: left, right: right}
+		if tok.text == "=~" || tok.text == "!~" {
+			if lit, ok := right.(*literalNode); ok {
+				pattern, ok := lit.value.(string)
+				if !ok {
+					return nil, fmt.Errorf("regex at position %d must be a string", tok.pos)
+				}
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("invalid regex at position %d: %w", tok.pos, err)
+				}
+				node.re = re
+			}
+		}
+		return node, nil
+	case tok.kind == tokIdent && tok.text == "in":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{left: left, right: right}, nil
+	case tok.kind == tokIdent && tok.text == "not":
+		p.next()
+		if in := p.next(); in.kind != tokIdent || in.text != "in" {
+			return nil, fmt.Errorf("expected 'in' after 'not' at position %d", in.pos)
+		}
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: &inNode{left: left, right: right}}, nil
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: f}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "in", "not":
+			return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+		}
+		if strings.HasPrefix(tok.text, ".") || strings.HasSuffix(tok.text, ".") || strings.Contains(tok.text, "..") {
+			return nil, fmt.Errorf("invalid identifier %q at position %d", tok.text, tok.pos)
+		}
+		return &identNode{name: tok.text, path: strings.Split(tok.text, ".")}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return inner, nil
+	case tokLBracket:
+		list := &listNode{}
+		if p.peek().kind == tokRBracket {
+			p.next()
+			return list, nil
+		}
+		for {
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+			sep := p.next()
+			if sep.kind == tokRBracket {
+				return list, nil
+			}
+			if sep.kind != tokComma {
+				return nil, fmt.Errorf("expected ',' or ']' at position %d", sep.pos)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// AST
+
+type exprNode interface {
+	eval(ctx *EvalContext) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type identNode struct {
+	name string
+	path []string
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+	re          *regexp.Regexp
+}
+
+type inNode struct {
+	left, right exprNode
+}
+
+func walkExpr(n exprNode, fn func(exprNode)) {
+	fn(n)
+	switch node := n.(type) {
+	case *listNode:
+		for _, item := range node.items {
+			walkExpr(item, fn)
+		}
+	case *notNode:
+		walkExpr(node.operand, fn)
+	case *logicalNode:
+		walkExpr(node.left, fn)
+		walkExpr(node.right, fn)
+	case *compareNode:
+		walkExpr(node.left, fn)
+		walkExpr(node.right, fn)
+	case *inNode:
+		walkExpr(node.left, fn)
+		walkExpr(node.right, fn)
+	}
+}
+
+func (n *literalNode) eval(ctx *EvalContext) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(ctx *EvalContext) (interface{}, error) {
+	if ctx.Self != nil {
+		switch n.path[0] {
+		case "exitCode", "exit_code", "stdout", "stderr", "outputs":
+			self := map[string]NeuronResult{"": *ctx.Self}
+			return resolveNeuronField(self, append([]string{""}, n.path...))
+		}
+	}
+	if len(n.path) == 1 {
+		return lookupString(ctx.Inputs, n.path[0]), nil
+	}
+
+	switch n.path[0] {
+	case "inputs":
+		if len(n.path) == 2 {
+			return lookupString(ctx.Inputs, n.path[1]), nil
+		}
+	case "env":
+		if len(n.path) == 2 {
+			if v, ok := os.LookupEnv(n.path[1]); ok {
+				return v, nil
+			}
+			return nil, nil
+		}
+	case "neurons":
+		return resolveNeuronField(ctx.Results, n.path[1:])
+	}
+
+	return lookupString(ctx.Inputs, n.name), nil
+}
+
+func resolveNeuronField(results map[string]NeuronResult, path []string) (interface{}, error) {
+	result, ok := results[path[0]]
+	if !ok {
+		return nil, nil
+	}
+	if len(path) == 1 {
+		return result.Status, nil
+	}
+
+	field := path[1]
+	switch field {
+	case "status":
+		return result.Status, nil
+	case "exitCode", "exit_code":
+		return float64(result.ExitCode), nil
+	case "stdout":
+		return result.Stdout, nil
+	case "stderr":
+		return result.Stderr, nil
+	case "outputs":
+		if len(path) == 3 {
+			return lookupString(result.Outputs, path[2]), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown neuron field %q", strings.Join(path[1:], "."))
+}
+
+func lookupString(values map[string]string, key string) interface{} {
+	if v, ok := values[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (n *listNode) eval(ctx *EvalContext) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (n *notNode) eval(ctx *EvalContext) (interface{}, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+func (n *logicalNode) eval(ctx *EvalContext) (interface{}, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit evaluation
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+func (n *compareNode) eval(ctx *EvalContext) (interface{}, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "=~", "!~":
+		re := n.re
+		if re == nil {
+			pattern, ok := right.(string)
+			if !ok {
+				return nil, fmt.Errorf("regex pattern must be a string, got %s", describeValue(right))
+			}
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid regex: %w", err)
+			}
+		}
+		if left == nil {
+			return n.op == "!~", nil
+		}
+		matched := re.MatchString(stringify(left))
+		return matched == (n.op == "=~"), nil
+	}
+
+	// Unset values are neither smaller nor greater than anything
+	if left == nil || right == nil {
+		return false, nil
+	}
+	cmp, err := compareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func (n *inNode) eval(ctx *EvalContext) (interface{}, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := right.(type) {
+	case []interface{}:
+		for _, item := range r {
+			if valuesEqual(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		if left == nil {
+			return false, nil
+		}
+		return strings.Contains(r, stringify(left)), nil
+	case nil:
+		return false, nil
+	}
+	return nil, fmt.Errorf("'in' requires a list or string, got %s", describeValue(right))
+}
+
+// Value helpers
+
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != "" && val != "false" && val != "0"
+	case []interface{}:
+		return len(val) > 0
+	}
+	return true
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func stringify(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func describeValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		af, aok := toNumber(a)
+		bf, bok := toNumber(b)
+		if aok && bok {
+			return af == bf
+		}
+	}
+	return stringify(a) == stringify(b)
+}
+
+func compareValues(a, b interface{}) (int, error) {
+	if af, ok := toNumber(a); ok {
+		if bf, ok := toNumber(b); ok {
+			switch {
+			case af < bf:
+				return -1, nil
+			case af > bf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", describeValue(a), describeValue(b))
+}
@@ -0,0 +1,146 @@
+package synapse_test
+
+import (
+	"os"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Expression", func() {
+	var ctx *synapse.EvalContext
+
+	BeforeEach(func() {
+		ctx = &synapse.EvalContext{
+			Inputs: map[string]string{
+				"environment": "staging",
+				"replicas":    "5",
+				"region":      "eu-west-1",
+			},
+			Results: map[string]synapse.NeuronResult{
+				"check-pods": {Name: "check-pods", Status: "failed", ExitCode: 120, Stdout: "pod web-1 CrashLoopBackOff"},
+			},
+		}
+	})
+
+	evaluate := func(source string) bool {
+		expr, err := synapse.ParseExpression(source)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := expr.Evaluate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	DescribeTable("evaluating conditions",
+		func(source string, expected bool) {
+			Expect(evaluate(source)).To(Equal(expected))
+		},
+		Entry("string equality", "environment == 'staging'", true),
+		Entry("string inequality", "environment != 'prod'", true),
+		Entry("double quoted strings", `environment == "production"`, false),
+		Entry("numeric comparison on string inputs", "replicas > 3", true),
+		Entry("numeric equality across representations", "replicas == 5.0", true),
+		Entry("less than or equal", "replicas <= 4", false),
+		Entry("logical and", "environment == 'staging' && replicas > 3", true),
+		Entry("logical or", "environment == 'prod' || replicas >= 5", true),
+		Entry("negation with parentheses", "!(environment == 'staging')", false),
+		Entry("membership", "environment in ['dev', 'staging']", true),
+		Entry("negated membership", "environment not in ['dev', 'staging']", false),
+		Entry("substring membership", "'CrashLoop' in neurons.check-pods.stdout", true),
+		Entry("regex match", "region =~ '^eu-'", true),
+		Entry("regex non-match", "region !~ '^us-'", true),
+		Entry("prior neuron status", "neurons.check-pods.status == 'failed'", true),
+		Entry("prior neuron exit code", "neurons.check-pods.exitCode == 120", true),
+		Entry("unknown inputs are null", "missing == null", true),
+		Entry("unknown inputs are falsy", "missing", false),
+		Entry("inputs namespace", "inputs.environment == 'staging'", true),
+		Entry("unknown inputs are not greater", "missing > 3", false),
+		Entry("unknown inputs are not smaller", "missing < 3", false),
+		Entry("unknown neurons have no exit code", "neurons.missing.exitCode >= 0", false),
+		Entry("null is not ordered", "null <= null", false),
+	)
+
+	It("resolves process environment variables through env.", func() {
+		os.Setenv("CORTEX_EXPRESSION_TEST", "yes")
+		defer os.Unsetenv("CORTEX_EXPRESSION_TEST")
+
+		Expect(evaluate("env.CORTEX_EXPRESSION_TEST == 'yes'")).To(BeTrue())
+	})
+
+	DescribeTable("rejecting malformed expressions",
+		func(source string) {
+			_, err := synapse.ParseExpression(source)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("dangling operator", "environment =="),
+		Entry("unterminated string", "environment == 'staging"),
+		Entry("unbalanced parentheses", "(environment == 'staging'"),
+		Entry("invalid regex", "region =~ '('"),
+		Entry("unknown character", "replicas > 3 ; rm -rf /"),
+		Entry("trailing tokens", "environment 'staging'"),
+		Entry("subtraction-like identifiers", "a-b == 1"),
+		Entry("dashes after a neuron name", "neurons.check-pods.exit-code == 1"),
+	)
+
+	It("reports evaluation errors for incomparable values", func() {
+		expr, err := synapse.ParseExpression("[1, 2] > 3")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = expr.Evaluate(ctx)
+		Expect(err).To(MatchError(ContainSubstring("cannot compare")))
+	})
+
+	It("lists referenced neurons", func() {
+		expr, err := synapse.ParseExpression("neurons.a.status == 'success' && neurons.b.exitCode != 0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expr.NeuronReferences()).To(Equal([]string{"a", "b"}))
+	})
+
+	Describe("Synapse validation", func() {
+		It("surfaces condition parse errors", func() {
+			s := &synapse.Synapse{
+				Name: "conditional",
+				Neurons: []synapse.NeuronRef{
+					{Name: "deploy", Condition: "environment = 'prod'"},
+				},
+			}
+			Expect(s.Validate()).To(MatchError(ContainSubstring("invalid condition")))
+		})
+
+		It("rejects conditions referencing unknown neurons", func() {
+			s := &synapse.Synapse{
+				Name: "conditional",
+				Neurons: []synapse.NeuronRef{
+					{Name: "deploy", Condition: "neurons.missing.status == 'success'"},
+				},
+			}
+			Expect(s.Validate()).To(MatchError(ContainSubstring("non-existent neuron: missing")))
+		})
+
+		It("rejects conditions referencing neurons outside dependsOn in parallel synapses", func() {
+			s := &synapse.Synapse{
+				Name:      "conditional",
+				Execution: synapse.ExecutionParallel,
+				Neurons: []synapse.NeuronRef{
+					{Name: "check"},
+					{Name: "deploy", Condition: "neurons.check.status == 'success'"},
+				},
+			}
+			Expect(s.Validate()).To(MatchError("neuron deploy condition references check, which is not in dependsOn"))
+
+			s.Neurons[1].DependsOn = []string{"check"}
+			Expect(s.Validate()).To(Succeed())
+		})
+
+		It("accepts conditions referencing earlier neurons in sequential synapses", func() {
+			s := &synapse.Synapse{
+				Name: "conditional",
+				Neurons: []synapse.NeuronRef{
+					{Name: "check"},
+					{Name: "deploy", Condition: "neurons.check.status == 'success'"},
+				},
+			}
+			Expect(s.Validate()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package synapse
+
+import "context"
+
+// Outcomes of remediating a failed check with its fix neurons
+const (
+	RemediationFixed        = "fixed"         // the check passed when re-run after a fix
+	RemediationFixFailed    = "fix failed"    // a fix neuron itself failed
+	RemediationStillFailing = "still failing" // the check kept failing after every fix
+)
+
+// defaultMaxRemediations is how many fix/re-check rounds a check gets by default
+const defaultMaxRemediations = 1
+
+// fixesFor returns the fix neuron for each exit code of a neuron, from its
+// definition and its reference, the latter taking precedence
+func (s *Synapse) fixesFor(neuronRef NeuronRef) map[int]string {
+	var fixes map[int]string
+	add := func(m map[int]string) {
+		for code, fix := range m {
+			if fixes == nil {
+				fixes = make(map[int]string)
+			}
+			fixes[code] = fix
+		}
+	}
+	if def := s.definition(neuronRef.Name); def != nil {
+		add(def.Config.Fix)
+	}
+	add(neuronRef.Fix)
+	return fixes
+}
+
+// maxRemediations returns how many fix/re-check rounds a neuron gets
+func (nr NeuronRef) maxRemediations() int {
+	if nr.MaxRemediations > 0 {
+		return nr.MaxRemediations
+	}
+	return defaultMaxRemediations
+}
+
+// remediate runs the fix neuron mapped to the exit code of a failed check and
+// then re-runs the check, until it passes, a fix fails, no fix is mapped to
+// the exit code or the neuron's remediation budget is used up. The returned
+// result is that of the last check run, with the fixes and outcome recorded.
+// extraEnv is exported to both the fix and the check.
+func (e *Executor) remediate(ctx context.Context, run *executionRun, neuronRef NeuronRef, result NeuronResult, upstream map[string]NeuronResult, extraEnv ...string) NeuronResult {
+	fixes := run.synapse.fixesFor(neuronRef)
+	if result.Status != "failed" || fixes[result.ExitCode] == "" {
+		return result
+	}
+
+	var applied []FixResult
+	outcome := RemediationStillFailing
+	maxRemediations := neuronRef.maxRemediations()
+	for round := 1; round <= maxRemediations && ctx.Err() == nil; round++ {
+		fix, ok := fixes[result.ExitCode]
+		if !ok {
+			break
+		}
+
+		e.printf(run, "Fixing %s with %s (exit code %d, remediation %d/%d)", neuronRef.Name, fix, result.ExitCode, round, maxRemediations)
+		fixResult := e.executeNeuronWithRetry(ctx, run, NeuronRef{Name: fix, DependsOn: []string{neuronRef.Name}}, map[string]NeuronResult{neuronRef.Name: result}, extraEnv...)
+		applied = append(applied, FixResult{NeuronResult: fixResult, ForExitCode: result.ExitCode})
+		if isFailure(fixResult.Status) {
+			outcome = RemediationFixFailed
+			break
+		}
+
+		e.printf(run, "Re-verifying: %s", neuronRef.Name)
+		result = e.executeNeuronWithRetry(ctx, run, neuronRef, upstream, extraEnv...)
+		if result.Status == "success" {
+			outcome = RemediationFixed
+			break
+		}
+	}
+
+	switch outcome {
+	case RemediationFixed:
+		e.printf(run, "Neuron %s fixed", neuronRef.Name)
+	case RemediationFixFailed:
+		e.printf(run, "Fix for %s failed", neuronRef.Name)
+	default:
+		e.printf(run, "Neuron %s still failing after %d fixes", neuronRef.Name, len(applied))
+	}
+
+	result.Fixes = applied
+	result.Remediation = outcome
+	return result
+}
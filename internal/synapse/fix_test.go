@@ -0,0 +1,106 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fix mappings", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		out        *bytes.Buffer
+		checks     string
+		healthy    string
+	)
+
+	execute := func(ref synapse.NeuronRef) synapse.NeuronResult {
+		syn := &synapse.Synapse{Name: "remediate", Neurons: []synapse.NeuronRef{ref}}
+		Expect(syn.Validate()).To(Succeed())
+
+		record, err := executor.Run(context.Background(), syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		return resultByName(*record, ref.Name)
+	}
+
+	checkRuns := func() int {
+		data, _ := os.ReadFile(checks)
+		return strings.Count(string(data), "\n")
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-fix-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-fix-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), synapse.NewHistoryManager(historyDir), out)
+		checks = filepath.Join(synapseDir, "checks")
+		healthy = filepath.Join(synapseDir, "healthy")
+
+		writeNeuron(synapseDir, "check_service", `echo run >> `+checks+`; [ -f `+healthy+` ] || exit 3`)
+		writeNeuron(synapseDir, "restart_service", `touch `+healthy)
+		writeNeuron(synapseDir, "noop", `true`)
+		writeNeuron(synapseDir, "broken_fix", `exit 1`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("fixes a failed check and verifies it again", func() {
+		result := execute(synapse.NeuronRef{Name: "check_service", Fix: map[int]string{3: "restart_service"}})
+
+		Expect(result.Status).To(Equal("success"))
+		Expect(result.Remediation).To(Equal(synapse.RemediationFixed))
+		Expect(result.Fixes).To(HaveLen(1))
+		Expect(result.Fixes[0].Name).To(Equal("restart_service"))
+		Expect(result.Fixes[0].ForExitCode).To(Equal(3))
+		Expect(checkRuns()).To(Equal(2))
+		Expect(out.String()).To(ContainSubstring("Re-verifying: check_service"))
+	})
+
+	It("reports a fix that failed", func() {
+		result := execute(synapse.NeuronRef{Name: "check_service", Fix: map[int]string{3: "broken_fix"}})
+
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Remediation).To(Equal(synapse.RemediationFixFailed))
+		Expect(result.Fixes[0].Status).To(Equal("failed"))
+		Expect(checkRuns()).To(Equal(1))
+	})
+
+	It("stops after the maximum number of remediations", func() {
+		result := execute(synapse.NeuronRef{Name: "check_service", Fix: map[int]string{3: "noop"}, MaxRemediations: 2})
+
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Remediation).To(Equal(synapse.RemediationStillFailing))
+		Expect(result.Fixes).To(HaveLen(2))
+		Expect(checkRuns()).To(Equal(3))
+	})
+
+	It("leaves exit codes without a fix alone", func() {
+		result := execute(synapse.NeuronRef{Name: "check_service", Fix: map[int]string{7: "restart_service"}})
+
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Remediation).To(BeEmpty())
+		Expect(result.Fixes).To(BeEmpty())
+		Expect(healthy).NotTo(BeAnExistingFile())
+	})
+
+	It("rejects neurons that fix themselves", func() {
+		syn := &synapse.Synapse{Name: "loop", Neurons: []synapse.NeuronRef{{Name: "check_service", Fix: map[int]string{3: "check_service"}}}}
+		Expect(syn.Validate()).To(MatchError(`neuron check_service has invalid fix for exit code 3: "check_service"`))
+	})
+})
@@ -12,24 +12,67 @@ import (
 
 // ExecutionRecord represents a single execution of a synapse
 type ExecutionRecord struct {
-	ID            string          `json:"id"`
-	SynapseName   string          `json:"synapse_name"`
-	Timestamp     time.Time       `json:"timestamp"`
-	Status        string          `json:"status"` // "success", "failed", "partial"
-	Duration      time.Duration   `json:"duration"`
-	NeuronResults []NeuronResult  `json:"neuron_results"`
-	ErrorMessage  string          `json:"error_message,omitempty"`
+	ID             string            `json:"id"`
+	SynapseName    string            `json:"synapse_name"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Status         string            `json:"status"` // "success", "failed", "partial", "locked"
+	Duration       time.Duration     `json:"duration"`
+	NeuronResults  []NeuronResult    `json:"neuron_results"`
+	ErrorMessage   string            `json:"error_message,omitempty"`
+	ResumedFrom    string            `json:"resumed_from,omitempty"`    // ID of the execution this one resumed
+	Inputs         map[string]string `json:"inputs,omitempty"`          // resolved synapse inputs
+	ReadOnly       bool              `json:"read_only,omitempty"`       // mutate neurons were skipped
+	Schedule       string            `json:"schedule,omitempty"`        // schedule that started the execution
+	Lock           *LockContention   `json:"lock,omitempty"`            // set when the synapse was locked by another execution
+	Approvals      []ApprovalRecord  `json:"approvals,omitempty"`       // decisions on running mutate neurons
+	Rollbacks      []RollbackResult  `json:"rollbacks,omitempty"`       // compensating neurons that ran
+	RollbackStatus string            `json:"rollback_status,omitempty"` // "success" or "failed" when rollbacks ran
+}
+
+// RollbackResult records a compensating neuron run for a neuron
+type RollbackResult struct {
+	NeuronResult
+	For string `json:"for"` // neuron whose effects were compensated
 }
 
 // NeuronResult represents the execution result of a single neuron
 type NeuronResult struct {
-	Name     string        `json:"name"`
-	Status   string        `json:"status"` // "success", "failed", "skipped"
+	Name            string            `json:"name"`
+	Status          string            `json:"status"` // "success", "failed", "timed_out", "skipped"
+	ExitCode        int               `json:"exit_code"`
+	Duration        time.Duration     `json:"duration"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr"`
+	Error           string            `json:"error,omitempty"`
+	Reason          string            `json:"reason,omitempty"`           // why a neuron was skipped or failed
+	Reused          bool              `json:"reused,omitempty"`           // result carried over from a resumed execution
+	OutputTruncated bool              `json:"output_truncated,omitempty"` // Stdout/Stderr exceeded the capture cap
+	Outputs         map[string]string `json:"outputs,omitempty"`          // KEY=VALUE pairs written to $CORTEX_OUTPUT
+	Attempts        []AttemptResult   `json:"attempts,omitempty"`         // every attempt, when the neuron was retried
+	Fixes           []FixResult       `json:"fixes,omitempty"`            // fix neurons run for a failed check
+	Remediation     string            `json:"remediation,omitempty"`      // "fixed", "fix failed" or "still failing"
+	SubExecution    *ExecutionRecord  `json:"sub_execution,omitempty"`    // execution of a nested synapse
+	Item            map[string]string `json:"item,omitempty"`             // matrix variables of a single matrix item
+	Items           []NeuronResult    `json:"items,omitempty"`            // results per item of a matrix neuron
+	Poll            *PollResult       `json:"poll,omitempty"`             // how polling ended, for polled neurons
+}
+
+// FixResult records a fix neuron run for a failed check
+type FixResult struct {
+	NeuronResult
+	ForExitCode int `json:"for_exit_code"` // exit code of the check the fix is mapped to
+}
+
+// AttemptResult records a single attempt at running a neuron
+type AttemptResult struct {
+	Attempt  int           `json:"attempt"`
+	Delay    time.Duration `json:"delay,omitempty"` // wait before this attempt
 	ExitCode int           `json:"exit_code"`
 	Duration time.Duration `json:"duration"`
-	Stdout   string        `json:"stdout"`
-	Stderr   string        `json:"stderr"`
+	Stdout   string        `json:"stdout,omitempty"`
+	Stderr   string        `json:"stderr,omitempty"`
 	Error    string        `json:"error,omitempty"`
+	TimedOut bool          `json:"timed_out,omitempty"`
 }
 
 // HistoryManager manages execution history for synapses
@@ -0,0 +1,199 @@
+package synapse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InputType is the type of a declared synapse input
+type InputType string
+
+const (
+	InputString InputType = "string" // any value (default)
+	InputInt    InputType = "int"
+	InputNumber InputType = "number"
+	InputBool   InputType = "bool" // normalized to true or false
+)
+
+// Input declares a value a synapse is parameterized with
+type Input struct {
+	Name        string    `yaml:"name"`
+	Type        InputType `yaml:"type,omitempty"`
+	Description string    `yaml:"description,omitempty"`
+	Default     string    `yaml:"default,omitempty"`
+	Required    bool      `yaml:"required,omitempty"` // must be supplied when there is no default
+	Enum        []string  `yaml:"enum,omitempty"`     // allowed values
+	Regex       string    `yaml:"regex,omitempty"`    // the value must match this expression
+}
+
+var inputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validate checks the declaration of an input, including its default
+func (in Input) validate() error {
+	if !inputNamePattern.MatchString(in.Name) {
+		return fmt.Errorf("invalid input name %q", in.Name)
+	}
+	switch in.Type {
+	case "", InputString, InputInt, InputNumber, InputBool:
+	default:
+		return fmt.Errorf("input %s has unknown type %q", in.Name, in.Type)
+	}
+	if in.Regex != "" {
+		if _, err := regexp.Compile(in.Regex); err != nil {
+			return fmt.Errorf("input %s has invalid regex: %w", in.Name, err)
+		}
+	}
+	for _, value := range in.Enum {
+		if _, err := in.check(value); err != nil {
+			return fmt.Errorf("input %s has invalid enum value: %w", in.Name, err)
+		}
+	}
+	if in.Default != "" {
+		if _, err := in.check(in.Default); err != nil {
+			return fmt.Errorf("input %s has invalid default: %w", in.Name, err)
+		}
+	}
+	return nil
+}
+
+// check converts value to the type of the input and checks it against the
+// enum and regex, returning the normalized value
+func (in Input) check(value string) (string, error) {
+	switch in.Type {
+	case InputInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", fmt.Errorf("%q is not an int", value)
+		}
+	case InputNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+	case InputBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", value)
+		}
+		value = strconv.FormatBool(b)
+	}
+	if in.Regex != "" {
+		if matched, _ := regexp.MatchString(in.Regex, value); !matched {
+			return "", fmt.Errorf("%q does not match %s", value, in.Regex)
+		}
+	}
+	if len(in.Enum) > 0 {
+		allowed := false
+		for _, option := range in.Enum {
+			allowed = allowed || option == value
+		}
+		if !allowed {
+			return "", fmt.Errorf("%q is not one of %s", value, strings.Join(in.Enum, ", "))
+		}
+	}
+	return value, nil
+}
+
+// validateInputs checks the input declarations of the synapse
+func (s *Synapse) validateInputs() error {
+	seen := make(map[string]bool)
+	for _, in := range s.Inputs {
+		if seen[in.Name] {
+			return fmt.Errorf("duplicate input: %s", in.Name)
+		}
+		seen[in.Name] = true
+		if err := in.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveInputs checks values against the declared inputs and fills in
+// defaults. Synapses without declared inputs accept any values as given;
+// otherwise values for undeclared inputs are rejected.
+func (s *Synapse) ResolveInputs(values map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(values))
+	if len(s.Inputs) == 0 {
+		for k, v := range values {
+			resolved[k] = v
+		}
+		return resolved, nil
+	}
+
+	declared := make(map[string]bool, len(s.Inputs))
+	var problems []string
+	for _, in := range s.Inputs {
+		declared[in.Name] = true
+		value, ok := values[in.Name]
+		if !ok {
+			if in.Default == "" {
+				if in.Required {
+					problems = append(problems, fmt.Sprintf("input %s is required", in.Name))
+				}
+				continue
+			}
+			value = in.Default
+		}
+		checked, err := in.check(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("input %s: %v", in.Name, err))
+			continue
+		}
+		resolved[in.Name] = checked
+	}
+
+	var unknown []string
+	for name := range values {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown input %s", name))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid inputs: %s", strings.Join(problems, "; "))
+	}
+	return resolved, nil
+}
+
+// declaredInputs keeps the values of inputs the synapse declares, or all of
+// them when it declares none
+func (s *Synapse) declaredInputs(values map[string]string) map[string]string {
+	kept := make(map[string]string, len(values))
+	for k, v := range values {
+		kept[k] = v
+	}
+	if len(s.Inputs) == 0 {
+		return kept
+	}
+	declared := make(map[string]bool, len(s.Inputs))
+	for _, in := range s.Inputs {
+		declared[in.Name] = true
+	}
+	for k := range kept {
+		if !declared[k] {
+			delete(kept, k)
+		}
+	}
+	return kept
+}
+
+// inputEnv exports inputs to neurons as CORTEX_INPUT_<NAME>
+func inputEnv(inputs map[string]string) []string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, "CORTEX_INPUT_"+envName(name)+"="+inputs[name])
+	}
+	return env
+}
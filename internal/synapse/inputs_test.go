@@ -0,0 +1,125 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inputs", func() {
+	inputs := []synapse.Input{
+		{Name: "env", Required: true, Enum: []string{"staging", "production"}},
+		{Name: "replicas", Type: synapse.InputInt, Default: "3"},
+		{Name: "dry_run", Type: synapse.InputBool, Default: "false"},
+		{Name: "version", Regex: `^v\d+\.\d+$`},
+	}
+	syn := &synapse.Synapse{Name: "deploy", Inputs: inputs, Neurons: []synapse.NeuronRef{{Name: "deploy"}}}
+
+	It("fills in defaults and normalizes values", func() {
+		resolved, err := syn.ResolveInputs(map[string]string{"env": "staging", "dry_run": "1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved).To(Equal(map[string]string{"env": "staging", "replicas": "3", "dry_run": "true"}))
+	})
+
+	It("reports every invalid value", func() {
+		_, err := syn.ResolveInputs(map[string]string{"replicas": "many", "version": "2.0", "region": "eu"})
+		Expect(err).To(MatchError(`invalid inputs: input env is required; input replicas: "many" is not an int; ` +
+			`input version: "2.0" does not match ^v\d+\.\d+$; unknown input region`))
+	})
+
+	It("rejects values outside the enum", func() {
+		_, err := syn.ResolveInputs(map[string]string{"env": "dev"})
+		Expect(err).To(MatchError(`invalid inputs: input env: "dev" is not one of staging, production`))
+	})
+
+	It("accepts any values when no inputs are declared", func() {
+		undeclared := &synapse.Synapse{Name: "free", Neurons: []synapse.NeuronRef{{Name: "deploy"}}}
+		resolved, err := undeclared.ResolveInputs(map[string]string{"anything": "goes"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved).To(Equal(map[string]string{"anything": "goes"}))
+	})
+
+	DescribeTable("rejects invalid declarations",
+		func(input synapse.Input, message string) {
+			invalid := &synapse.Synapse{Name: "invalid", Inputs: []synapse.Input{input}, Neurons: []synapse.NeuronRef{{Name: "deploy"}}}
+			Expect(invalid.Validate()).To(MatchError(message))
+		},
+		Entry("bad name", synapse.Input{Name: "my-input"}, `invalid input name "my-input"`),
+		Entry("unknown type", synapse.Input{Name: "n", Type: "list"}, `input n has unknown type "list"`),
+		Entry("bad regex", synapse.Input{Name: "n", Regex: "("}, "input n has invalid regex: error parsing regexp: missing closing ): `(`"),
+		Entry("bad default", synapse.Input{Name: "n", Type: synapse.InputInt, Default: "x"}, `input n has invalid default: "x" is not an int`),
+		Entry("bad enum", synapse.Input{Name: "n", Type: synapse.InputBool, Enum: []string{"maybe"}}, `input n has invalid enum value: "maybe" is not a bool`),
+	)
+
+	Describe("execution", func() {
+		var (
+			synapseDir string
+			historyDir string
+			executor   *synapse.Executor
+			history    *synapse.HistoryManager
+		)
+
+		BeforeEach(func() {
+			var err error
+			synapseDir, err = os.MkdirTemp("", "cortex-inputs-test-*")
+			Expect(err).NotTo(HaveOccurred())
+			historyDir, err = os.MkdirTemp("", "cortex-inputs-history-*")
+			Expect(err).NotTo(HaveOccurred())
+
+			history = synapse.NewHistoryManager(historyDir)
+			executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, &bytes.Buffer{})
+			writeNeuron(synapseDir, "deploy", `echo "$CORTEX_INPUT_ENV $CORTEX_INPUT_REPLICAS $CORTEX_INPUT_DRY_RUN"`)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(synapseDir)
+			os.RemoveAll(historyDir)
+		})
+
+		It("exports inputs to neurons and records them", func() {
+			executor.SetEnvironment(map[string]string{"env": "production"})
+			record, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultByName(*record, "deploy").Stdout).To(Equal("production 3 false\n"))
+			Expect(record.Inputs).To(Equal(map[string]string{"env": "production", "replicas": "3", "dry_run": "false"}))
+		})
+
+		It("does not execute with invalid inputs", func() {
+			executor.SetEnvironment(map[string]string{"env": "dev"})
+			_, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).To(MatchError(ContainSubstring(`input env: "dev" is not one of staging, production`)))
+
+			records, err := history.GetHistory("deploy")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(BeEmpty())
+		})
+
+		It("passes only declared inputs to sub-synapses", func() {
+			childDir := filepath.Join(synapseDir, "child")
+			writeNeuron(childDir, "deploy", `echo "${CORTEX_INPUT_ENV:-none} $CORTEX_INPUT_REPLICAS"`)
+			Expect(os.WriteFile(filepath.Join(childDir, synapse.ConfigFile), []byte(`apiVersion: cortex/v1
+name: child
+inputs:
+  - name: replicas
+    type: int
+neurons: [deploy]
+`), 0644)).To(Succeed())
+
+			parent := &synapse.Synapse{Name: "parent", Neurons: []synapse.NeuronRef{{Name: "child", Synapse: "child"}}}
+			executor.SetEnvironment(map[string]string{"env": "staging", "replicas": "5"})
+			record, err := executor.Run(context.Background(), parent, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			sub := resultByName(*record, "child").SubExecution
+			Expect(sub.Inputs).To(Equal(map[string]string{"replicas": "5"}))
+			Expect(resultByName(*sub, "deploy").Stdout).To(Equal("none 5\n"))
+		})
+	})
+})
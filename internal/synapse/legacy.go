@@ -0,0 +1,92 @@
+package synapse
+
+import (
+	"fmt"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"gopkg.in/yaml.v2"
+)
+
+// legacySynapse is the original synapse.yaml layout: neuron locations in
+// definition and the order to run them in plan.steps
+type legacySynapse struct {
+	Name       string              `yaml:"name"`
+	Definition []neuron.Definition `yaml:"definition"`
+	Plan       *legacyPlan         `yaml:"plan"`
+}
+
+type legacyPlan struct {
+	Config legacyPlanConfig `yaml:"config"`
+	Steps  legacySteps      `yaml:"steps"`
+}
+
+type legacyPlanConfig struct {
+	ExitOnFirstError bool `yaml:"exit_on_first_error"`
+}
+
+type legacySteps struct {
+	Serial   []string `yaml:"serial"`
+	Parallel []string `yaml:"parallel"`
+}
+
+// isLegacy reports whether data holds a synapse in the legacy plan format
+func isLegacy(data []byte) bool {
+	var probe struct {
+		APIVersion string      `yaml:"apiVersion"`
+		Plan       interface{} `yaml:"plan"`
+	}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.APIVersion == "" && probe.Plan != nil
+}
+
+// MigrateLegacy converts a synapse in the legacy plan format to the current
+// schema. Serial steps run one after the other; parallel steps run
+// concurrently once the serial steps are done. A failing step only stops the
+// steps after it when exit_on_first_error is set, as before.
+func MigrateLegacy(data []byte) (*Synapse, error) {
+	var legacy legacySynapse
+	if err := yaml.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("failed to parse synapse config: %w", err)
+	}
+	if legacy.Plan == nil {
+		return nil, fmt.Errorf("synapse config has no plan to migrate")
+	}
+
+	synapse := &Synapse{
+		APIVersion:  APIVersion,
+		Name:        legacy.Name,
+		Definition:  legacy.Definition,
+		Execution:   ExecutionSequential,
+		StopOnError: legacy.Plan.Config.ExitOnFirstError,
+	}
+
+	steps := legacy.Plan.Steps
+	if len(steps.Parallel) == 0 {
+		for _, name := range steps.Serial {
+			synapse.Neurons = append(synapse.Neurons, NeuronRef{Name: name})
+		}
+		return synapse, nil
+	}
+
+	// Order the serial steps through dependencies so that the parallel
+	// steps can share a parallel execution with them
+	synapse.Execution = ExecutionParallel
+	previous := ""
+	after := func(ref NeuronRef) NeuronRef {
+		if previous != "" {
+			ref.DependsOn = []string{previous}
+			ref.TriggerRule = TriggerAlways
+		}
+		return ref
+	}
+	for _, name := range steps.Serial {
+		synapse.Neurons = append(synapse.Neurons, after(NeuronRef{Name: name}))
+		previous = name
+	}
+	for _, name := range steps.Parallel {
+		synapse.Neurons = append(synapse.Neurons, after(NeuronRef{Name: name}))
+	}
+	return synapse, nil
+}
@@ -8,25 +8,39 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-// LoadFromDirectory loads a synapse configuration from a directory
-func LoadFromDirectory(dir string) (*Synapse, error) {
-	configPath := filepath.Join(dir, "config.yml")
+const (
+	// ConfigFile is the synapse config file in a synapse directory
+	ConfigFile = "config.yml"
+	// LegacyConfigFile is the config file of synapses in the legacy plan format
+	LegacyConfigFile = "synapse.yaml"
+)
 
-	// Check if config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("synapse config not found: %s", configPath)
+// FindConfig returns the path of the synapse config in dir, preferring
+// config.yml over a legacy synapse.yaml
+func FindConfig(dir string) (string, error) {
+	for _, name := range []string{ConfigFile, LegacyConfigFile} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
 	}
+	return "", fmt.Errorf("synapse config not found: %s", filepath.Join(dir, ConfigFile))
+}
 
-	// Read config file
-	data, err := os.ReadFile(configPath)
+// LoadFromDirectory loads a synapse configuration from a directory
+func LoadFromDirectory(dir string) (*Synapse, error) {
+	configPath, err := FindConfig(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read synapse config: %w", err)
+		return nil, err
 	}
+	return LoadFromFile(configPath)
+}
 
-	// Parse YAML
-	var synapse Synapse
-	if err := yaml.Unmarshal(data, &synapse); err != nil {
-		return nil, fmt.Errorf("failed to parse synapse config: %w", err)
+// LoadFromFile loads a synapse configuration from a specific file
+func LoadFromFile(path string) (*Synapse, error) {
+	synapse, err := parseFile(path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate synapse configuration
@@ -34,27 +48,37 @@ func LoadFromDirectory(dir string) (*Synapse, error) {
 		return nil, fmt.Errorf("invalid synapse configuration: %w", err)
 	}
 
-	return &synapse, nil
+	return synapse, nil
 }
 
-// LoadFromFile loads a synapse configuration from a specific file
-func LoadFromFile(path string) (*Synapse, error) {
+// parseFile reads and parses a synapse configuration without validating it
+func parseFile(path string) (*Synapse, error) {
 	// Read config file
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read synapse config: %w", err)
 	}
 
-	// Parse YAML
-	var synapse Synapse
-	if err := yaml.Unmarshal(data, &synapse); err != nil {
-		return nil, fmt.Errorf("failed to parse synapse config: %w", err)
+	synapse, err := Parse(data)
+	if err != nil {
+		return nil, err
 	}
+	if synapse.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return synapse, nil
+}
 
-	// Validate synapse configuration
-	if err := synapse.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid synapse configuration: %w", err)
+// Parse parses a synapse configuration without validating it. Configurations
+// in the legacy plan format are converted to the current schema.
+func Parse(data []byte) (*Synapse, error) {
+	if isLegacy(data) {
+		return MigrateLegacy(data)
 	}
 
+	var synapse Synapse
+	if err := yaml.Unmarshal(data, &synapse); err != nil {
+		return nil, fmt.Errorf("failed to parse synapse config: %w", err)
+	}
 	return &synapse, nil
 }
@@ -0,0 +1,205 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+const legacyConfig = `name: system_health_check
+definition:
+  - neuron: check_disk
+    config:
+      path: check_disk
+      fix:
+        110: clean_disk
+  - neuron: check_memory
+    config:
+      path: check_memory
+  - neuron: clean_disk
+    config:
+      path: clean_disk
+plan:
+  config:
+    exit_on_first_error: %v
+  steps:
+    serial:
+      - check_disk
+      - check_memory
+    parallel: %s
+`
+
+var _ = Describe("Loader", func() {
+	var synapseDir string
+
+	writeConfig := func(name, content string) {
+		Expect(os.WriteFile(filepath.Join(synapseDir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	// writeLegacyNeuron writes a neuron in the legacy <name>/neuron.yaml layout
+	writeLegacyNeuron := func(name, script string, assertExitStatus ...string) {
+		dir := filepath.Join(synapseDir, name)
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		scriptPath := filepath.Join(dir, "run.sh")
+		Expect(os.WriteFile(scriptPath, []byte("#!/bin/bash\n"+script+"\n"), 0755)).To(Succeed())
+		config := fmt.Sprintf("name: %s\ntype: check\nexec_file: %s\nassert_exit_status: [%s]\n", name, scriptPath, strings.Join(assertExitStatus, ", "))
+		Expect(os.WriteFile(filepath.Join(dir, "neuron.yaml"), []byte(config), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-loader-test-*")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+	})
+
+	Describe("legacy synapse.yaml", func() {
+		It("runs serial steps in order", func() {
+			writeConfig(synapse.LegacyConfigFile, fmt.Sprintf(legacyConfig, true, "[]"))
+
+			syn, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syn.APIVersion).To(Equal(synapse.APIVersion))
+			Expect(syn.Execution).To(Equal(synapse.ExecutionSequential))
+			Expect(syn.StopOnError).To(BeTrue())
+			Expect(syn.Neurons).To(Equal([]synapse.NeuronRef{{Name: "check_disk"}, {Name: "check_memory"}}))
+			Expect(syn.Definition).To(HaveLen(3))
+		})
+
+		It("runs parallel steps after the serial ones", func() {
+			writeConfig(synapse.LegacyConfigFile, fmt.Sprintf(legacyConfig, false, "[clean_disk]"))
+
+			syn, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(syn.Execution).To(Equal(synapse.ExecutionParallel))
+			Expect(syn.Neurons).To(Equal([]synapse.NeuronRef{
+				{Name: "check_disk"},
+				{Name: "check_memory", DependsOn: []string{"check_disk"}, TriggerRule: synapse.TriggerAlways},
+				{Name: "clean_disk", DependsOn: []string{"check_memory"}, TriggerRule: synapse.TriggerAlways},
+			}))
+		})
+
+		It("executes neurons from their definitions and runs mapped fixes", func() {
+			writeConfig(synapse.LegacyConfigFile, fmt.Sprintf(legacyConfig, false, "[]"))
+			writeLegacyNeuron("check_disk", "exit 110")
+			writeLegacyNeuron("check_memory", "exit 3", "0", "3")
+			writeLegacyNeuron("clean_disk", "echo cleaned")
+
+			syn, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			historyDir, err := os.MkdirTemp("", "cortex-loader-history-*")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(historyDir)
+
+			out := &bytes.Buffer{}
+			executor := synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), synapse.NewHistoryManager(historyDir), out)
+			record, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultByName(*record, "check_disk").Status).To(Equal("failed"))
+			Expect(resultByName(*record, "check_memory").Status).To(Equal("success"), "exit 3 is asserted")
+			Expect(resultByName(*record, "check_memory").ExitCode).To(Equal(3))
+			Expect(out.String()).To(ContainSubstring("Fixing check_disk with clean_disk"))
+			checkDisk := resultByName(*record, "check_disk")
+			Expect(checkDisk.Remediation).To(Equal(synapse.RemediationStillFailing))
+			Expect(checkDisk.Fixes).To(HaveLen(1))
+			Expect(checkDisk.Fixes[0].Name).To(Equal("clean_disk"))
+			Expect(checkDisk.Fixes[0].Stdout).To(Equal("cleaned\n"))
+		})
+
+		It("finds neurons by paths relative to the working directory", func() {
+			workDir, err := os.MkdirTemp("", "cortex-loader-cwd-*")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(workDir)
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(workDir)).To(Succeed())
+			defer os.Chdir(wd)
+
+			writeConfig(synapse.LegacyConfigFile, "name: relative\ndefinition:\n  - neuron: check_disk\n    config:\n      path: neurons/check_disk\n  - neuron: check_memory\n    config:\n      path: check_memory\nplan:\n  steps:\n    serial: [check_disk, check_memory]\n")
+			writeLegacyNeuron("check_memory", "echo synapse dir")
+			neuronDir := filepath.Join(workDir, "neurons", "check_disk")
+			Expect(os.MkdirAll(neuronDir, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(neuronDir, "run.sh"), []byte("#!/bin/bash\necho working dir\n"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(neuronDir, "neuron.yaml"), []byte("name: check_disk\ntype: check\nexec_file: "+filepath.Join(neuronDir, "run.sh")+"\n"), 0644)).To(Succeed())
+
+			syn, err := synapse.LoadFromDirectory(synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			executor := synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), nil, &bytes.Buffer{})
+			record, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resultByName(*record, "check_disk").Stdout).To(Equal("working dir\n"))
+			Expect(resultByName(*record, "check_memory").Stdout).To(Equal("synapse dir\n"))
+		})
+
+		It("can be migrated and loaded back", func() {
+			writeConfig(synapse.LegacyConfigFile, fmt.Sprintf(legacyConfig, false, "[clean_disk]"))
+			data, err := os.ReadFile(filepath.Join(synapseDir, synapse.LegacyConfigFile))
+			Expect(err).NotTo(HaveOccurred())
+
+			migrated, err := synapse.MigrateLegacy(data)
+			Expect(err).NotTo(HaveOccurred())
+
+			data, err = yaml.Marshal(migrated)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(HavePrefix("apiVersion: cortex/v1\n"))
+
+			reloaded, err := synapse.Parse(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(Equal(migrated))
+		})
+	})
+
+	It("prefers config.yml over synapse.yaml", func() {
+		writeConfig(synapse.LegacyConfigFile, fmt.Sprintf(legacyConfig, false, "[]"))
+		writeConfig(synapse.ConfigFile, "apiVersion: cortex/v1\nname: current\nneurons: [check_disk]\n")
+
+		syn, err := synapse.LoadFromDirectory(synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(syn.Name).To(Equal("current"))
+	})
+
+	It("rejects unknown schema versions", func() {
+		writeConfig(synapse.ConfigFile, "apiVersion: cortex/v9\nname: future\nneurons: [check_disk]\n")
+
+		_, err := synapse.LoadFromDirectory(synapseDir)
+		Expect(err).To(MatchError(ContainSubstring(`unsupported apiVersion "cortex/v9"`)))
+	})
+
+	It("runs synapses without neurons as a no-op", func() {
+		writeConfig(synapse.ConfigFile, "apiVersion: cortex/v1\nname: scaffold\nneurons: []\nexecution: sequential\n")
+
+		syn, err := synapse.LoadFromDirectory(synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		record, err := synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), nil, &bytes.Buffer{}).Run(context.Background(), syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Status).To(Equal("success"))
+		Expect(record.NeuronResults).To(BeEmpty())
+	})
+
+	It("loads legacy synapses with an empty plan", func() {
+		writeConfig(synapse.LegacyConfigFile, "name: empty\nplan:\n  steps:\n    serial: []\n")
+
+		syn, err := synapse.LoadFromDirectory(synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(syn.Neurons).To(BeEmpty())
+	})
+
+	It("reports a missing config", func() {
+		_, err := synapse.LoadFromDirectory(synapseDir)
+		Expect(err).To(MatchError("synapse config not found: " + filepath.Join(synapseDir, synapse.ConfigFile)))
+	})
+})
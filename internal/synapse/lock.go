@@ -0,0 +1,257 @@
+package synapse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// lockPollInterval is how often a waiting execution checks the lock again
+const lockPollInterval = 200 * time.Millisecond
+
+// ErrLocked is returned when a synapse is already being executed
+var ErrLocked = errors.New("synapse is locked")
+
+// LockHolder describes the process holding the lock of a synapse
+type LockHolder struct {
+	PID        int       `json:"pid"`
+	Host       string    `json:"host"`
+	User       string    `json:"user,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+func (h LockHolder) String() string {
+	holder := fmt.Sprintf("pid %d on %s", h.PID, h.Host)
+	if h.User != "" {
+		holder += " (" + h.User + ")"
+	}
+	return holder + " since " + h.AcquiredAt.Format(time.RFC3339)
+}
+
+// LockedError is returned by LockManager.Acquire when another process holds
+// the lock. It matches ErrLocked.
+type LockedError struct {
+	Synapse string
+	Holder  LockHolder
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("synapse %s is already running: locked by %s", e.Synapse, e.Holder)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// LockContention records that an execution found its synapse locked
+type LockContention struct {
+	Holder LockHolder    `json:"holder"`
+	Waited time.Duration `json:"waited,omitempty"` // how long the execution waited for the lock
+}
+
+// LockPolicy decides what an execution does when its synapse is locked
+type LockPolicy struct {
+	// FailIfLocked fails at once instead of waiting for the lock
+	FailIfLocked bool
+	// Wait bounds how long to wait for the lock; zero waits until it is free
+	Wait time.Duration
+}
+
+// LockManager keeps a lock file per synapse, so that the same synapse is not
+// executed by two processes at once
+type LockManager struct {
+	dir string
+}
+
+// NewLockManager creates a lock manager keeping lock files in dir
+func NewLockManager(dir string) *LockManager {
+	return &LockManager{dir: dir}
+}
+
+// NewDefaultLockManager creates a lock manager using ~/.cortex/locks
+func NewDefaultLockManager() (*LockManager, error) {
+	home, err := GetHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewLockManager(filepath.Join(home, ".cortex", "locks")), nil
+}
+
+// Lock is a held synapse lock
+type Lock struct {
+	path   string
+	holder LockHolder
+}
+
+// Release removes the lock, unless it was taken over in the meantime
+func (l *Lock) Release() error {
+	current, err := readLock(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !current.same(l.holder) {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+// path returns the lock file of a synapse
+func (m *LockManager) path(synapseName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, synapseName)
+	return filepath.Join(m.dir, name+".lock")
+}
+
+// Holder returns the process holding the lock of a synapse. Stale locks, left
+// behind by processes that no longer run, are not reported.
+func (m *LockManager) Holder(synapseName string) (LockHolder, bool, error) {
+	holder, err := readLock(m.path(synapseName))
+	if os.IsNotExist(err) {
+		return LockHolder{}, false, nil
+	}
+	if err != nil {
+		return LockHolder{}, false, err
+	}
+	if holder.stale() {
+		return LockHolder{}, false, nil
+	}
+	return holder, true, nil
+}
+
+// TryAcquire takes the lock of a synapse, replacing a stale lock. It returns
+// a *LockedError if another process holds it.
+func (m *LockManager) TryAcquire(synapseName string) (*Lock, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	holder := LockHolder{PID: os.Getpid(), Host: host, AcquiredAt: time.Now().UTC()}
+	if current, err := user.Current(); err == nil {
+		holder.User = current.Username
+	}
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock is written to a temporary file and linked into place, which
+	// fails if the lock exists, so that a lock file is never seen half written
+	path := m.path(synapseName)
+	tmp := fmt.Sprintf("%s.%s.tmp", path, uuid.New().String())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write lock: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	for i := 0; i < 2; i++ {
+		err := os.Link(tmp, path)
+		if err == nil {
+			return &Lock{path: path, holder: holder}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock: %w", err)
+		}
+
+		current, readErr := readLock(path)
+		if os.IsNotExist(readErr) {
+			continue // released meanwhile
+		}
+		if readErr == nil && !current.stale() {
+			return nil, &LockedError{Synapse: synapseName, Holder: current}
+		}
+		// Only remove a stale or unreadable lock if nobody replaced it yet
+		if again, err := readLock(path); readErr != nil || (err == nil && again.same(current)) {
+			os.Remove(path)
+		}
+	}
+	current, _ := readLock(path)
+	return nil, &LockedError{Synapse: synapseName, Holder: current}
+}
+
+// Acquire takes the lock of a synapse according to policy. When it has to
+// wait, waiting is called once with the current holder. The returned
+// contention is nil if the lock was free.
+func (m *LockManager) Acquire(ctx context.Context, synapseName string, policy LockPolicy, waiting func(LockHolder)) (*Lock, *LockContention, error) {
+	start := time.Now()
+	var contention *LockContention
+
+	for {
+		lock, err := m.TryAcquire(synapseName)
+		if err == nil {
+			if contention != nil {
+				contention.Waited = time.Since(start).Round(time.Millisecond)
+			}
+			return lock, contention, nil
+		}
+
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return nil, contention, err
+		}
+		if contention == nil {
+			contention = &LockContention{Holder: locked.Holder}
+			if !policy.FailIfLocked && waiting != nil {
+				waiting(locked.Holder)
+			}
+		}
+		if policy.FailIfLocked {
+			return nil, contention, err
+		}
+		if policy.Wait > 0 && time.Since(start) >= policy.Wait {
+			contention.Waited = time.Since(start).Round(time.Millisecond)
+			return nil, contention, fmt.Errorf("%w (waited %v)", err, policy.Wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			contention.Waited = time.Since(start).Round(time.Millisecond)
+			return nil, contention, fmt.Errorf("%w (stopped waiting: %v)", err, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// readLock reads the holder of a lock file
+func readLock(path string) (LockHolder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LockHolder{}, err
+	}
+	var holder LockHolder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return LockHolder{}, fmt.Errorf("invalid lock file: %w", err)
+	}
+	return holder, nil
+}
+
+// same reports whether both describe the same acquisition of a lock
+func (h LockHolder) same(other LockHolder) bool {
+	return h.PID == other.PID && h.Host == other.Host && h.AcquiredAt.Equal(other.AcquiredAt)
+}
+
+// stale reports whether the holder is a process of this host that no longer
+// runs. Locks of other hosts sharing the directory are never considered stale.
+func (h LockHolder) stale() bool {
+	host, err := os.Hostname()
+	if err != nil || host != h.Host {
+		return false
+	}
+	return !processAlive(h.PID)
+}
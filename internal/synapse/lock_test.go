@@ -0,0 +1,208 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Synapse locks", func() {
+	var (
+		lockDir string
+		locks   *synapse.LockManager
+	)
+
+	// writeLock writes a lock file as if held by pid on host
+	writeLock := func(name string, pid int, host string) {
+		data, err := json.Marshal(synapse.LockHolder{PID: pid, Host: host, AcquiredAt: time.Now()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(lockDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(lockDir, name+".lock"), data, 0644)).To(Succeed())
+	}
+
+	// exitedPID returns the pid of a process that is no longer running
+	exitedPID := func() int {
+		cmd := exec.Command("true")
+		Expect(cmd.Run()).To(Succeed())
+		return cmd.Process.Pid
+	}
+
+	BeforeEach(func() {
+		var err error
+		lockDir, err = os.MkdirTemp("", "cortex-lock-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		locks = synapse.NewLockManager(lockDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(lockDir)
+	})
+
+	It("lets one process hold the lock of a synapse at a time", func() {
+		lock, err := locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = locks.TryAcquire("restart-pods")
+		Expect(err).To(MatchError(synapse.ErrLocked))
+		var locked *synapse.LockedError
+		Expect(errors.As(err, &locked)).To(BeTrue())
+		Expect(locked.Holder.PID).To(Equal(os.Getpid()))
+		Expect(err.Error()).To(HavePrefix("synapse restart-pods is already running: locked by pid "))
+
+		holder, held, err := locks.Holder("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(held).To(BeTrue())
+		Expect(holder.PID).To(Equal(os.Getpid()))
+
+		_, err = locks.TryAcquire("other-synapse")
+		Expect(err).NotTo(HaveOccurred(), "locks are per synapse")
+
+		Expect(lock.Release()).To(Succeed())
+		_, held, _ = locks.Holder("restart-pods")
+		Expect(held).To(BeFalse())
+		_, err = locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("takes over locks of processes that no longer run", func() {
+		host, err := os.Hostname()
+		Expect(err).NotTo(HaveOccurred())
+		writeLock("restart-pods", exitedPID(), host)
+
+		_, held, err := locks.Holder("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(held).To(BeFalse())
+
+		_, err = locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("does not take over locks of other hosts", func() {
+		writeLock("restart-pods", exitedPID(), "some-other-host")
+
+		_, err := locks.TryAcquire("restart-pods")
+		Expect(err).To(MatchError(synapse.ErrLocked))
+	})
+
+	It("replaces unreadable lock files", func() {
+		Expect(os.WriteFile(filepath.Join(lockDir, "restart-pods.lock"), []byte("garbage"), 0644)).To(Succeed())
+
+		_, err := locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("waits for the lock to be released", func() {
+		lock, err := locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+		time.AfterFunc(300*time.Millisecond, func() { lock.Release() })
+
+		var waitedFor synapse.LockHolder
+		_, contention, err := locks.Acquire(context.Background(), "restart-pods", synapse.LockPolicy{}, func(holder synapse.LockHolder) {
+			waitedFor = holder
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(waitedFor.PID).To(Equal(os.Getpid()))
+		Expect(contention.Holder.PID).To(Equal(os.Getpid()))
+		Expect(contention.Waited).To(BeNumerically(">=", 200*time.Millisecond))
+	})
+
+	It("gives up waiting after the configured time", func() {
+		_, err := locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, contention, err := locks.Acquire(context.Background(), "restart-pods", synapse.LockPolicy{Wait: 300 * time.Millisecond}, nil)
+		Expect(err).To(MatchError(synapse.ErrLocked))
+		Expect(err.Error()).To(HaveSuffix("(waited 300ms)"))
+		Expect(contention.Waited).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	Describe("executions", func() {
+		var (
+			synapseDir string
+			historyDir string
+			executor   *synapse.Executor
+			history    *synapse.HistoryManager
+			out        *bytes.Buffer
+			syn        *synapse.Synapse
+		)
+
+		BeforeEach(func() {
+			var err error
+			synapseDir, err = os.MkdirTemp("", "cortex-lock-synapse-*")
+			Expect(err).NotTo(HaveOccurred())
+			historyDir, err = os.MkdirTemp("", "cortex-lock-history-*")
+			Expect(err).NotTo(HaveOccurred())
+
+			out = &bytes.Buffer{}
+			history = synapse.NewHistoryManager(historyDir)
+			executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+			writeNeuron(synapseDir, "restart", "echo restarted")
+			syn = &synapse.Synapse{Name: "restart-pods", Neurons: []synapse.NeuronRef{{Name: "restart"}}}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(synapseDir)
+			os.RemoveAll(historyDir)
+		})
+
+		It("hold the lock while running and release it afterwards", func() {
+			var heldDuringRun bool
+			executor.Subscribe(synapse.ObserverFunc(func(event synapse.Event) {
+				if _, ok := event.(synapse.NeuronStarted); ok {
+					_, heldDuringRun, _ = locks.Holder("restart-pods")
+				}
+			}))
+			executor.SetLocks(locks, synapse.LockPolicy{})
+
+			record, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.Lock).To(BeNil())
+			Expect(heldDuringRun).To(BeTrue())
+
+			_, held, _ := locks.Holder("restart-pods")
+			Expect(held).To(BeFalse())
+		})
+
+		It("are refused and recorded when the synapse is locked", func() {
+			_, err := locks.TryAcquire("restart-pods")
+			Expect(err).NotTo(HaveOccurred())
+			executor.SetLocks(locks, synapse.LockPolicy{FailIfLocked: true})
+
+			err = executor.Execute(context.Background(), syn, synapseDir)
+			Expect(err).To(MatchError(synapse.ErrLocked))
+			Expect(out.String()).NotTo(ContainSubstring("restarted"))
+
+			records, err := history.GetHistory("restart-pods")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Status).To(Equal("locked"))
+			Expect(records[0].ErrorMessage).To(ContainSubstring("already running"))
+			Expect(records[0].Lock.Holder.PID).To(Equal(os.Getpid()))
+			Expect(records[0].NeuronResults).To(BeEmpty())
+		})
+
+		It("record how long they waited for the lock", func() {
+			lock, err := locks.TryAcquire("restart-pods")
+			Expect(err).NotTo(HaveOccurred())
+			time.AfterFunc(300*time.Millisecond, func() { lock.Release() })
+			executor.SetLocks(locks, synapse.LockPolicy{})
+
+			record, err := executor.Run(context.Background(), syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.Status).To(Equal("success"))
+			Expect(record.Lock).NotTo(BeNil())
+			Expect(record.Lock.Waited).To(BeNumerically(">=", 200*time.Millisecond))
+			Expect(out.String()).To(ContainSubstring("Waiting for restart-pods: locked by pid"))
+		})
+	})
+})
@@ -0,0 +1,245 @@
+package synapse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMatrixVariable holds the values a matrix takes from an upstream output
+const defaultMatrixVariable = "ITEM"
+
+// matrixItem is one set of matrix variables, in a stable order
+type matrixItem struct {
+	names  []string
+	values map[string]string
+}
+
+// label names the execution of neuron for the item, e.g. check[namespace=web]
+func (item matrixItem) label(neuron string) string {
+	pairs := make([]string, len(item.names))
+	for i, name := range item.names {
+		pairs[i] = name + "=" + item.values[name]
+	}
+	return neuron + "[" + strings.Join(pairs, ",") + "]"
+}
+
+// env exports the item both as NAME and as CORTEX_MATRIX_<NAME>
+func (item matrixItem) env() []string {
+	var env []string
+	for _, name := range item.names {
+		value := item.values[name]
+		env = append(env, name+"="+value, "CORTEX_MATRIX_"+envName(name)+"="+value)
+	}
+	return env
+}
+
+// validate checks the matrix of neuronRef
+func (m *Matrix) validate(neuronRef NeuronRef) error {
+	if m == nil {
+		return nil
+	}
+	if neuronRef.Synapse != "" {
+		return fmt.Errorf("cannot be combined with synapse")
+	}
+	if len(m.Values) == 0 && m.From == "" {
+		return fmt.Errorf("values or from is required")
+	}
+	for name, values := range m.Values {
+		if len(values) == 0 {
+			return fmt.Errorf("variable %s has no values", name)
+		}
+	}
+	if m.From != "" {
+		producer, _, ok := m.source()
+		if !ok {
+			return fmt.Errorf("from must be <neuron>.<OUTPUT>, got %q", m.From)
+		}
+		isDependency := false
+		for _, dep := range neuronRef.DependsOn {
+			isDependency = isDependency || dep == producer
+		}
+		if !isDependency {
+			return fmt.Errorf("from references %s, which is not in dependsOn", producer)
+		}
+	}
+	switch m.Pass {
+	case "", MatrixAll, MatrixAny:
+	case MatrixThreshold:
+		if _, err := m.required(100); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown pass rule %q", m.Pass)
+	}
+	return nil
+}
+
+// source splits From into the producing neuron and the output key
+func (m *Matrix) source() (string, string, bool) {
+	i := strings.LastIndex(m.From, ".")
+	if i <= 0 || i == len(m.From)-1 {
+		return "", "", false
+	}
+	return m.From[:i], m.From[i+1:], true
+}
+
+// required returns how many of total items must pass under the threshold rule
+func (m *Matrix) required(total int) (int, error) {
+	threshold := strings.TrimSpace(m.Threshold)
+	if strings.HasSuffix(threshold, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, fmt.Errorf("invalid threshold %q", m.Threshold)
+		}
+		return int(math.Ceil(float64(total) * percent / 100)), nil
+	}
+	count, err := strconv.Atoi(threshold)
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid threshold %q", m.Threshold)
+	}
+	return count, nil
+}
+
+// staticItems returns the number of items formed by Values alone
+func (m *Matrix) staticItems() int {
+	count := 1
+	for _, values := range m.Values {
+		count *= len(values)
+	}
+	return count
+}
+
+// items expands the matrix into every combination of its values, taking the
+// values of From from the outputs in upstream
+func (m *Matrix) items(upstream map[string]NeuronResult) []matrixItem {
+	names := make([]string, 0, len(m.Values)+1)
+	for name := range m.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string][]string, len(names)+1)
+	for _, name := range names {
+		values[name] = m.Values[name]
+	}
+	if producer, key, ok := m.source(); ok {
+		as := m.As
+		if as == "" {
+			as = defaultMatrixVariable
+		}
+		names = append(names, as)
+		values[as] = strings.FieldsFunc(upstream[producer].Outputs[key], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n'
+		})
+	}
+
+	combinations := []map[string]string{{}}
+	for _, name := range names {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range values[name] {
+				item := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					item[k] = v
+				}
+				item[name] = value
+				next = append(next, item)
+			}
+		}
+		combinations = next
+	}
+
+	items := make([]matrixItem, len(combinations))
+	for i, combination := range combinations {
+		items[i] = matrixItem{names: names, values: combination}
+	}
+	return items
+}
+
+// passed applies the pass rule to the number of passing items
+func (m *Matrix) passed(passing, total int) bool {
+	switch m.Pass {
+	case MatrixAny:
+		return passing > 0
+	case MatrixThreshold:
+		required, err := m.required(total)
+		return err == nil && passing >= required
+	default:
+		return passing == total
+	}
+}
+
+// runMatrix executes a neuron once per matrix item, each item taking one of the
+// execution's concurrency slots (one at a time in sequential synapses that do
+// not set maxConcurrency), and decides the outcome of the neuron with the
+// matrix pass rule
+func (e *Executor) runMatrix(ctx context.Context, run *executionRun, neuronRef NeuronRef, upstream map[string]NeuronResult) NeuronResult {
+	matrix := neuronRef.Matrix
+	result := NeuronResult{Name: neuronRef.Name}
+	startTime := time.Now()
+
+	items := matrix.items(upstream)
+	if len(items) == 0 {
+		e.printf(run, "Skipping: %s (matrix has no items)", neuronRef.Name)
+		result.Status = "skipped"
+		result.Reason = "matrix has no items"
+		return result
+	}
+
+	e.printf(run, "Expanding %s into %d items (max concurrency: %d)", neuronRef.Name, len(items), cap(run.slots))
+
+	results := make([]NeuronResult, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		release := run.acquire()
+		wg.Add(1)
+		go func(i int, item matrixItem) {
+			defer wg.Done()
+			defer release()
+
+			env := item.env()
+			itemResult := e.executeNeuronWithRetry(ctx, run, neuronRef, upstream, env...)
+			itemResult = e.remediate(ctx, run, neuronRef, itemResult, upstream, env...)
+			itemResult.Name = item.label(neuronRef.Name)
+			itemResult.Item = item.values
+			results[i] = itemResult
+		}(i, item)
+	}
+	wg.Wait()
+
+	passing := 0
+	for _, itemResult := range results {
+		if itemResult.Status == "success" {
+			passing++
+		}
+	}
+
+	result.Items = results
+	result.Duration = time.Since(startTime)
+	pass := matrix.Pass
+	if pass == "" {
+		pass = MatrixAll
+	}
+	e.printf(run, "Matrix %s: %d/%d items passed (pass: %s)", neuronRef.Name, passing, len(items), pass)
+
+	if matrix.passed(passing, len(items)) {
+		result.Status = "success"
+		return result
+	}
+
+	result.Status = "failed"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Status = "timed_out"
+	}
+	result.ExitCode = 1
+	result.Reason = fmt.Sprintf("%d/%d items passed (pass: %s)", passing, len(items), pass)
+	result.Error = "matrix pass rule not met"
+	return result
+}
@@ -0,0 +1,220 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Matrix", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		out        *bytes.Buffer
+		journal    string
+	)
+
+	run := func(syn *synapse.Synapse) synapse.ExecutionRecord {
+		Expect(syn.Validate()).To(Succeed())
+		record, err := executor.Run(context.Background(), syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		return *record
+	}
+
+	journalLines := func() []string {
+		data, _ := os.ReadFile(journal)
+		lines := strings.Fields(string(data))
+		sort.Strings(lines)
+		return lines
+	}
+
+	itemNames := func(result synapse.NeuronResult) []string {
+		var names []string
+		for _, item := range result.Items {
+			names = append(names, item.Name+" "+item.Status)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-matrix-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-matrix-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), synapse.NewHistoryManager(historyDir), out)
+		journal = filepath.Join(synapseDir, "journal")
+
+		writeNeuron(synapseDir, "check_pods", `echo "$REGION/$NAMESPACE" >> `+journal+`; [ "$NAMESPACE" != "broken" ]`)
+		writeNeuron(synapseDir, "list_namespaces", `echo "NAMESPACES=web, api broken" >> "$CORTEX_OUTPUT"`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("runs the neuron once for every combination of values", func() {
+		record := run(&synapse.Synapse{
+			Name: "regions",
+			Neurons: []synapse.NeuronRef{{
+				Name: "check_pods",
+				Matrix: &synapse.Matrix{Values: map[string][]string{
+					"REGION":    {"eu", "us"},
+					"NAMESPACE": {"web", "api"},
+				}},
+			}},
+		})
+
+		Expect(record.Status).To(Equal("success"))
+		Expect(journalLines()).To(Equal([]string{"eu/api", "eu/web", "us/api", "us/web"}))
+		result := resultByName(record, "check_pods")
+		Expect(itemNames(result)).To(Equal([]string{
+			"check_pods[NAMESPACE=web,REGION=eu] success",
+			"check_pods[NAMESPACE=web,REGION=us] success",
+			"check_pods[NAMESPACE=api,REGION=eu] success",
+			"check_pods[NAMESPACE=api,REGION=us] success",
+		}))
+		Expect(result.Items[0].Item).To(Equal(map[string]string{"NAMESPACE": "web", "REGION": "eu"}))
+		Expect(out.String()).To(ContainSubstring("Expanding check_pods into 4 items (max concurrency: 1)"))
+	})
+
+	It("takes items from an upstream output", func() {
+		record := run(&synapse.Synapse{
+			Name:      "namespaces",
+			Execution: synapse.ExecutionParallel,
+			Neurons: []synapse.NeuronRef{
+				{Name: "list_namespaces"},
+				{
+					Name:      "check_pods",
+					DependsOn: []string{"list_namespaces"},
+					Matrix: &synapse.Matrix{
+						Values: map[string][]string{"REGION": {"eu"}},
+						From:   "list_namespaces.NAMESPACES",
+						As:     "NAMESPACE",
+					},
+				},
+			},
+		})
+
+		Expect(journalLines()).To(Equal([]string{"eu/api", "eu/broken", "eu/web"}))
+		result := resultByName(record, "check_pods")
+		Expect(itemNames(result)).To(Equal([]string{
+			"check_pods[REGION=eu,NAMESPACE=web] success",
+			"check_pods[REGION=eu,NAMESPACE=api] success",
+			"check_pods[REGION=eu,NAMESPACE=broken] failed",
+		}))
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Reason).To(Equal("2/3 items passed (pass: all)"))
+		Expect(record.Status).To(Equal("partial"))
+	})
+
+	DescribeTable("applies the pass rule",
+		func(pass synapse.MatrixRule, threshold string, status string) {
+			record := run(&synapse.Synapse{
+				Name: "rules",
+				Neurons: []synapse.NeuronRef{{
+					Name: "check_pods",
+					Matrix: &synapse.Matrix{
+						Values:    map[string][]string{"NAMESPACE": {"web", "api", "broken", "broken"}},
+						Pass:      pass,
+						Threshold: threshold,
+					},
+				}},
+			})
+			Expect(resultByName(record, "check_pods").Status).To(Equal(status))
+		},
+		Entry("all", synapse.MatrixAll, "", "failed"),
+		Entry("any", synapse.MatrixAny, "", "success"),
+		Entry("threshold count met", synapse.MatrixThreshold, "2", "success"),
+		Entry("threshold percentage not met", synapse.MatrixThreshold, "75%", "failed"),
+	)
+
+	It("runs items concurrently up to maxConcurrency", func() {
+		writeNeuron(synapseDir, "slow", `sleep 0.3`)
+
+		record := run(&synapse.Synapse{
+			Name:           "concurrent",
+			MaxConcurrency: 4,
+			Neurons: []synapse.NeuronRef{{
+				Name:   "slow",
+				Matrix: &synapse.Matrix{Values: map[string][]string{"N": {"1", "2", "3", "4"}}},
+			}},
+		})
+
+		Expect(record.Status).To(Equal("success"))
+		Expect(record.Duration.Seconds()).To(BeNumerically("<", 1))
+	})
+
+	It("shares the concurrency limit between the items of all matrix neurons", func() {
+		running := filepath.Join(synapseDir, "running")
+		Expect(os.Mkdir(running, 0755)).To(Succeed())
+		probe := `f=$(mktemp ` + running + `/item.XXXXXX); ls ` + running + ` | wc -l >> ` + journal + `; sleep 0.2; rm "$f"`
+		writeNeuron(synapseDir, "probe_a", probe)
+		writeNeuron(synapseDir, "probe_b", probe)
+
+		items := &synapse.Matrix{Values: map[string][]string{"N": {"1", "2", "3"}}}
+		record := run(&synapse.Synapse{
+			Name:           "shared",
+			Execution:      synapse.ExecutionParallel,
+			MaxConcurrency: 2,
+			Neurons: []synapse.NeuronRef{
+				{Name: "probe_a", Matrix: items},
+				{Name: "probe_b", Matrix: items},
+			},
+		})
+
+		Expect(record.Status).To(Equal("success"))
+		Expect(journalLines()).To(HaveLen(6))
+		Expect(journalLines()).To(HaveEach(BeElementOf("1", "2")), "items running at once")
+	})
+
+	It("skips matrices without items", func() {
+		writeNeuron(synapseDir, "list_namespaces", `true`)
+
+		record := run(&synapse.Synapse{
+			Name: "empty",
+			Neurons: []synapse.NeuronRef{
+				{Name: "list_namespaces"},
+				{Name: "check_pods", DependsOn: []string{"list_namespaces"}, Matrix: &synapse.Matrix{From: "list_namespaces.NAMESPACES"}},
+			},
+		})
+
+		result := resultByName(record, "check_pods")
+		Expect(result.Status).To(Equal("skipped"))
+		Expect(result.Reason).To(Equal("matrix has no items"))
+	})
+
+	DescribeTable("rejects invalid matrices",
+		func(neuronRef synapse.NeuronRef, message string) {
+			syn := &synapse.Synapse{Name: "invalid", Neurons: []synapse.NeuronRef{{Name: "list_namespaces"}, neuronRef}}
+			Expect(syn.Validate()).To(MatchError("neuron check_pods has invalid matrix: " + message))
+		},
+		Entry("no values",
+			synapse.NeuronRef{Name: "check_pods", Matrix: &synapse.Matrix{}},
+			"values or from is required"),
+		Entry("malformed from",
+			synapse.NeuronRef{Name: "check_pods", DependsOn: []string{"list_namespaces"}, Matrix: &synapse.Matrix{From: "list_namespaces"}},
+			`from must be <neuron>.<OUTPUT>, got "list_namespaces"`),
+		Entry("from without dependency",
+			synapse.NeuronRef{Name: "check_pods", Matrix: &synapse.Matrix{From: "list_namespaces.NAMESPACES"}},
+			"from references list_namespaces, which is not in dependsOn"),
+		Entry("unknown pass rule",
+			synapse.NeuronRef{Name: "check_pods", Matrix: &synapse.Matrix{Values: map[string][]string{"N": {"1"}}, Pass: "most"}},
+			`unknown pass rule "most"`),
+		Entry("bad threshold",
+			synapse.NeuronRef{Name: "check_pods", Matrix: &synapse.Matrix{Values: map[string][]string{"N": {"1"}}, Pass: synapse.MatrixThreshold, Threshold: "150%"}},
+			`invalid threshold "150%"`),
+	)
+})
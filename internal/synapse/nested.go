@@ -0,0 +1,162 @@
+package synapse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// subSynapseDir resolves the directory of a sub-synapse against the directory
+// of the synapse referencing it
+func subSynapseDir(baseDir string, neuronRef NeuronRef) string {
+	if filepath.IsAbs(neuronRef.Synapse) {
+		return filepath.Clean(neuronRef.Synapse)
+	}
+	return filepath.Join(baseDir, neuronRef.Synapse)
+}
+
+// validateSubSynapses loads and validates the synapses s references, rejecting
+// references back to a synapse in stack. Synapses that were not loaded from a
+// directory cannot resolve their sub-synapses and are checked when executed.
+func (s *Synapse) validateSubSynapses(stack []string) error {
+	if s.dir == "" {
+		return nil
+	}
+	stack = append(stack, s.dir)
+
+	for _, neuronRef := range s.Neurons {
+		if neuronRef.Synapse == "" {
+			continue
+		}
+		dir := subSynapseDir(s.dir, neuronRef)
+		if err := checkSynapseCycle(stack, dir); err != nil {
+			return err
+		}
+
+		configPath, err := FindConfig(dir)
+		if err != nil {
+			return fmt.Errorf("neuron %s references a missing synapse: %w", neuronRef.Name, err)
+		}
+		sub, err := parseFile(configPath)
+		if err != nil {
+			return fmt.Errorf("neuron %s: %w", neuronRef.Name, err)
+		}
+		if err := sub.validate(stack); err != nil {
+			return fmt.Errorf("sub-synapse %s: %w", neuronRef.Synapse, err)
+		}
+	}
+	return nil
+}
+
+// checkSynapseCycle rejects dir if it is one of the synapse directories in stack
+func checkSynapseCycle(stack []string, dir string) error {
+	for i, d := range stack {
+		if d == dir {
+			cycle := append(append([]string{}, stack[i:]...), dir)
+			return fmt.Errorf("Circular synapse reference detected: %v", cycle)
+		}
+	}
+	return nil
+}
+
+// runSubSynapse executes the synapse referenced by neuronRef as a step of run.
+// The sub-synapse receives the inputs of run overlaid with those of the
+// reference; its execution is nested in the returned result, which succeeds
+// only if every neuron of the sub-synapse did. The outputs of its neurons
+// become the outputs of the step.
+func (e *Executor) runSubSynapse(ctx context.Context, run *executionRun, neuronRef NeuronRef) NeuronResult {
+	result := NeuronResult{Name: neuronRef.Name}
+	startTime := time.Now()
+	dir := subSynapseDir(run.synapseDir, neuronRef)
+
+	fail := func(err error) NeuronResult {
+		e.printf(run, "Synapse %s failed: %v", neuronRef.Name, err)
+		result.Status = "failed"
+		result.ExitCode = -1
+		result.Error = err.Error()
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	e.printf(run, "Executing synapse: %s (%s)", neuronRef.Name, dir)
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fail(err)
+	}
+	var stack []string
+	for r := run; r != nil; r = r.parent {
+		if d, err := filepath.Abs(r.synapseDir); err == nil {
+			stack = append([]string{d}, stack...)
+		}
+	}
+	if err := checkSynapseCycle(stack, absDir); err != nil {
+		return fail(err)
+	}
+
+	sub, err := LoadFromDirectory(dir)
+	if err != nil {
+		return fail(err)
+	}
+
+	inputs := sub.declaredInputs(run.inputs)
+	for k, v := range neuronRef.Inputs {
+		inputs[k] = v
+	}
+	if inputs, err = sub.ResolveInputs(inputs); err != nil {
+		return fail(err)
+	}
+
+	// Validate rejects malformed timeouts
+	if timeout, _ := time.ParseDuration(neuronRef.Timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	subRun := &executionRun{
+		synapse:    sub,
+		synapseDir: dir,
+		record:     &ExecutionRecord{},
+		inputs:     inputs,
+		parent:     run,
+	}
+	executionErr := e.executeRun(ctx, subRun)
+
+	result.SubExecution = subRun.record
+	result.Duration = time.Since(startTime)
+	for _, subResult := range subRun.record.NeuronResults {
+		for k, v := range subResult.Outputs {
+			if result.Outputs == nil {
+				result.Outputs = make(map[string]string)
+			}
+			result.Outputs[k] = v
+		}
+	}
+
+	if executionErr == nil && subRun.record.Status == "success" {
+		result.Status = "success"
+		return result
+	}
+
+	result.Status = "failed"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Status = "timed_out"
+	}
+	result.ExitCode = 1
+	if executionErr != nil {
+		result.Error = executionErr.Error()
+	} else {
+		failed := 0
+		for _, subResult := range subRun.record.NeuronResults {
+			if isFailure(subResult.Status) {
+				failed++
+			}
+		}
+		result.Error = fmt.Sprintf("%d of %d neurons failed", failed, len(subRun.record.NeuronResults))
+	}
+	e.printf(run, "Synapse %s failed: %s", neuronRef.Name, result.Error)
+	return result
+}
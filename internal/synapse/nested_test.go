@@ -0,0 +1,121 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nested synapses", func() {
+	var (
+		rootDir    string
+		parentDir  string
+		healthDir  string
+		historyDir string
+		executor   *synapse.Executor
+		out        *bytes.Buffer
+	)
+
+	writeConfig := func(dir, config string) {
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, synapse.ConfigFile), []byte(config), 0644)).To(Succeed())
+	}
+
+	run := func(syn *synapse.Synapse) *synapse.ExecutionRecord {
+		Expect(syn.Validate()).To(Succeed())
+		record, err := executor.Run(context.Background(), syn, parentDir)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	BeforeEach(func() {
+		var err error
+		rootDir, err = os.MkdirTemp("", "cortex-nested-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-nested-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		parentDir = filepath.Join(rootDir, "upgrade")
+		healthDir = filepath.Join(rootDir, "system_health_check")
+
+		writeNeuron(healthDir, "check_disk", `echo "DISK_FREE=42" >> "$CORTEX_OUTPUT"`)
+		writeNeuron(healthDir, "check_memory", `exit 1`)
+		writeConfig(healthDir, `name: system_health_check
+neurons:
+  - check_disk
+  - name: check_memory
+    condition: "strict == 'yes'"
+`)
+		writeNeuron(parentDir, "report", `echo "free: $DISK_FREE"`)
+
+		out = &bytes.Buffer{}
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), synapse.NewHistoryManager(historyDir), out)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(rootDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("nests the results of a sub-synapse and passes its outputs on", func() {
+		record := run(&synapse.Synapse{
+			Name: "upgrade",
+			Neurons: []synapse.NeuronRef{
+				{Name: "health", Synapse: "../system_health_check"},
+				{Name: "report", DependsOn: []string{"health"}},
+			},
+		})
+
+		Expect(record.Status).To(Equal("success"))
+		health := resultByName(*record, "health")
+		Expect(health.Status).To(Equal("success"))
+		Expect(health.SubExecution).NotTo(BeNil())
+		Expect(health.SubExecution.SynapseName).To(Equal("system_health_check"))
+		Expect(resultByName(*health.SubExecution, "check_disk").Status).To(Equal("success"))
+		Expect(resultByName(*health.SubExecution, "check_memory").Status).To(Equal("skipped"))
+		Expect(resultByName(*record, "report").Stdout).To(Equal("free: 42\n"))
+	})
+
+	It("passes inputs down and fails the step when a sub-synapse neuron fails", func() {
+		executor.SetEnvironment(map[string]string{"strict": "no"})
+		record := run(&synapse.Synapse{
+			Name:    "upgrade",
+			Neurons: []synapse.NeuronRef{{Name: "health", Synapse: "../system_health_check", Inputs: map[string]string{"strict": "yes"}}},
+		})
+
+		health := resultByName(*record, "health")
+		Expect(health.Status).To(Equal("failed"))
+		Expect(health.Error).To(Equal("1 of 2 neurons failed"))
+		Expect(resultByName(*health.SubExecution, "check_memory").Status).To(Equal("failed"))
+		Expect(record.Status).To(Equal("partial"))
+	})
+
+	It("records only the parent execution in history", func() {
+		run(&synapse.Synapse{Name: "upgrade", Neurons: []synapse.NeuronRef{{Name: "health", Synapse: "../system_health_check"}}})
+
+		nested, err := synapse.NewHistoryManager(historyDir).GetHistory("system_health_check")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nested).To(BeEmpty())
+	})
+
+	It("detects cycles across synapse files when validating", func() {
+		writeConfig(parentDir, "name: upgrade\nneurons:\n  - name: health\n    synapse: ../system_health_check\n")
+		writeConfig(healthDir, "name: system_health_check\nneurons:\n  - name: upgrade\n    synapse: ../upgrade\n")
+
+		_, err := synapse.LoadFromDirectory(parentDir)
+		Expect(err).To(MatchError(ContainSubstring("Circular synapse reference detected")))
+	})
+
+	It("refuses to run a synapse inside itself", func() {
+		writeConfig(parentDir, "name: upgrade\nneurons: [report]\n")
+
+		record := run(&synapse.Synapse{Name: "upgrade", Neurons: []synapse.NeuronRef{{Name: "again", Synapse: "."}}})
+		Expect(resultByName(*record, "again").Error).To(ContainSubstring("Circular synapse reference detected"))
+	})
+})
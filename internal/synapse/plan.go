@@ -0,0 +1,284 @@
+package synapse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+)
+
+// Plan decisions for a neuron
+const (
+	PlanRun     = "run"     // the neuron will be executed
+	PlanSkip    = "skip"    // the condition is false for the supplied environment, or read-only mode
+	PlanRuntime = "runtime" // the condition depends on results only known at runtime
+	PlanError   = "error"   // the neuron cannot be resolved or its condition fails to evaluate
+)
+
+// ExecutionPlan describes what executing a synapse would do, without running anything
+type ExecutionPlan struct {
+	Synapse        string            `json:"synapse"`
+	Execution      ExecutionMode     `json:"execution"`
+	MaxConcurrency int               `json:"max_concurrency,omitempty"` // only set for parallel execution
+	StopOnError    bool              `json:"stop_on_error"`
+	Timeout        string            `json:"timeout,omitempty"`
+	Environment    map[string]string `json:"environment,omitempty"` // resolved inputs conditions were evaluated against
+	Levels         [][]string        `json:"levels"`                // neurons grouped by dependency depth
+	Neurons        []PlannedNeuron   `json:"neurons"`               // in execution order
+}
+
+// PlannedNeuron describes how a single neuron would be executed
+type PlannedNeuron struct {
+	Name            string          `json:"name"`
+	Level           int             `json:"level"`               // 1 for neurons without dependencies
+	Path            string          `json:"path,omitempty"`      // resolved neuron config file
+	Type            string          `json:"type,omitempty"`      // check or mutate
+	ExecFile        string          `json:"exec_file,omitempty"` // script the neuron runs
+	Target          string          `json:"target,omitempty"`    // host the neuron runs on over SSH
+	Image           string          `json:"image,omitempty"`     // container image the neuron runs in
+	DependsOn       []string        `json:"depends_on,omitempty"`
+	TriggerRule     TriggerRule     `json:"trigger_rule"`
+	Condition       string          `json:"condition,omitempty"`
+	Timeout         string          `json:"timeout,omitempty"` // from the synapse, else the neuron config
+	Decision        string          `json:"decision"`          // one of PlanRun, PlanSkip, PlanRuntime, PlanError
+	Reason          string          `json:"reason,omitempty"`  // why the decision was made
+	MaxAttempts     int             `json:"max_attempts"`
+	Backoff         string          `json:"backoff,omitempty"`      // only set when retries are possible
+	RetryDelays     []time.Duration `json:"retry_delays,omitempty"` // wait before each retry
+	RetryBudget     time.Duration   `json:"retry_budget"`           // total time spent waiting between retries, before jitter
+	Jitter          float64         `json:"jitter,omitempty"`
+	RetryOn         []int           `json:"retry_on,omitempty"`
+	AbortOn         []int           `json:"abort_on,omitempty"`
+	OnFailure       []string        `json:"on_failure,omitempty"`
+	Undo            string          `json:"undo,omitempty"`
+	Fix             map[int]string  `json:"fix,omitempty"` // fix neuron by exit code
+	MaxRemediations int             `json:"max_remediations,omitempty"`
+	Matrix          *Matrix         `json:"matrix,omitempty"`
+	MatrixItems     int             `json:"matrix_items,omitempty"` // combinations of the static matrix values
+	PollInterval    time.Duration   `json:"poll_interval,omitempty"`
+	PollTimeout     time.Duration   `json:"poll_timeout,omitempty"`
+	PollUntil       string          `json:"poll_until,omitempty"` // only set for polled neurons
+}
+
+// Plan resolves neuron files, evaluates conditions against the executor
+// environment and computes the execution order, dependency levels and retry
+// budgets of a synapse. Nothing is executed.
+func (e *Executor) Plan(synapse *Synapse, synapseDir string) (*ExecutionPlan, error) {
+	levels, err := dependencyLevels(synapse)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	environment := e.environment
+	e.mu.Unlock()
+
+	inputs, err := synapse.ResolveInputs(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ExecutionPlan{
+		Synapse:     synapse.Name,
+		Execution:   synapse.Execution,
+		StopOnError: synapse.StopOnError,
+		Timeout:     synapse.Timeout,
+		Environment: inputs,
+	}
+	if plan.Execution == "" {
+		plan.Execution = ExecutionSequential
+	}
+	if plan.Execution == ExecutionParallel {
+		plan.MaxConcurrency = synapse.MaxConcurrency
+		if plan.MaxConcurrency <= 0 {
+			plan.MaxConcurrency = defaultMaxConcurrency
+		}
+	}
+
+	for _, neuronRef := range synapse.Neurons {
+		level := levels[neuronRef.Name]
+		for len(plan.Levels) < level {
+			plan.Levels = append(plan.Levels, nil)
+		}
+		plan.Levels[level-1] = append(plan.Levels[level-1], neuronRef.Name)
+
+		plan.Neurons = append(plan.Neurons, e.planNeuron(synapse, neuronRef, synapseDir, level, inputs))
+	}
+
+	return plan, nil
+}
+
+// planNeuron resolves a single neuron and decides whether it would run
+func (e *Executor) planNeuron(synapse *Synapse, neuronRef NeuronRef, synapseDir string, level int, inputs map[string]string) PlannedNeuron {
+	planned := PlannedNeuron{
+		Name:        neuronRef.Name,
+		Level:       level,
+		DependsOn:   neuronRef.DependsOn,
+		TriggerRule: neuronRef.triggerRule(),
+		Condition:   neuronRef.Condition,
+		Decision:    PlanRun,
+		OnFailure:   neuronRef.OnFailure,
+		Timeout:     neuronRef.Timeout,
+		Undo:        neuronRef.Undo,
+	}
+	if planned.Fix = synapse.fixesFor(neuronRef); planned.Fix != nil {
+		planned.MaxRemediations = neuronRef.maxRemediations()
+	}
+	if planned.TriggerRule == "" {
+		planned.TriggerRule = TriggerOnSuccess
+	}
+	if neuronRef.Matrix != nil {
+		planned.Matrix = neuronRef.Matrix
+		planned.MatrixItems = neuronRef.Matrix.staticItems()
+	}
+	if neuronRef.Poll != nil {
+		planned.PollInterval, planned.PollTimeout = neuronRef.Poll.durations()
+		planned.PollUntil = neuronRef.Poll.until()
+	}
+
+	retry := retryConfigFor(neuronRef)
+	planned.MaxAttempts = retry.maxAttempts
+	if retry.maxAttempts > 1 {
+		planned.Backoff = string(retry.backoff)
+		planned.Jitter = retry.jitter
+		planned.RetryOn = retry.retryOn
+		planned.AbortOn = retry.abortOn
+		for attempt := 2; attempt <= retry.maxAttempts; attempt++ {
+			delay := retry.delay(attempt - 1)
+			planned.RetryDelays = append(planned.RetryDelays, delay)
+			planned.RetryBudget += delay
+		}
+	}
+
+	if neuronRef.Synapse != "" {
+		// Sub-synapses are planned as a single step
+		planned.Type = "synapse"
+		path, err := FindConfig(subSynapseDir(synapseDir, neuronRef))
+		if err != nil {
+			planned.Decision = PlanError
+			planned.Reason = err.Error()
+			return planned
+		}
+		planned.Path = path
+	} else {
+		path, err := resolveNeuronPath(synapse, synapseDir, neuronRef.Name)
+		if err != nil {
+			planned.Decision = PlanError
+			planned.Reason = err.Error()
+			return planned
+		}
+		planned.Path = path
+
+		n, err := neuron.NewNeuron(e.logger, path)
+		if err != nil {
+			planned.Decision = PlanError
+			planned.Reason = fmt.Sprintf("failed to load neuron: %v", err)
+			return planned
+		}
+		planned.Type = n.Type
+		planned.ExecFile = n.ExecFile
+		target := n.Target
+		if target == nil {
+			target = synapse.Target
+		}
+		if target != nil {
+			planned.Target = target.String()
+		}
+		planned.Image = n.Image
+		if n.Image != "" && target != nil {
+			planned.Decision = PlanError
+			planned.Reason = "a neuron with an image cannot run on a target"
+			return planned
+		}
+		if planned.Timeout == "" {
+			planned.Timeout = n.Timeout
+		}
+
+		e.mu.Lock()
+		readOnly := e.readOnly
+		e.mu.Unlock()
+		if readOnly && n.Type == mutateType {
+			planned.Decision = PlanSkip
+			planned.Reason = "read-only mode"
+			return planned
+		}
+	}
+
+	if neuronRef.Condition == "" {
+		return planned
+	}
+
+	expr, err := ParseExpression(neuronRef.Condition)
+	if err != nil {
+		planned.Decision = PlanError
+		planned.Reason = fmt.Sprintf("invalid condition: %v", err)
+		return planned
+	}
+	if refs := expr.NeuronReferences(); len(refs) > 0 {
+		planned.Decision = PlanRuntime
+		planned.Reason = fmt.Sprintf("condition depends on results of %s", strings.Join(refs, ", "))
+		return planned
+	}
+
+	shouldRun, err := e.evaluateCondition(neuronRef.Condition, inputs, nil)
+	switch {
+	case err != nil:
+		planned.Decision = PlanError
+		planned.Reason = fmt.Sprintf("condition evaluation failed: %v", err)
+	case !shouldRun:
+		planned.Decision = PlanSkip
+		planned.Reason = "condition not met"
+	}
+	return planned
+}
+
+// dependencyLevels assigns each neuron its depth in the dependency graph:
+// neurons without dependencies are on level 1, every other neuron is one
+// level below its deepest dependency.
+func dependencyLevels(synapse *Synapse) (map[string]int, error) {
+	refs := make(map[string]NeuronRef, len(synapse.Neurons))
+	for _, neuronRef := range synapse.Neurons {
+		refs[neuronRef.Name] = neuronRef
+	}
+
+	levels := make(map[string]int, len(synapse.Neurons))
+	visiting := make(map[string]bool)
+
+	var visit func(string) (int, error)
+	visit = func(name string) (int, error) {
+		if level, ok := levels[name]; ok {
+			return level, nil
+		}
+		neuronRef, ok := refs[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown neuron in dependencies: %s", name)
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("Circular dependency detected at neuron %s", name)
+		}
+		visiting[name] = true
+
+		level := 1
+		for _, dep := range neuronRef.DependsOn {
+			depLevel, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			if depLevel+1 > level {
+				level = depLevel + 1
+			}
+		}
+
+		visiting[name] = false
+		levels[name] = level
+		return level, nil
+	}
+
+	for _, neuronRef := range synapse.Neurons {
+		if _, err := visit(neuronRef.Name); err != nil {
+			return nil, err
+		}
+	}
+	return levels, nil
+}
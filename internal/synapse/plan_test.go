@@ -0,0 +1,143 @@
+package synapse_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Executor.Plan", func() {
+	var (
+		synapseDir string
+		executor   *synapse.Executor
+		out        *bytes.Buffer
+		marker     string
+	)
+
+	plannedByName := func(plan *synapse.ExecutionPlan, name string) synapse.PlannedNeuron {
+		for _, n := range plan.Neurons {
+			if n.Name == name {
+				return n
+			}
+		}
+		Fail("no planned neuron " + name)
+		return synapse.PlannedNeuron{}
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-plan-test-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), nil, out)
+
+		marker = filepath.Join(synapseDir, "ran")
+		for _, name := range []string{"check_a", "check_b", "mutate_fix", "report"} {
+			writeNeuron(synapseDir, name, "touch "+marker)
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+	})
+
+	It("computes levels, decisions and retry budgets without executing anything", func() {
+		executor.SetEnvironment(map[string]string{"env": "staging"})
+		syn := &synapse.Synapse{
+			Name:        "planned",
+			Execution:   synapse.ExecutionParallel,
+			StopOnError: true,
+			Neurons: []synapse.NeuronRef{
+				{Name: "check_a"},
+				{Name: "check_b", Condition: `env == "production"`},
+				{
+					Name:      "mutate_fix",
+					DependsOn: []string{"check_a"},
+					Condition: `neurons.check_a.exitCode != 0`,
+					Retry:     &synapse.RetryPolicy{MaxAttempts: 3, Backoff: synapse.BackoffLinear, InitialDelay: "2s"},
+				},
+				{Name: "report", DependsOn: []string{"mutate_fix", "check_b"}, TriggerRule: synapse.TriggerAlways},
+				{Name: "missing"},
+			},
+		}
+
+		plan, err := executor.Plan(syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(plan.Execution).To(Equal(synapse.ExecutionParallel))
+		Expect(plan.MaxConcurrency).To(Equal(5))
+		Expect(plan.Levels).To(Equal([][]string{
+			{"check_a", "check_b", "missing"},
+			{"mutate_fix"},
+			{"report"},
+		}))
+
+		checkA := plannedByName(plan, "check_a")
+		Expect(checkA.Decision).To(Equal(synapse.PlanRun))
+		Expect(checkA.Type).To(Equal("check"))
+		Expect(checkA.Path).To(Equal(filepath.Join(synapseDir, "neurons", "check_a.yml")))
+		Expect(checkA.MaxAttempts).To(Equal(1))
+		Expect(checkA.RetryBudget).To(BeZero())
+
+		checkB := plannedByName(plan, "check_b")
+		Expect(checkB.Decision).To(Equal(synapse.PlanSkip))
+		Expect(checkB.Reason).To(Equal("condition not met"))
+
+		fix := plannedByName(plan, "mutate_fix")
+		Expect(fix.Decision).To(Equal(synapse.PlanRuntime))
+		Expect(fix.Reason).To(ContainSubstring("check_a"))
+		Expect(fix.MaxAttempts).To(Equal(3))
+		Expect(fix.RetryDelays).To(Equal([]time.Duration{2 * time.Second, 4 * time.Second}))
+		Expect(fix.RetryBudget).To(Equal(6 * time.Second))
+
+		report := plannedByName(plan, "report")
+		Expect(report.Level).To(Equal(3))
+		Expect(report.TriggerRule).To(Equal(synapse.TriggerAlways))
+
+		missing := plannedByName(plan, "missing")
+		Expect(missing.Decision).To(Equal(synapse.PlanError))
+		Expect(missing.Reason).To(ContainSubstring("neuron not found"))
+
+		_, err = os.Stat(marker)
+		Expect(os.IsNotExist(err)).To(BeTrue(), "no neuron should have been executed")
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("lists neurons in declaration order for sequential synapses", func() {
+		syn := &synapse.Synapse{
+			Name: "sequential",
+			Neurons: []synapse.NeuronRef{
+				{Name: "report", DependsOn: []string{"check_a"}},
+				{Name: "check_a"},
+			},
+		}
+
+		plan, err := executor.Plan(syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Execution).To(Equal(synapse.ExecutionSequential))
+		Expect(plan.MaxConcurrency).To(BeZero())
+		Expect(plan.Neurons[0].Name).To(Equal("report"))
+		Expect(plan.Neurons[0].Level).To(Equal(2))
+		Expect(plan.Levels).To(Equal([][]string{{"check_a"}, {"report"}}))
+	})
+
+	It("rejects circular dependencies", func() {
+		syn := &synapse.Synapse{
+			Name: "cyclic",
+			Neurons: []synapse.NeuronRef{
+				{Name: "check_a", DependsOn: []string{"check_b"}},
+				{Name: "check_b", DependsOn: []string{"check_a"}},
+			},
+		}
+
+		_, err := executor.Plan(syn, synapseDir)
+		Expect(err).To(MatchError(ContainSubstring("Circular dependency")))
+	})
+})
@@ -0,0 +1,165 @@
+package synapse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultPollTimeout  = 5 * time.Minute
+	defaultPollUntil    = "exitCode == 0"
+)
+
+// exitCodeShorthand matches the "exitCode N" form of until
+var exitCodeShorthand = regexp.MustCompile(`^\s*exitCode\s+(-?\d+)\s*$`)
+
+// PollPolicy re-runs a check until a criterion holds or a deadline passes.
+// Unlike retries, polls that don't meet the criterion are not failures: the
+// neuron only fails if the deadline passes first.
+type PollPolicy struct {
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"` // wait between polls, 5s by default
+	Timeout  string `yaml:"timeout,omitempty" json:"timeout,omitempty"`   // give up after this long, 5m by default
+	// Until is a condition over the result of the poll (exitCode, stdout,
+	// stderr, outputs.KEY), "exitCode 0" by default
+	Until string `yaml:"until,omitempty" json:"until,omitempty"`
+}
+
+// PollResult records how polling a neuron ended
+type PollResult struct {
+	Polls     int    `json:"polls"`
+	Satisfied bool   `json:"satisfied"` // until held before the deadline
+	Until     string `json:"until"`
+}
+
+// until returns the criterion as an expression
+func (p *PollPolicy) until() string {
+	if p.Until == "" {
+		return defaultPollUntil
+	}
+	if m := exitCodeShorthand.FindStringSubmatch(p.Until); m != nil {
+		return "exitCode == " + m[1]
+	}
+	return p.Until
+}
+
+// durations returns the interval and timeout with defaults applied. Validate
+// rejects malformed durations.
+func (p *PollPolicy) durations() (time.Duration, time.Duration) {
+	interval, timeout := defaultPollInterval, defaultPollTimeout
+	if d, err := time.ParseDuration(p.Interval); err == nil {
+		interval = d
+	}
+	if d, err := time.ParseDuration(p.Timeout); err == nil {
+		timeout = d
+	}
+	return interval, timeout
+}
+
+// validate checks the poll policy of neuronRef
+func (p *PollPolicy) validate(neuronRef NeuronRef) error {
+	if p == nil {
+		return nil
+	}
+	if neuronRef.Retry != nil {
+		return fmt.Errorf("cannot be combined with retry")
+	}
+	if neuronRef.Synapse != "" {
+		return fmt.Errorf("cannot be combined with synapse")
+	}
+	for field, value := range map[string]string{"interval": p.Interval, "timeout": p.Timeout} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s %q", field, value)
+		}
+	}
+	if _, err := ParseExpression(p.until()); err != nil {
+		return fmt.Errorf("invalid until %q: %w", p.Until, err)
+	}
+	return nil
+}
+
+// pollNeuron runs a neuron every interval until its poll criterion holds,
+// reporting progress after each poll. A run that cannot be started or whose
+// criterion fails to evaluate ends polling; the neuron times out when the
+// deadline passes first.
+func (e *Executor) pollNeuron(ctx context.Context, run *executionRun, neuronRef NeuronRef, env []string) NeuronResult {
+	policy := neuronRef.Poll
+	interval, pollTimeout := policy.durations()
+	until, _ := ParseExpression(policy.until())
+
+	// Validate rejects malformed timeouts, zero leaves the neuron's own
+	timeout, _ := time.ParseDuration(neuronRef.Timeout)
+
+	poll := &PollResult{Until: until.String()}
+	result := NeuronResult{Name: neuronRef.Name, Poll: poll}
+	startTime := time.Now()
+	deadline := startTime.Add(pollTimeout)
+
+	finish := func(status string) NeuronResult {
+		result.Status = status
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	for {
+		if ctx.Err() != nil {
+			result.Error = contextErrorMessage(ctx)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return finish("timed_out")
+			}
+			return finish("failed")
+		}
+
+		poll.Polls++
+		e.emit(NeuronStarted{ExecutionID: run.record.ID, Neuron: neuronRef.Name, Poll: poll.Polls, Time: time.Now()})
+		res, err := e.executeNeuron(ctx, run, neuronRef.Name, env, timeout)
+		result.ExitCode = res.ExitCode
+		result.Stdout = res.Stdout
+		result.Stderr = res.Stderr
+		result.OutputTruncated = res.Truncated
+		result.Outputs = res.Outputs
+		result.Error = ""
+
+		if err != nil && !res.TimedOut {
+			result.Error = err.Error()
+			if ctx.Err() == nil {
+				return finish("failed")
+			}
+			continue
+		}
+
+		satisfied, evalErr := until.Evaluate(&EvalContext{Inputs: run.inputs, Self: &result})
+		if evalErr != nil {
+			result.Error = fmt.Sprintf("failed to evaluate until: %v", evalErr)
+			return finish("failed")
+		}
+		if satisfied {
+			poll.Satisfied = true
+			e.printf(run, "Neuron %s ready after %d polls (%v)", neuronRef.Name, poll.Polls, time.Since(startTime).Round(time.Millisecond))
+			return finish("success")
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			e.printf(run, "Gave up polling %s after %d polls (%v)", neuronRef.Name, poll.Polls, pollTimeout)
+			result.Reason = fmt.Sprintf("%s did not hold within %v", until.String(), pollTimeout)
+			return finish("timed_out")
+		}
+
+		wait := interval
+		if wait > remaining {
+			wait = remaining
+		}
+		e.printf(run, "Waiting for %s: %s does not hold (exit code %d), polling again in %v",
+			neuronRef.Name, until.String(), res.ExitCode, wait)
+		if err := waitForRetry(ctx, wait); err != nil {
+			continue
+		}
+	}
+}
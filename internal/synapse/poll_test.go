@@ -0,0 +1,106 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Polling", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		out        *bytes.Buffer
+		counter    string
+	)
+
+	// writeReadyAfter writes a neuron that reports PHASE=Pending and exits 1
+	// until it has run n times
+	writeReadyAfter := func(name string, n string) {
+		writeNeuron(synapseDir, name, `c=$(( $(cat `+counter+` 2>/dev/null || echo 0) + 1 )); echo $c > `+counter+`
+if [ $c -ge `+n+` ]; then echo "PHASE=Running" >> "$CORTEX_OUTPUT"; echo ready; exit 0; fi
+echo "PHASE=Pending" >> "$CORTEX_OUTPUT"; exit 1`)
+	}
+
+	run := func(neuronRef synapse.NeuronRef) synapse.NeuronResult {
+		syn := &synapse.Synapse{Name: "poll", Neurons: []synapse.NeuronRef{neuronRef}}
+		Expect(syn.Validate()).To(Succeed())
+		record, err := executor.Run(context.Background(), syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		return record.NeuronResults[0]
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-poll-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-poll-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), synapse.NewHistoryManager(historyDir), out)
+		counter = filepath.Join(synapseDir, "counter")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("polls until the exit code criterion holds", func() {
+		writeReadyAfter("wait_ready", "3")
+
+		result := run(synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{Interval: "10ms", Timeout: "5s", Until: "exitCode 0"}})
+		Expect(result.Status).To(Equal("success"))
+		Expect(result.Poll).To(Equal(&synapse.PollResult{Polls: 3, Satisfied: true, Until: "exitCode == 0"}))
+		Expect(result.Stdout).To(Equal("ready\n"))
+		Expect(result.Attempts).To(BeEmpty())
+		Expect(out.String()).To(ContainSubstring("Waiting for wait_ready: exitCode == 0 does not hold (exit code 1), polling again in 10ms"))
+		Expect(out.String()).To(ContainSubstring("Neuron wait_ready ready after 3 polls"))
+	})
+
+	It("polls until an output criterion holds", func() {
+		writeReadyAfter("wait_ready", "2")
+
+		result := run(synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{Interval: "10ms", Until: "outputs.PHASE == 'Running'"}})
+		Expect(result.Status).To(Equal("success"))
+		Expect(result.Poll.Polls).To(Equal(2))
+	})
+
+	It("times out when the criterion does not hold before the deadline", func() {
+		writeReadyAfter("wait_ready", "1000")
+
+		start := time.Now()
+		result := run(synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{Interval: "50ms", Timeout: "300ms"}})
+		Expect(time.Since(start)).To(BeNumerically("<", 3*time.Second))
+		Expect(result.Status).To(Equal("timed_out"))
+		Expect(result.Poll.Satisfied).To(BeFalse())
+		Expect(result.Poll.Polls).To(BeNumerically(">=", 3))
+		Expect(result.Reason).To(Equal("exitCode == 0 did not hold within 300ms"))
+		Expect(result.Outputs).To(Equal(map[string]string{"PHASE": "Pending"}))
+	})
+
+	DescribeTable("rejects invalid policies",
+		func(neuronRef synapse.NeuronRef, message string) {
+			syn := &synapse.Synapse{Name: "poll", Neurons: []synapse.NeuronRef{neuronRef}}
+			Expect(syn.Validate()).To(MatchError("neuron wait_ready has invalid poll policy: " + message))
+		},
+		Entry("bad interval",
+			synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{Interval: "often"}},
+			`invalid interval "often"`),
+		Entry("bad until",
+			synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{Until: "exitCode =="}},
+			`invalid until "exitCode ==": unexpected "end of expression" at position 11`),
+		Entry("combined with retry",
+			synapse.NeuronRef{Name: "wait_ready", Poll: &synapse.PollPolicy{}, Retry: &synapse.RetryPolicy{MaxAttempts: 2}},
+			"cannot be combined with retry"),
+	)
+})
@@ -0,0 +1,168 @@
+package synapse
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// defaultInitialDelay is the wait before the first retry when none is configured
+const defaultInitialDelay = time.Second
+
+// maxBackoffDelay bounds computed delays so large attempt counts cannot overflow
+const maxBackoffDelay = 24 * time.Hour
+
+// retryConfig is a neuron's retry policy with defaults applied and durations parsed
+type retryConfig struct {
+	maxAttempts  int
+	backoff      BackoffStrategy
+	initialDelay time.Duration
+	maxDelay     time.Duration // 0 for no cap
+	jitter       float64       // fraction of each delay that is randomized
+	retryOn      []int         // only these exit codes are retried, all if empty
+	abortOn      []int         // these exit codes are never retried
+}
+
+// retryConfigFor resolves the retry policy of a neuron. Validate rejects
+// malformed policies, so parse errors fall back to the defaults here.
+func retryConfigFor(neuronRef NeuronRef) retryConfig {
+	config := retryConfig{
+		maxAttempts:  1,
+		backoff:      BackoffLinear,
+		initialDelay: defaultInitialDelay,
+	}
+
+	policy := neuronRef.Retry
+	if policy == nil {
+		return config
+	}
+
+	if policy.MaxAttempts > 1 {
+		config.maxAttempts = policy.MaxAttempts
+	}
+	if policy.Backoff != "" {
+		config.backoff = policy.Backoff
+	}
+	if d, err := time.ParseDuration(policy.InitialDelay); err == nil {
+		config.initialDelay = d
+	}
+	if d, err := time.ParseDuration(policy.MaxDelay); err == nil {
+		config.maxDelay = d
+	}
+	config.jitter = policy.Jitter
+	config.retryOn = policy.RetryOn
+	config.abortOn = policy.AbortOn
+	return config
+}
+
+// delay returns the wait before the given retry, 1 being the first retry,
+// without jitter. The first retry always waits initialDelay; linear backoff
+// adds initialDelay for every further retry, exponential backoff doubles it.
+func (c retryConfig) delay(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+
+	delay := c.initialDelay
+	switch c.backoff {
+	case BackoffExponential:
+		for i := 1; i < retry && delay < maxBackoffDelay; i++ {
+			delay *= 2
+		}
+	default:
+		if delay > 0 && time.Duration(retry) > maxBackoffDelay/delay {
+			delay = maxBackoffDelay
+		} else {
+			delay *= time.Duration(retry)
+		}
+	}
+
+	if delay > maxBackoffDelay {
+		delay = maxBackoffDelay
+	}
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	return delay
+}
+
+// jittered spreads delay randomly by up to ±jitter of its value, never
+// exceeding maxDelay
+func (c retryConfig) jittered(delay time.Duration) time.Duration {
+	if c.jitter <= 0 || delay <= 0 {
+		return delay
+	}
+	spread := (rand.Float64()*2 - 1) * c.jitter
+	delay = time.Duration(float64(delay) * (1 + spread))
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	return delay
+}
+
+// retryable decides whether a failed attempt with the given exit code may be
+// retried. It returns why not when it may not.
+func (c retryConfig) retryable(exitCode int) (bool, string) {
+	if containsCode(c.abortOn, exitCode) {
+		return false, fmt.Sprintf("exit code %d is in abortOn", exitCode)
+	}
+	if len(c.retryOn) > 0 && !containsCode(c.retryOn, exitCode) {
+		return false, fmt.Sprintf("exit code %d is not in retryOn", exitCode)
+	}
+	return true, ""
+}
+
+// validate checks the retry policy of a neuron
+func (p *RetryPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("maxAttempts must not be negative")
+	}
+	switch p.Backoff {
+	case "", BackoffLinear, BackoffExponential:
+	default:
+		return fmt.Errorf("unknown backoff %q", p.Backoff)
+	}
+	if p.InitialDelay != "" {
+		if d, err := time.ParseDuration(p.InitialDelay); err != nil || d < 0 {
+			return fmt.Errorf("invalid initialDelay %q", p.InitialDelay)
+		}
+	}
+	if p.MaxDelay != "" {
+		if d, err := time.ParseDuration(p.MaxDelay); err != nil || d < 0 {
+			return fmt.Errorf("invalid maxDelay %q", p.MaxDelay)
+		}
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// waitForRetry sleeps for delay unless ctx is done first
+func waitForRetry(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry policy", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		history    *synapse.HistoryManager
+		out        *bytes.Buffer
+		attempts   string
+	)
+
+	// writeFlakyNeuron writes a neuron that exits with the given codes on
+	// successive attempts and 0 once they are used up
+	writeFlakyNeuron := func(name string, codes ...string) {
+		script := `n=$(( $(cat ` + attempts + ` 2>/dev/null || echo 0) + 1 )); echo $n > ` + attempts + `
+codes=(` + strings.Join(codes, " ") + `)
+echo "attempt $n"
+exit ${codes[$((n-1))]:-0}`
+		writeNeuron(synapseDir, name, script)
+	}
+
+	attemptCount := func() string {
+		data, err := os.ReadFile(attempts)
+		Expect(err).NotTo(HaveOccurred())
+		return strings.TrimSpace(string(data))
+	}
+
+	run := func(ctx context.Context, policy *synapse.RetryPolicy) synapse.NeuronResult {
+		syn := &synapse.Synapse{
+			Name:    "flaky",
+			Neurons: []synapse.NeuronRef{{Name: "flaky", Retry: policy}},
+		}
+		Expect(syn.Validate()).To(Succeed())
+		executor.Execute(ctx, syn, synapseDir)
+
+		records, err := history.GetHistory("flaky")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		return records[0].NeuronResults[0]
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-retry-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-retry-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		history = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+		attempts = filepath.Join(synapseDir, "attempts")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("records every attempt of a retried neuron", func() {
+		writeFlakyNeuron("flaky", "1", "3")
+
+		result := run(context.Background(), &synapse.RetryPolicy{MaxAttempts: 3, InitialDelay: "10ms"})
+		Expect(result.Status).To(Equal("success"))
+		Expect(result.Attempts).To(HaveLen(3))
+
+		var codes []int
+		for _, attempt := range result.Attempts {
+			codes = append(codes, attempt.ExitCode)
+		}
+		Expect(codes).To(Equal([]int{1, 3, 0}))
+		Expect(result.Attempts[0].Delay).To(BeZero())
+		Expect(result.Attempts[1].Delay).To(Equal(10 * time.Millisecond))
+		Expect(result.Attempts[2].Delay).To(Equal(20 * time.Millisecond))
+		Expect(result.Attempts[1].Stdout).To(Equal("attempt 2\n"))
+	})
+
+	It("does not record attempts for neurons that ran once", func() {
+		writeFlakyNeuron("flaky")
+
+		result := run(context.Background(), &synapse.RetryPolicy{MaxAttempts: 3, InitialDelay: "10ms"})
+		Expect(result.Status).To(Equal("success"))
+		Expect(result.Attempts).To(BeNil())
+	})
+
+	It("stops retrying on exit codes listed in abortOn", func() {
+		writeFlakyNeuron("flaky", "2", "2", "2")
+
+		result := run(context.Background(), &synapse.RetryPolicy{MaxAttempts: 3, InitialDelay: "10ms", AbortOn: []int{2}})
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Reason).To(Equal("not retried: exit code 2 is in abortOn"))
+		Expect(attemptCount()).To(Equal("1"))
+	})
+
+	It("only retries exit codes listed in retryOn", func() {
+		writeFlakyNeuron("flaky", "75", "1", "1")
+
+		result := run(context.Background(), &synapse.RetryPolicy{MaxAttempts: 3, InitialDelay: "10ms", RetryOn: []int{75}})
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.ExitCode).To(Equal(1))
+		Expect(result.Reason).To(Equal("not retried: exit code 1 is not in retryOn"))
+		Expect(result.Attempts).To(HaveLen(2))
+	})
+
+	It("keeps jittered delays within the configured spread", func() {
+		writeFlakyNeuron("flaky", "1", "1", "1")
+
+		result := run(context.Background(), &synapse.RetryPolicy{MaxAttempts: 4, InitialDelay: "20ms", Jitter: 0.5, MaxDelay: "50ms"})
+		Expect(result.Attempts).To(HaveLen(4))
+		Expect(result.Attempts[1].Delay).To(BeNumerically("~", 20*time.Millisecond, 10*time.Millisecond))
+		Expect(result.Attempts[2].Delay).To(BeNumerically("~", 40*time.Millisecond, 20*time.Millisecond))
+		Expect(result.Attempts[3].Delay).To(BeNumerically("<=", 50*time.Millisecond))
+	})
+
+	It("stops waiting for a retry when the execution is cancelled", func() {
+		writeFlakyNeuron("flaky", "1", "1")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+
+		start := time.Now()
+		result := run(ctx, &synapse.RetryPolicy{MaxAttempts: 2, InitialDelay: "1h"})
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(result.Status).To(Equal("failed"))
+		Expect(result.Error).To(Equal("execution cancelled"))
+		Expect(attemptCount()).To(Equal("1"))
+	})
+
+	DescribeTable("computes backoff delays",
+		func(policy synapse.RetryPolicy, expected []time.Duration) {
+			writeFlakyNeuron("flaky")
+			syn := &synapse.Synapse{Name: "flaky", Neurons: []synapse.NeuronRef{{Name: "flaky", Retry: &policy}}}
+
+			plan, err := executor.Plan(syn, synapseDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Neurons[0].RetryDelays).To(Equal(expected))
+		},
+		Entry("linear",
+			synapse.RetryPolicy{MaxAttempts: 4, Backoff: synapse.BackoffLinear, InitialDelay: "1s"},
+			[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second}),
+		Entry("exponential",
+			synapse.RetryPolicy{MaxAttempts: 5, Backoff: synapse.BackoffExponential, InitialDelay: "1s"},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}),
+		Entry("exponential capped by maxDelay",
+			synapse.RetryPolicy{MaxAttempts: 5, Backoff: synapse.BackoffExponential, InitialDelay: "1s", MaxDelay: "3s"},
+			[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}),
+		Entry("default initial delay",
+			synapse.RetryPolicy{MaxAttempts: 2},
+			[]time.Duration{time.Second}),
+	)
+
+	It("does not overflow with many exponential retries", func() {
+		writeFlakyNeuron("flaky")
+		policy := synapse.RetryPolicy{MaxAttempts: 100, Backoff: synapse.BackoffExponential, InitialDelay: "1s"}
+		syn := &synapse.Synapse{Name: "flaky", Neurons: []synapse.NeuronRef{{Name: "flaky", Retry: &policy}}}
+
+		plan, err := executor.Plan(syn, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		delays := plan.Neurons[0].RetryDelays
+		for i := 1; i < len(delays); i++ {
+			Expect(delays[i]).To(BeNumerically(">=", delays[i-1]))
+		}
+		Expect(delays[len(delays)-1]).To(Equal(24 * time.Hour))
+	})
+
+	DescribeTable("rejects invalid policies",
+		func(policy synapse.RetryPolicy, message string) {
+			syn := &synapse.Synapse{Name: "flaky", Neurons: []synapse.NeuronRef{{Name: "flaky", Retry: &policy}}}
+			Expect(syn.Validate()).To(MatchError("neuron flaky has invalid retry policy: " + message))
+		},
+		Entry("jitter above 1", synapse.RetryPolicy{MaxAttempts: 2, Jitter: 1.5}, "jitter must be between 0 and 1"),
+		Entry("bad maxDelay", synapse.RetryPolicy{MaxAttempts: 2, MaxDelay: "soon"}, `invalid maxDelay "soon"`),
+		Entry("bad initialDelay", synapse.RetryPolicy{MaxAttempts: 2, InitialDelay: "-1s"}, `invalid initialDelay "-1s"`),
+		Entry("unknown backoff", synapse.RetryPolicy{MaxAttempts: 2, Backoff: "random"}, `unknown backoff "random"`),
+	)
+})
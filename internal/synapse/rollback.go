@@ -0,0 +1,65 @@
+package synapse
+
+import "context"
+
+// rollback runs the undo neurons of the neurons that completed successfully,
+// most recently completed first, compensating for their effects after the
+// execution failed
+func (e *Executor) rollback(ctx context.Context, run *executionRun) {
+	refs := make(map[string]NeuronRef, len(run.synapse.Neurons))
+	for _, neuronRef := range run.synapse.Neurons {
+		refs[neuronRef.Name] = neuronRef
+	}
+	results := make(map[string]NeuronResult, len(run.record.NeuronResults))
+	for _, result := range run.record.NeuronResults {
+		results[result.Name] = result
+	}
+
+	var undo []string
+	for i := len(run.completed) - 1; i >= 0; i-- {
+		name := run.completed[i]
+		if refs[name].Undo != "" && results[name].Status == "success" {
+			undo = append(undo, name)
+		}
+	}
+	if len(undo) == 0 {
+		return
+	}
+
+	e.printf(run, "Rolling back %d completed neurons", len(undo))
+	for _, name := range undo {
+		e.runRollback(ctx, run, refs[name].Undo, name, results)
+	}
+}
+
+// runRollback runs a compensating neuron for forNeuron and records its result.
+// Like a dependent, it receives the outputs of forNeuron in its environment.
+// Rollbacks run even when the execution was cancelled or timed out.
+func (e *Executor) runRollback(ctx context.Context, run *executionRun, name string, forNeuron string, upstream map[string]NeuronResult) {
+	ctx = context.WithoutCancel(ctx)
+
+	result := e.executeNeuronWithRetry(ctx, run, NeuronRef{Name: name, DependsOn: []string{forNeuron}}, upstream)
+	if isFailure(result.Status) {
+		e.printf(run, "Rollback %s for %s failed", name, forNeuron)
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.record.Rollbacks = append(run.record.Rollbacks, RollbackResult{
+		NeuronResult: result,
+		For:          forNeuron,
+	})
+}
+
+// rollbackStatus summarizes the outcome of the rollbacks of an execution
+func rollbackStatus(rollbacks []RollbackResult) string {
+	if len(rollbacks) == 0 {
+		return ""
+	}
+	for _, rollback := range rollbacks {
+		if isFailure(rollback.Status) {
+			return "failed"
+		}
+	}
+	return "success"
+}
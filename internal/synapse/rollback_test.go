@@ -0,0 +1,170 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollback", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		history    *synapse.HistoryManager
+		out        *bytes.Buffer
+		journal    string
+	)
+
+	execute := func(syn *synapse.Synapse) synapse.ExecutionRecord {
+		Expect(syn.Validate()).To(Succeed())
+		executor.Execute(context.Background(), syn, synapseDir)
+
+		records, err := history.GetHistory(syn.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		return records[0]
+	}
+
+	journalLines := func() string {
+		data, _ := os.ReadFile(journal)
+		return string(data)
+	}
+
+	rollbackNames := func(record synapse.ExecutionRecord) []string {
+		var names []string
+		for _, rollback := range record.Rollbacks {
+			names = append(names, rollback.Name+" for "+rollback.For)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-rollback-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-rollback-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		out = &bytes.Buffer{}
+		history = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+		journal = filepath.Join(synapseDir, "journal")
+
+		writeNeuron(synapseDir, "scale_down", `echo scale_down >> `+journal+`; echo "REPLICAS=3" >> "$CORTEX_OUTPUT"`)
+		writeNeuron(synapseDir, "drain_node", `echo drain_node >> `+journal)
+		writeNeuron(synapseDir, "upgrade", `echo upgrade >> `+journal+`; exit 1`)
+		writeNeuron(synapseDir, "scale_up", `echo "scale_up $REPLICAS" >> `+journal)
+		writeNeuron(synapseDir, "uncordon", `echo uncordon >> `+journal)
+		writeNeuron(synapseDir, "broken_undo", `exit 7`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("undoes completed neurons in reverse order when the synapse fails", func() {
+		record := execute(&synapse.Synapse{
+			Name: "upgrade",
+			Neurons: []synapse.NeuronRef{
+				{Name: "scale_down", Undo: "scale_up"},
+				{Name: "drain_node", Undo: "uncordon"},
+				{Name: "upgrade", Undo: "broken_undo"},
+			},
+		})
+
+		Expect(journalLines()).To(Equal("scale_down\ndrain_node\nupgrade\nuncordon\nscale_up 3\n"))
+		Expect(rollbackNames(record)).To(Equal([]string{"uncordon for drain_node", "scale_up for scale_down"}))
+		Expect(record.RollbackStatus).To(Equal("success"))
+		Expect(record.Status).To(Equal("partial"))
+		Expect(out.String()).To(ContainSubstring("Rolling back 2 completed neurons"))
+	})
+
+	It("does not roll back successful executions", func() {
+		record := execute(&synapse.Synapse{
+			Name:    "healthy",
+			Neurons: []synapse.NeuronRef{{Name: "scale_down", Undo: "scale_up"}},
+		})
+
+		Expect(record.Status).To(Equal("success"))
+		Expect(record.Rollbacks).To(BeEmpty())
+		Expect(record.RollbackStatus).To(BeEmpty())
+	})
+
+	It("records failed rollbacks", func() {
+		record := execute(&synapse.Synapse{
+			Name:        "stuck",
+			StopOnError: true,
+			Neurons: []synapse.NeuronRef{
+				{Name: "drain_node", Undo: "broken_undo"},
+				{Name: "upgrade"},
+				{Name: "scale_down", Undo: "scale_up"},
+			},
+		})
+
+		Expect(record.Status).To(Equal("failed"))
+		Expect(rollbackNames(record)).To(Equal([]string{"broken_undo for drain_node"}))
+		Expect(record.Rollbacks[0].Status).To(Equal("failed"))
+		Expect(record.Rollbacks[0].ExitCode).To(Equal(7))
+		Expect(record.RollbackStatus).To(Equal("failed"))
+	})
+
+	It("undoes in completion order for parallel synapses", func() {
+		writeNeuron(synapseDir, "slow_drain", `sleep 0.3; echo slow_drain >> `+journal)
+
+		record := execute(&synapse.Synapse{
+			Name:      "parallel-upgrade",
+			Execution: synapse.ExecutionParallel,
+			Neurons: []synapse.NeuronRef{
+				{Name: "slow_drain", Undo: "uncordon"},
+				{Name: "scale_down", Undo: "scale_up"},
+				{Name: "upgrade", DependsOn: []string{"slow_drain", "scale_down"}},
+			},
+		})
+
+		Expect(rollbackNames(record)).To(Equal([]string{"uncordon for slow_drain", "scale_up for scale_down"}))
+	})
+
+	It("records onFailure rollback neurons with their outcome", func() {
+		record := execute(&synapse.Synapse{
+			Name: "on-failure",
+			Neurons: []synapse.NeuronRef{
+				{Name: "upgrade", OnFailure: []string{"uncordon", "broken_undo"}},
+			},
+		})
+
+		Expect(rollbackNames(record)).To(Equal([]string{"uncordon for upgrade", "broken_undo for upgrade"}))
+		Expect(record.Rollbacks[0].Status).To(Equal("success"))
+		Expect(record.Rollbacks[1].Status).To(Equal("failed"))
+		Expect(record.RollbackStatus).To(Equal("failed"))
+	})
+
+	It("rolls back after the synapse timed out", func() {
+		writeNeuron(synapseDir, "hang", "sleep 30")
+
+		record := execute(&synapse.Synapse{
+			Name:    "timed-out",
+			Timeout: "500ms",
+			Neurons: []synapse.NeuronRef{
+				{Name: "scale_down", Undo: "scale_up"},
+				{Name: "hang"},
+			},
+		})
+
+		Expect(record.Status).To(Equal("failed"))
+		Expect(record.RollbackStatus).To(Equal("success"))
+		Expect(journalLines()).To(HaveSuffix("scale_up 3\n"))
+	})
+
+	It("rejects neurons that undo themselves", func() {
+		syn := &synapse.Synapse{Name: "loop", Neurons: []synapse.NeuronRef{{Name: "upgrade", Undo: "upgrade"}}}
+		Expect(syn.Validate()).To(MatchError("neuron upgrade cannot undo itself"))
+	})
+})
@@ -0,0 +1,138 @@
+package synapse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// defaultMaxConcurrency is used when a parallel synapse does not set maxConcurrency
+const defaultMaxConcurrency = 5
+
+// maxConcurrency returns how many neuron processes of the synapse may run at
+// once: maxConcurrency if set, else defaultMaxConcurrency in parallel synapses
+// and one in sequential ones
+func (s *Synapse) maxConcurrency() int {
+	switch {
+	case s.MaxConcurrency > 0:
+		return s.MaxConcurrency
+	case s.Execution == ExecutionParallel:
+		return defaultMaxConcurrency
+	default:
+		return 1
+	}
+}
+
+// neuronCompletion is sent by a worker when a neuron finishes
+type neuronCompletion struct {
+	name   string
+	result NeuronResult
+}
+
+// executeParallel executes neurons as a dependency graph. A neuron is started
+// as soon as its last dependency finishes and a concurrency slot is free; all
+// scheduling state is owned by this goroutine, workers only report completions.
+// Matrix neurons do not take a slot themselves, their items share the slots of
+// the execution with the other neurons.
+func (e *Executor) executeParallel(ctx context.Context, run *executionRun) error {
+	synapse := run.synapse
+	maxConcurrency := cap(run.slots)
+	e.printf(run, "Executing in parallel (max concurrency: %d)", maxConcurrency)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Build the dependency graph
+	order := make(map[string]int, len(synapse.Neurons))
+	refs := make(map[string]NeuronRef, len(synapse.Neurons))
+	pending := make(map[string]int, len(synapse.Neurons))
+	dependents := make(map[string][]string)
+	for i, neuronRef := range synapse.Neurons {
+		order[neuronRef.Name] = i
+		refs[neuronRef.Name] = neuronRef
+		pending[neuronRef.Name] = len(neuronRef.DependsOn)
+		for _, dep := range neuronRef.DependsOn {
+			dependents[dep] = append(dependents[dep], neuronRef.Name)
+		}
+	}
+
+	var ready []string
+	for _, neuronRef := range synapse.Neurons {
+		if pending[neuronRef.Name] == 0 {
+			ready = append(ready, neuronRef.Name)
+		}
+	}
+
+	results := make(map[string]NeuronResult, len(synapse.Neurons))
+	done := make(chan neuronCompletion)
+	running := 0
+	var stopErr error
+
+	for {
+		// Start every ready neuron a slot is available for, in declaration order
+		for stopErr == nil && ctx.Err() == nil && running < maxConcurrency && len(ready) > 0 {
+			name := ready[0]
+			ready = ready[1:]
+
+			upstream := make(map[string]NeuronResult, len(results))
+			for k, v := range results {
+				upstream[k] = v
+			}
+
+			running++
+			go func(neuronRef NeuronRef) {
+				release := func() {}
+				if neuronRef.Matrix == nil {
+					release = run.acquire()
+				}
+				result := e.runNeuron(ctx, run, neuronRef, upstream)
+				release()
+				done <- neuronCompletion{name: neuronRef.Name, result: result}
+			}(refs[name])
+		}
+
+		if running == 0 {
+			break
+		}
+
+		completion := <-done
+		running--
+		results[completion.name] = completion.result
+		run.completed = append(run.completed, completion.name)
+
+		if isFailure(completion.result.Status) && synapse.StopOnError && stopErr == nil {
+			e.printf(run, "Stopping execution due to error in %s", completion.name)
+			stopErr = fmt.Errorf("neuron %s failed: %s", completion.name, completion.result.Error)
+			cancel()
+		}
+
+		// Release dependents whose last dependency just finished
+		for _, dependent := range dependents[completion.name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.SliceStable(ready, func(i, j int) bool {
+			return order[ready[i]] < order[ready[j]]
+		})
+	}
+
+	// Record results in declaration order so history is deterministic
+	for _, neuronRef := range synapse.Neurons {
+		if result, ok := results[neuronRef.Name]; ok {
+			run.record.NeuronResults = append(run.record.NeuronResults, result)
+		}
+	}
+
+	if stopErr != nil {
+		return stopErr
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s", contextErrorMessage(ctx))
+	}
+	if len(results) < len(synapse.Neurons) {
+		return fmt.Errorf("deadlock detected: some neurons cannot execute due to unmet dependencies")
+	}
+	return nil
+}
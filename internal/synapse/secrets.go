@@ -0,0 +1,45 @@
+package synapse
+
+import "github.com/anoop2811/cortex/internal/secret"
+
+// maskRecord masks secrets in everything an execution record keeps of the
+// neurons' output, before it is written to history
+func maskRecord(record *ExecutionRecord, masker *secret.Masker) {
+	record.ErrorMessage = masker.Mask(record.ErrorMessage)
+	for k, v := range record.Inputs {
+		record.Inputs[k] = masker.Mask(v)
+	}
+	maskResults(record.NeuronResults, masker)
+	for i := range record.Rollbacks {
+		maskResult(&record.Rollbacks[i].NeuronResult, masker)
+	}
+}
+
+func maskResults(results []NeuronResult, masker *secret.Masker) {
+	for i := range results {
+		maskResult(&results[i], masker)
+	}
+}
+
+func maskResult(result *NeuronResult, masker *secret.Masker) {
+	result.Stdout = masker.Mask(result.Stdout)
+	result.Stderr = masker.Mask(result.Stderr)
+	result.Error = masker.Mask(result.Error)
+	result.Reason = masker.Mask(result.Reason)
+	for k, v := range result.Outputs {
+		result.Outputs[k] = masker.Mask(v)
+	}
+	for i := range result.Attempts {
+		attempt := &result.Attempts[i]
+		attempt.Stdout = masker.Mask(attempt.Stdout)
+		attempt.Stderr = masker.Mask(attempt.Stderr)
+		attempt.Error = masker.Mask(attempt.Error)
+	}
+	for i := range result.Fixes {
+		maskResult(&result.Fixes[i].NeuronResult, masker)
+	}
+	maskResults(result.Items, masker)
+	if result.SubExecution != nil {
+		maskRecord(result.SubExecution, masker)
+	}
+}
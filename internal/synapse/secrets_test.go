@@ -0,0 +1,107 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/secret"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secrets", func() {
+	var (
+		synapseDir string
+		historyDir string
+		executor   *synapse.Executor
+		history    *synapse.HistoryManager
+		out        *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-secrets-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		historyDir, err = os.MkdirTemp("", "cortex-secrets-history-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		store := secret.NewStore(filepath.Join(synapseDir, "store"))
+		Expect(store.Set("kube-token", "stored-kube-token")).To(Succeed())
+
+		out = &bytes.Buffer{}
+		history = synapse.NewHistoryManager(historyDir)
+		executor = synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), history, out)
+		executor.SetSecretStore(store)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(historyDir)
+	})
+
+	It("injects secrets into neurons and masks them everywhere", func() {
+		tokenFile := filepath.Join(synapseDir, "token")
+		Expect(os.WriteFile(tokenFile, []byte("file-secret-value\n"), 0600)).To(Succeed())
+		writeNeuron(synapseDir, "leaky", `echo "token=$KUBE_TOKEN file=$FILE_TOKEN region=$REGION"
+echo "$KUBE_TOKEN" >&2
+echo "TOKEN=$FILE_TOKEN" >> "$CORTEX_OUTPUT"
+exit 1`)
+
+		syn := &synapse.Synapse{
+			Name: "leaky",
+			Env: secret.Env{
+				"KUBE_TOKEN": {FromStore: "kube-token"},
+				"FILE_TOKEN": {FromFile: tokenFile},
+				"REGION":     {Value: "eu-west"},
+			},
+			Neurons: []synapse.NeuronRef{{Name: "leaky", Retry: &synapse.RetryPolicy{MaxAttempts: 2, InitialDelay: "10ms"}}},
+		}
+		Expect(syn.Validate()).To(Succeed())
+		Expect(executor.Execute(context.Background(), syn, synapseDir)).To(Succeed())
+
+		Expect(out.String()).To(ContainSubstring("token=*** file=*** region=eu-west"))
+		Expect(out.String()).NotTo(ContainSubstring("stored-kube-token"))
+		Expect(os.Getenv("KUBE_TOKEN")).To(BeEmpty())
+
+		data, err := os.ReadFile(filepath.Join(historyDir, "leaky.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("stored-kube-token"))
+		Expect(string(data)).NotTo(ContainSubstring("file-secret-value"))
+
+		records, err := history.GetHistory("leaky")
+		Expect(err).NotTo(HaveOccurred())
+		result := records[0].NeuronResults[0]
+		Expect(result.Stderr).To(Equal("***\n"))
+		Expect(result.Outputs).To(Equal(map[string]string{"TOKEN": "***"}))
+		Expect(result.Attempts[0].Stdout).To(Equal("token=*** file=*** region=eu-west\n"))
+	})
+
+	It("masks secrets of a neuron's own env", func() {
+		neuronsDir := filepath.Join(synapseDir, "neurons")
+		Expect(os.MkdirAll(neuronsDir, 0755)).To(Succeed())
+		script := filepath.Join(neuronsDir, "own.sh")
+		Expect(os.WriteFile(script, []byte("#!/bin/bash\necho \"using $API_KEY\"\n"), 0755)).To(Succeed())
+		config := "name: own\ntype: check\nexec_file: " + script + "\nenv:\n  API_KEY:\n    fromStore: kube-token\n"
+		Expect(os.WriteFile(filepath.Join(neuronsDir, "own.yml"), []byte(config), 0644)).To(Succeed())
+
+		record, err := executor.Run(context.Background(), &synapse.Synapse{Name: "own", Neurons: []synapse.NeuronRef{{Name: "own"}}}, synapseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resultByName(*record, "own").Stdout).To(Equal("using ***\n"))
+		Expect(out.String()).To(ContainSubstring("using ***"))
+	})
+
+	It("does not execute when a secret cannot be resolved", func() {
+		writeNeuron(synapseDir, "leaky", `echo never`)
+		syn := &synapse.Synapse{
+			Name:    "missing",
+			Env:     secret.Env{"TOKEN": {FromStore: "missing"}},
+			Neurons: []synapse.NeuronRef{{Name: "leaky"}},
+		}
+		Expect(executor.Execute(context.Background(), syn, synapseDir)).To(MatchError("failed to resolve env: env TOKEN: secret not found: missing"))
+		Expect(out.String()).NotTo(ContainSubstring("never"))
+	})
+})
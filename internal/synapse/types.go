@@ -2,9 +2,12 @@ package synapse
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/secret"
 )
 
 // ExecutionMode defines how neurons should be executed
@@ -23,25 +26,67 @@ const (
 	BackoffLinear      BackoffStrategy = "linear"
 )
 
+// TriggerRule defines when a neuron runs based on the outcome of its dependencies
+type TriggerRule string
+
+const (
+	TriggerOnSuccess TriggerRule = "on-success" // all dependencies succeeded (default)
+	TriggerOnFailure TriggerRule = "on-failure" // at least one dependency failed
+	TriggerAlways    TriggerRule = "always"     // regardless of dependency outcome
+	TriggerOnSkip    TriggerRule = "on-skip"    // at least one dependency was skipped
+)
+
+// APIVersion is the version of the synapse schema understood by this release
+const APIVersion = "cortex/v1"
+
 // Synapse represents a workflow configuration
 type Synapse struct {
+	APIVersion     string              `yaml:"apiVersion,omitempty"`
 	Name           string              `yaml:"name"`
-	Definition     []neuron.Definition `yaml:"definition"`
+	Inputs         []Input             `yaml:"inputs,omitempty"`     // values the synapse is parameterized with
+	Env            secret.Env          `yaml:"env,omitempty"`        // added to the environment of every neuron
+	Definition     []neuron.Definition `yaml:"definition,omitempty"` // neuron locations and fix maps, by name
 	Neurons        []NeuronRef         `yaml:"neurons"`
-	Execution      ExecutionMode       `yaml:"execution"`
-	StopOnError    bool                `yaml:"stopOnError"`
-	MaxConcurrency int                 `yaml:"maxConcurrency"`
+	Execution      ExecutionMode       `yaml:"execution,omitempty"`
+	StopOnError    bool                `yaml:"stopOnError,omitempty"`
+	MaxConcurrency int                 `yaml:"maxConcurrency,omitempty"`
 	Resources      *ResourceLimits     `yaml:"resources,omitempty"`
 	Timeout        string              `yaml:"timeout,omitempty"`
+	Target         *neuron.Target      `yaml:"target,omitempty"` // host neurons without a target of their own run on
+
+	dir string // directory the synapse was loaded from, if any
+}
+
+// definition returns the definition of the named neuron, nil if it has none
+func (s *Synapse) definition(name string) *neuron.Definition {
+	for i := range s.Definition {
+		if s.Definition[i].Name == name {
+			return &s.Definition[i]
+		}
+	}
+	return nil
 }
 
 // NeuronRef references a neuron with execution metadata
 type NeuronRef struct {
-	Name      string        `yaml:"name"`
-	Condition string        `yaml:"condition,omitempty"`
-	Retry     *RetryPolicy  `yaml:"retry,omitempty"`
-	OnFailure []string      `yaml:"onFailure,omitempty"`
-	DependsOn []string      `yaml:"dependsOn,omitempty"`
+	Name        string       `yaml:"name"`
+	Condition   string       `yaml:"condition,omitempty"`
+	Retry       *RetryPolicy `yaml:"retry,omitempty"`
+	OnFailure   []string     `yaml:"onFailure,omitempty"`
+	DependsOn   []string     `yaml:"dependsOn,omitempty"`
+	TriggerRule TriggerRule  `yaml:"triggerRule,omitempty"`
+	Timeout     string       `yaml:"timeout,omitempty"` // overrides the timeout in the neuron's own config
+	Undo        string       `yaml:"undo,omitempty"`    // compensates for this neuron if the synapse fails
+	// Fix maps exit codes to neurons that fix the problem, after which this
+	// neuron is run again; it extends the fix map of the neuron's definition
+	Fix             map[int]string `yaml:"fix,omitempty"`
+	MaxRemediations int            `yaml:"maxRemediations,omitempty"` // fix/re-check rounds, 1 by default
+	// Synapse runs the synapse in this directory, relative to the directory
+	// of the referencing synapse, instead of a neuron
+	Synapse string            `yaml:"synapse,omitempty"`
+	Inputs  map[string]string `yaml:"inputs,omitempty"` // passed to the sub-synapse on top of the parent's
+	Matrix  *Matrix           `yaml:"matrix,omitempty"` // runs the neuron once per item
+	Poll    *PollPolicy       `yaml:"poll,omitempty"`   // re-runs the neuron until a criterion holds
 }
 
 // UnmarshalYAML implements custom unmarshaling to support both string and object formats
@@ -69,24 +114,108 @@ type RetryPolicy struct {
 	MaxAttempts  int             `yaml:"maxAttempts"`
 	Backoff      BackoffStrategy `yaml:"backoff"`
 	InitialDelay string          `yaml:"initialDelay"`
+	MaxDelay     string          `yaml:"maxDelay,omitempty"` // caps the wait before any retry
+	Jitter       float64         `yaml:"jitter,omitempty"`   // randomizes each wait by up to ±this fraction
+	RetryOn      []int           `yaml:"retryOn,omitempty"`  // only retry these exit codes
+	AbortOn      []int           `yaml:"abortOn,omitempty"`  // never retry these exit codes
 }
 
-// ResourceLimits defines resource constraints
+// MatrixRule decides whether a matrix neuron passes, from its items
+type MatrixRule string
+
+const (
+	MatrixAll       MatrixRule = "all"       // every item passed (default)
+	MatrixAny       MatrixRule = "any"       // at least one item passed
+	MatrixThreshold MatrixRule = "threshold" // at least threshold items passed
+)
+
+// Matrix fans a neuron out into one execution per item. Each item is a set
+// of variables exported to the neuron's environment.
+type Matrix struct {
+	Values    map[string][]string `yaml:"values,omitempty" json:"values,omitempty"`       // variable → values; every combination is an item
+	From      string              `yaml:"from,omitempty" json:"from,omitempty"`           // <neuron>.<OUTPUT> of a dependency, split on commas and whitespace
+	As        string              `yaml:"as,omitempty" json:"as,omitempty"`               // variable holding the values of From, ITEM by default
+	Pass      MatrixRule          `yaml:"pass,omitempty" json:"pass,omitempty"`           // all, any or threshold
+	Threshold string              `yaml:"threshold,omitempty" json:"threshold,omitempty"` // items that must pass: a count, or a percentage such as 80%
+}
+
+// ResourceLimits defines resource constraints applied to each neuron process
 type ResourceLimits struct {
-	Memory string `yaml:"memory"`
+	Memory       string `yaml:"memory"`                 // e.g. 256Mi, 1G
+	CPUTime      string `yaml:"cpuTime,omitempty"`      // e.g. 30s
+	MaxProcesses int    `yaml:"maxProcesses,omitempty"` // processes and threads
+	MaxOpenFiles int    `yaml:"maxOpenFiles,omitempty"` // file descriptors
 }
 
-type Plan struct {
-	Config Config `yaml:"config"`
+// NeuronLimits converts the limits into the form enforced on neuron processes
+func (r *ResourceLimits) NeuronLimits() (*neuron.Limits, error) {
+	if r == nil {
+		return nil, nil
+	}
+	limits := &neuron.Limits{}
+
+	if r.Memory != "" {
+		memory, err := parseByteSize(r.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory limit %q: %w", r.Memory, err)
+		}
+		limits.Memory = memory
+	}
+	if r.CPUTime != "" {
+		cpuTime, err := time.ParseDuration(r.CPUTime)
+		if err != nil || cpuTime <= 0 {
+			return nil, fmt.Errorf("invalid cpuTime limit %q", r.CPUTime)
+		}
+		limits.CPUTime = cpuTime
+	}
+	if r.MaxProcesses < 0 {
+		return nil, fmt.Errorf("invalid maxProcesses limit: %d", r.MaxProcesses)
+	}
+	limits.MaxProcesses = uint64(r.MaxProcesses)
+	if r.MaxOpenFiles < 0 {
+		return nil, fmt.Errorf("invalid maxOpenFiles limit: %d", r.MaxOpenFiles)
+	}
+	limits.MaxOpenFiles = uint64(r.MaxOpenFiles)
+
+	return limits, nil
 }
 
-type Steps struct {
-	Serial   []string `yaml:"serial"`
-	Parallel []string `yaml:"parallel"`
+// byteSizeUnits maps size suffixes to multipliers, binary (Ki) and decimal (K)
+var byteSizeUnits = map[string]uint64{
+	"":   1,
+	"k":  1000,
+	"m":  1000 * 1000,
+	"g":  1000 * 1000 * 1000,
+	"t":  1000 * 1000 * 1000 * 1000,
+	"ki": 1 << 10,
+	"mi": 1 << 20,
+	"gi": 1 << 30,
+	"ti": 1 << 40,
 }
 
-type Config struct {
-	ExitOnFirstError bool `yaml:"exit_on_first_error"`
+// parseByteSize parses sizes such as 512, 256Mi or 1G
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("missing number")
+	}
+	value, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s[i:])), "b")
+	multiplier, ok := byteSizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", s[i:])
+	}
+	if value == 0 {
+		return 0, fmt.Errorf("size must be positive")
+	}
+	return value * multiplier, nil
 }
 
 // GetTimeoutDuration parses timeout string to duration
@@ -97,13 +226,31 @@ func (s *Synapse) GetTimeoutDuration() (time.Duration, error) {
 	return time.ParseDuration(s.Timeout)
 }
 
-// Validate checks if the synapse configuration is valid
+// Validate checks if the synapse configuration is valid. Sub-synapses of a
+// synapse loaded from a directory are loaded and validated as well.
 func (s *Synapse) Validate() error {
+	return s.validate(nil)
+}
+
+// validate checks the synapse; stack holds the directories of the synapses
+// that reference it, to detect cycles across files
+func (s *Synapse) validate(stack []string) error {
+	if s.APIVersion != "" && s.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q (expected %s)", s.APIVersion, APIVersion)
+	}
 	if s.Name == "" {
 		return fmt.Errorf("synapse name cannot be empty")
 	}
-	if len(s.Neurons) == 0 {
-		return fmt.Errorf("synapse must have at least one neuron")
+	if err := s.validateInputs(); err != nil {
+		return err
+	}
+	if err := s.Env.Validate(); err != nil {
+		return err
+	}
+	if s.Target != nil {
+		if err := s.Target.Validate(); err != nil {
+			return err
+		}
 	}
 
 	// Check for duplicate neuron names
@@ -124,12 +271,119 @@ func (s *Synapse) Validate() error {
 		}
 	}
 
+	// Validate neuron definitions
+	defined := make(map[string]bool)
+	for _, def := range s.Definition {
+		if def.Name == "" {
+			return fmt.Errorf("neuron definition without a name")
+		}
+		if defined[def.Name] {
+			return fmt.Errorf("duplicate neuron definition: %s", def.Name)
+		}
+		defined[def.Name] = true
+		if def.Config.Path == "" {
+			return fmt.Errorf("neuron definition %s has no path", def.Name)
+		}
+	}
+
+	// Validate resource limits
+	if _, err := s.Resources.NeuronLimits(); err != nil {
+		return err
+	}
+
+	// Validate undo neurons
+	for _, neuron := range s.Neurons {
+		if neuron.Undo == neuron.Name {
+			return fmt.Errorf("neuron %s cannot undo itself", neuron.Name)
+		}
+	}
+
+	// Validate fix mappings
+	for _, neuron := range s.Neurons {
+		if neuron.MaxRemediations < 0 {
+			return fmt.Errorf("neuron %s has negative maxRemediations", neuron.Name)
+		}
+		for code, fix := range s.fixesFor(neuron) {
+			if fix == "" || fix == neuron.Name {
+				return fmt.Errorf("neuron %s has invalid fix for exit code %d: %q", neuron.Name, code, fix)
+			}
+		}
+	}
+
+	// Validate matrices
+	for _, neuron := range s.Neurons {
+		if err := neuron.Matrix.validate(neuron); err != nil {
+			return fmt.Errorf("neuron %s has invalid matrix: %w", neuron.Name, err)
+		}
+	}
+
+	// Validate poll policies
+	for _, neuron := range s.Neurons {
+		if err := neuron.Poll.validate(neuron); err != nil {
+			return fmt.Errorf("neuron %s has invalid poll policy: %w", neuron.Name, err)
+		}
+	}
+
+	// Validate retry policies
+	for _, neuron := range s.Neurons {
+		if err := neuron.Retry.validate(); err != nil {
+			return fmt.Errorf("neuron %s has invalid retry policy: %w", neuron.Name, err)
+		}
+	}
+
+	// Validate neuron timeouts
+	for _, neuron := range s.Neurons {
+		if neuron.Timeout == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(neuron.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("neuron %s has invalid timeout: %s", neuron.Name, neuron.Timeout)
+		}
+	}
+
+	// Validate trigger rules
+	for _, neuron := range s.Neurons {
+		switch neuron.TriggerRule {
+		case "", TriggerOnSuccess, TriggerOnFailure, TriggerAlways, TriggerOnSkip:
+		default:
+			return fmt.Errorf("neuron %s has invalid triggerRule: %s", neuron.Name, neuron.TriggerRule)
+		}
+	}
+
+	// Validate conditions parse and reference known neurons. In parallel
+	// synapses only the results of dependencies are known by the time the
+	// condition is evaluated.
+	for _, neuron := range s.Neurons {
+		if neuron.Condition == "" {
+			continue
+		}
+		expr, err := ParseExpression(neuron.Condition)
+		if err != nil {
+			return fmt.Errorf("neuron %s has invalid condition %q: %w", neuron.Name, neuron.Condition, err)
+		}
+		for _, ref := range expr.NeuronReferences() {
+			if !seen[ref] {
+				return fmt.Errorf("neuron %s condition references non-existent neuron: %s", neuron.Name, ref)
+			}
+			if s.Execution != ExecutionParallel {
+				continue
+			}
+			isDependency := false
+			for _, dep := range neuron.DependsOn {
+				isDependency = isDependency || dep == ref
+			}
+			if !isDependency {
+				return fmt.Errorf("neuron %s condition references %s, which is not in dependsOn", neuron.Name, ref)
+			}
+		}
+	}
+
 	// Check for circular dependencies
 	if err := s.detectCircularDependencies(); err != nil {
 		return err
 	}
 
-	return nil
+	return s.validateSubSynapses(stack)
 }
 
 // detectCircularDependencies uses DFS to detect cycles in dependency graph
@@ -0,0 +1,288 @@
+package synapse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce is how long Watch waits for changes to settle
+const DefaultWatchDebounce = 300 * time.Millisecond
+
+// WatchOptions configures Watch
+type WatchOptions struct {
+	// Run executes the synapse once; it is cancelled when files change
+	Run func(ctx context.Context, synapse *Synapse) (*ExecutionRecord, error)
+	// Debounce is how long changes must settle before re-running; zero uses
+	// DefaultWatchDebounce
+	Debounce time.Duration
+	// Out receives the result of every run
+	Out io.Writer
+}
+
+// Watch runs the synapse in synapseDir and runs it again whenever a file it
+// depends on changes, until ctx is cancelled. A run still in progress when
+// files change is cancelled. After every run the results are compared to the
+// previous run.
+func Watch(ctx context.Context, synapseDir string, opts WatchOptions) error {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch files: %w", err)
+	}
+	defer watcher.Close()
+
+	type runResult struct {
+		record *ExecutionRecord
+		err    error
+	}
+
+	watched := make(map[string]bool)
+	var previous *ExecutionRecord
+	for runs := 1; ; runs++ {
+		// The synapse is loaded for every run, as its configuration may be
+		// what changed
+		syn, loadErr := LoadFromDirectory(synapseDir)
+		paths := []string{synapseDir}
+		if loadErr == nil {
+			paths = WatchPaths(syn, synapseDir)
+		}
+		updateWatches(watcher, watched, paths)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		var done chan runResult
+		if loadErr != nil {
+			fmt.Fprintf(opts.Out, "Cannot run %s: %v\n", synapseDir, loadErr)
+			fmt.Fprintf(opts.Out, "Watching %d directories for changes\n", len(watched))
+		} else {
+			done = make(chan runResult, 1)
+			go func() {
+				record, err := opts.Run(runCtx, syn)
+				done <- runResult{record, err}
+			}()
+		}
+
+		// Wait until files changed and the run, cancelled if need be, ended
+		changed := false
+		var debounce <-chan time.Time
+		for !changed || done != nil {
+			select {
+			case <-ctx.Done():
+				cancel()
+				if done != nil {
+					<-done
+				}
+				return nil
+
+			case result := <-done:
+				done = nil
+				if changed {
+					continue // cancelled, it is about to be repeated
+				}
+				if result.record == nil || result.record.ID == "" {
+					fmt.Fprintf(opts.Out, "Run %d failed: %v\n", runs, result.err)
+				} else {
+					WriteResultDiff(opts.Out, runs, previous, result.record)
+					previous = result.record
+				}
+				fmt.Fprintf(opts.Out, "Watching %d directories for changes\n", len(watched))
+
+			case event := <-watcher.Events:
+				if relevantChange(event) {
+					debounce = time.After(opts.Debounce)
+				}
+
+			case err := <-watcher.Errors:
+				fmt.Fprintf(opts.Out, "Watch error: %v\n", err)
+
+			case <-debounce:
+				debounce = nil
+				if !changed && done != nil {
+					fmt.Fprintln(opts.Out, "Files changed, cancelling the running execution")
+					cancel()
+				}
+				changed = true
+			}
+		}
+		cancel()
+		fmt.Fprintln(opts.Out, "Files changed, running again")
+	}
+}
+
+// updateWatches makes watcher watch exactly paths
+func updateWatches(watcher *fsnotify.Watcher, watched map[string]bool, paths []string) {
+	wanted := make(map[string]bool)
+	for _, path := range paths {
+		wanted[path] = true
+		if !watched[path] {
+			if err := watcher.Add(path); err == nil {
+				watched[path] = true
+			}
+		}
+	}
+	for path := range watched {
+		if !wanted[path] {
+			watcher.Remove(path)
+			delete(watched, path)
+		}
+	}
+}
+
+// relevantChange reports whether event changes a file, leaving out
+// permission changes and the temporary files of editors
+func relevantChange(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	base := filepath.Base(event.Name)
+	return !strings.HasPrefix(base, ".") && !strings.HasSuffix(base, "~") &&
+		!strings.HasSuffix(base, ".swp") && !strings.HasSuffix(base, ".swx")
+}
+
+// WatchPaths returns the directories a synapse depends on: the synapse
+// directory and its subdirectories, the directories of neurons defined
+// elsewhere and of their exec files, and those of nested synapses
+func WatchPaths(synapse *Synapse, synapseDir string) []string {
+	dirs := make(map[string]bool)
+	collectWatchPaths(synapse, synapseDir, dirs, make(map[string]bool))
+
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func collectWatchPaths(synapse *Synapse, synapseDir string, dirs, visited map[string]bool) {
+	absDir, err := filepath.Abs(synapseDir)
+	if err != nil || visited[absDir] {
+		return
+	}
+	visited[absDir] = true
+
+	addDir := func(dir string) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			if info, err := os.Stat(abs); err == nil && info.IsDir() {
+				dirs[abs] = true
+			}
+		}
+	}
+
+	filepath.Walk(absDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path != absDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		dirs[path] = true
+		return nil
+	})
+
+	// Neuron configs, whose exec files may live elsewhere
+	var configs []string
+	for _, def := range synapse.Definition {
+		if path, err := resolveNeuronPath(synapse, absDir, def.Name); err == nil {
+			configs = append(configs, path)
+		}
+	}
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(absDir, "neurons", pattern))
+		configs = append(configs, matches...)
+	}
+	for _, config := range configs {
+		addDir(filepath.Dir(config))
+		if execFile, err := neuron.ExecPath(config); err == nil {
+			addDir(filepath.Dir(execFile))
+		}
+	}
+
+	for _, ref := range synapse.Neurons {
+		if ref.Synapse == "" {
+			continue
+		}
+		dir := subSynapseDir(absDir, ref)
+		if sub, err := LoadFromDirectory(dir); err == nil {
+			collectWatchPaths(sub, dir, dirs, visited)
+		} else {
+			addDir(dir)
+		}
+	}
+}
+
+// WriteResultDiff writes a compact summary of the run-th execution. Against
+// a previous execution it lists only the neurons whose status changed;
+// without one it lists every neuron.
+func WriteResultDiff(w io.Writer, run int, previous, current *ExecutionRecord) {
+	passed, failed := 0, 0
+	for _, result := range current.NeuronResults {
+		switch {
+		case result.Status == "success":
+			passed++
+		case isFailure(result.Status):
+			failed++
+		}
+	}
+	fmt.Fprintf(w, "Run %d: %s (%d passed, %d failed) in %v\n", run, current.Status, passed, failed, current.Duration.Round(time.Millisecond))
+
+	if previous == nil {
+		for _, result := range current.NeuronResults {
+			fmt.Fprintf(w, "  %s %s: %s\n", statusMark(result.Status), result.Name, result.Status)
+		}
+		return
+	}
+
+	before := make(map[string]string)
+	for _, result := range previous.NeuronResults {
+		before[result.Name] = result.Status
+	}
+	changed := 0
+	for _, result := range current.NeuronResults {
+		was, ok := before[result.Name]
+		delete(before, result.Name)
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "  %s %s: %s (new)\n", statusMark(result.Status), result.Name, result.Status)
+		case was != result.Status:
+			fmt.Fprintf(w, "  %s %s: %s -> %s\n", statusMark(result.Status), result.Name, was, result.Status)
+		default:
+			continue
+		}
+		changed++
+	}
+	for _, result := range previous.NeuronResults {
+		if _, ok := before[result.Name]; ok {
+			fmt.Fprintf(w, "  - %s: removed\n", result.Name)
+			changed++
+		}
+	}
+	if changed == 0 {
+		fmt.Fprintln(w, "  no changes since the previous run")
+	}
+}
+
+// statusMark returns a one-character mark for a neuron status
+func statusMark(status string) string {
+	switch {
+	case status == "success":
+		return "✓"
+	case isFailure(status):
+		return "✗"
+	}
+	return "·"
+}
@@ -0,0 +1,257 @@
+package synapse_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/neuron"
+	"github.com/anoop2811/cortex/internal/synapse"
+	log "github.com/anoop2811/cortex/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// lockedBuffer is a buffer safe to read while Watch writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+var _ = Describe("Watch", func() {
+	var (
+		baseDir    string
+		synapseDir string
+	)
+
+	writeConfig := func(dir, config string) {
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, synapse.ConfigFile), []byte(config), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		baseDir, err = os.MkdirTemp("", "cortex-watch-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		baseDir, err = filepath.EvalSymlinks(baseDir)
+		Expect(err).NotTo(HaveOccurred())
+		synapseDir = filepath.Join(baseDir, "synapse")
+		writeNeuron(synapseDir, "check", "echo ok")
+		writeConfig(synapseDir, "apiVersion: cortex/v1\nname: watched\nneurons: [check]\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(baseDir)
+	})
+
+	It("watches the synapse, its neurons and nested synapses", func() {
+		shared := filepath.Join(baseDir, "shared", "disk")
+		Expect(os.MkdirAll(shared, 0755)).To(Succeed())
+		// Relative exec files are run from the working directory
+		Expect(os.WriteFile(filepath.Join(shared, "neuron.yaml"), []byte("name: disk\ntype: check\nexec_file: shared/scripts/disk.sh\n"), 0644)).To(Succeed())
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(baseDir)).To(Succeed())
+		defer os.Chdir(wd)
+		Expect(os.MkdirAll(filepath.Join(baseDir, "shared", "scripts"), 0755)).To(Succeed())
+		writeNeuron(filepath.Join(baseDir, "sub"), "inner", "echo inner")
+		writeConfig(filepath.Join(baseDir, "sub"), "apiVersion: cortex/v1\nname: sub\nneurons: [inner]\n")
+
+		syn := &synapse.Synapse{
+			Name: "watched",
+			Definition: []neuron.Definition{
+				{Name: "disk", Config: neuron.Config{Path: "../shared/disk"}},
+			},
+			Neurons: []synapse.NeuronRef{{Name: "check"}, {Name: "disk"}, {Name: "step", Synapse: "../sub"}},
+		}
+
+		Expect(synapse.WatchPaths(syn, synapseDir)).To(Equal([]string{
+			filepath.Join(baseDir, "shared", "disk"),
+			filepath.Join(baseDir, "shared", "scripts"),
+			filepath.Join(baseDir, "sub"),
+			filepath.Join(baseDir, "sub", "neurons"),
+			synapseDir,
+			filepath.Join(synapseDir, "neurons"),
+		}))
+	})
+
+	Describe("WriteResultDiff", func() {
+		record := func(statuses ...string) *synapse.ExecutionRecord {
+			r := &synapse.ExecutionRecord{ID: "1", Status: "success"}
+			for i := 0; i < len(statuses); i += 2 {
+				r.NeuronResults = append(r.NeuronResults, synapse.NeuronResult{Name: statuses[i], Status: statuses[i+1]})
+				if statuses[i+1] == "failed" {
+					r.Status = "partial"
+				}
+			}
+			return r
+		}
+
+		It("lists every neuron of the first run", func() {
+			out := &bytes.Buffer{}
+			synapse.WriteResultDiff(out, 1, nil, record("check_disk", "success", "check_pods", "failed"))
+			Expect(out.String()).To(Equal("Run 1: partial (1 passed, 1 failed) in 0s\n  ✓ check_disk: success\n  ✗ check_pods: failed\n"))
+		})
+
+		It("lists only what changed since the previous run", func() {
+			out := &bytes.Buffer{}
+			synapse.WriteResultDiff(out, 2,
+				record("check_disk", "success", "check_pods", "failed", "old", "success"),
+				record("check_disk", "success", "check_pods", "success", "new", "skipped"))
+			Expect(out.String()).To(Equal("Run 2: success (2 passed, 0 failed) in 0s\n" +
+				"  ✓ check_pods: failed -> success\n" +
+				"  · new: skipped (new)\n" +
+				"  - old: removed\n"))
+		})
+
+		It("says when nothing changed", func() {
+			out := &bytes.Buffer{}
+			synapse.WriteResultDiff(out, 3, record("check_disk", "success"), record("check_disk", "success"))
+			Expect(out.String()).To(HaveSuffix("  no changes since the previous run\n"))
+		})
+	})
+
+	Describe("re-running", func() {
+		var (
+			out     *lockedBuffer
+			mu      sync.Mutex
+			runs    []context.Context
+			cancel  context.CancelFunc
+			stopped chan struct{}
+		)
+
+		runCount := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(runs)
+		}
+
+		touch := func() {
+			Expect(os.WriteFile(filepath.Join(synapseDir, "neurons", "check.sh"), []byte("#!/bin/bash\necho changed\n"), 0755)).To(Succeed())
+		}
+
+		// watch starts watching; with block every run lasts until cancelled
+		watch := func(block bool) {
+			out = &lockedBuffer{}
+			runs = nil
+			stopped = make(chan struct{})
+
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(stopped)
+				defer GinkgoRecover()
+				Expect(synapse.Watch(ctx, synapseDir, synapse.WatchOptions{
+					Debounce: 100 * time.Millisecond,
+					Out:      out,
+					Run: func(ctx context.Context, syn *synapse.Synapse) (*synapse.ExecutionRecord, error) {
+						mu.Lock()
+						runs = append(runs, ctx)
+						mu.Unlock()
+						if block {
+							<-ctx.Done()
+						}
+						return &synapse.ExecutionRecord{
+							ID:            "run",
+							Status:        "success",
+							NeuronResults: []synapse.NeuronResult{{Name: "check", Status: "success"}},
+						}, nil
+					},
+				})).To(Succeed())
+			}()
+		}
+
+		AfterEach(func() {
+			cancel()
+			Eventually(stopped).Should(BeClosed())
+		})
+
+		It("runs again once changes settle", func() {
+			watch(false)
+			Eventually(out.String).Should(ContainSubstring("Watching 2 directories for changes"))
+			Expect(out.String()).To(ContainSubstring("Run 1: success (1 passed, 0 failed)"))
+
+			for i := 0; i < 3; i++ {
+				touch()
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			Eventually(out.String).Should(ContainSubstring("Run 2: success"))
+			Expect(out.String()).To(ContainSubstring("no changes since the previous run"))
+			Consistently(runCount, 300*time.Millisecond).Should(Equal(2), "changes are debounced")
+		})
+
+		It("keeps answering approvals of one executor across runs", func() {
+			writeNeuron(synapseDir, "restart", "echo restarted")
+			config := filepath.Join(synapseDir, "neurons", "restart.yml")
+			data, err := os.ReadFile(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(config, []byte(strings.Replace(string(data), "type: check", "type: mutate", 1)), 0644)).To(Succeed())
+			writeConfig(synapseDir, "apiVersion: cortex/v1\nname: watched\nneurons: [restart]\n")
+
+			out = &lockedBuffer{}
+			answers, answer := io.Pipe()
+			defer answer.Close()
+			executor := synapse.NewExecutor(log.NewLoggerWithWriter(0, GinkgoWriter), nil, io.Discard)
+			executor.SetApprover(synapse.NewPromptApprover(answers, out))
+
+			stopped = make(chan struct{})
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(stopped)
+				defer GinkgoRecover()
+				Expect(synapse.Watch(ctx, synapseDir, synapse.WatchOptions{
+					Debounce: 100 * time.Millisecond,
+					Out:      out,
+					Run: func(ctx context.Context, syn *synapse.Synapse) (*synapse.ExecutionRecord, error) {
+						return executor.Run(ctx, syn, synapseDir)
+					},
+				})).To(Succeed())
+			}()
+
+			prompts := func() int { return strings.Count(out.String(), "Approve? [y/N]") }
+			Eventually(prompts).Should(Equal(1))
+			fmt.Fprintln(answer, "y")
+			Eventually(out.String).Should(ContainSubstring("Run 1: success (1 passed, 0 failed)"))
+
+			touch()
+			Eventually(prompts).Should(Equal(2))
+			fmt.Fprintln(answer, "y")
+			Eventually(out.String).Should(ContainSubstring("Run 2: success"))
+		})
+
+		It("cancels a run in progress", func() {
+			watch(true)
+			Eventually(runCount).Should(Equal(1))
+
+			touch()
+
+			Eventually(runCount).Should(Equal(2))
+			mu.Lock()
+			first := runs[0]
+			mu.Unlock()
+			Expect(first.Err()).To(MatchError(context.Canceled))
+			Expect(out.String()).To(ContainSubstring("Files changed, cancelling the running execution"))
+			Expect(out.String()).NotTo(ContainSubstring("Run 1:"))
+		})
+	})
+})
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
 
+	"github.com/anoop2811/cortex/internal/schedule"
 	"github.com/anoop2811/cortex/logger"
 	"github.com/anoop2811/cortex/web/server/models"
 	"github.com/anoop2811/cortex/web/server/services"
@@ -28,6 +30,7 @@ type Handlers struct {
 	neuronService    *services.NeuronService
 	synapseService   *services.SynapseService
 	executionService *services.ExecutionService
+	scheduleService  *services.ScheduleService
 	wsHub            *services.WebSocketHub
 }
 
@@ -41,6 +44,7 @@ func NewHandlers(log *logger.StandardLogger) *Handlers {
 		neuronService:    services.NewNeuronService(log),
 		synapseService:   services.NewSynapseService(),
 		executionService: services.NewExecutionService(log, hub),
+		scheduleService:  services.NewScheduleService(log),
 		wsHub:            hub,
 	}
 }
@@ -360,6 +364,10 @@ func (h *Handlers) Execute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	execution, err := h.executionService.Execute(req)
+	if errors.Is(err, services.ErrSynapseRunning) {
+		respondJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Error(err, "Execution failed")
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -381,6 +389,73 @@ func (h *Handlers) ListExecutions(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, executions)
 }
 
+// ListApprovals handles GET /api/approvals
+func (h *Handlers) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.executionService.PendingApprovals())
+}
+
+// DecideApproval handles POST /api/executions/{id}/approval
+func (h *Handlers) DecideApproval(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req models.ApprovalDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	approval, err := h.executionService.DecideApproval(id, req.Approve)
+	if errors.Is(err, services.ErrNoPendingApproval) {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error(err, "Failed to decide approval")
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, approval)
+}
+
+// ListSchedules handles GET /api/schedules
+func (h *Handlers) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.scheduleService.ListSchedules()
+	if err != nil {
+		h.logger.Error(err, "Failed to list schedules")
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, schedules)
+}
+
+// PauseSchedule handles POST /api/schedules/{name}/pause
+func (h *Handlers) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	sc, err := h.scheduleService.PauseSchedule(mux.Vars(r)["name"])
+	h.respondSchedule(w, sc, err)
+}
+
+// ResumeSchedule handles POST /api/schedules/{name}/resume
+func (h *Handlers) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	sc, err := h.scheduleService.ResumeSchedule(mux.Vars(r)["name"])
+	h.respondSchedule(w, sc, err)
+}
+
+// respondSchedule sends a changed schedule, or 404 for unknown schedules
+func (h *Handlers) respondSchedule(w http.ResponseWriter, sc *models.Schedule, err error) {
+	if errors.Is(err, schedule.ErrNotFound) {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error(err, "Failed to update schedule")
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondJSON(w, http.StatusOK, sc)
+}
+
 // WebSocketHandler handles WebSocket connections
 func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -59,13 +59,48 @@ type Synapse struct {
 	CreatedAt   time.Time            `json:"createdAt"`
 	UpdatedAt   time.Time            `json:"updatedAt"`
 	Neurons     []string             `json:"neurons,omitempty"` // Deprecated, kept for backward compatibility
+	Legacy      bool                 `json:"legacy,omitempty"`  // defined in the legacy synapse.yaml plan format
 }
 
 // ExecuteRequest represents an execution request
 type ExecuteRequest struct {
-	Type string `json:"type"` // "neuron" or "synapse"
-	Name string `json:"name"`
-	Path string `json:"path"`
+	Type       string `json:"type"` // "neuron" or "synapse"
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	ReadOnly   bool   `json:"readOnly,omitempty"`   // skip mutate neurons of a synapse
+	ApproveAll bool   `json:"approveAll,omitempty"` // run mutate neurons without asking
+}
+
+// Approval is a mutate neuron of a running synapse waiting to be approved
+// or denied
+type Approval struct {
+	ExecutionID string    `json:"executionId"`
+	Synapse     string    `json:"synapse"`
+	Neuron      string    `json:"neuron"`
+	RequestedAt time.Time `json:"requestedAt"`
+	Decision    string    `json:"decision,omitempty"` // "approved" or "denied" once decided
+}
+
+// ApprovalDecisionRequest approves or denies a pending approval
+type ApprovalDecisionRequest struct {
+	Approve bool `json:"approve"`
+}
+
+// Schedule is a synapse run periodically by the scheduler
+type Schedule struct {
+	Name            string     `json:"name"`
+	Synapse         string     `json:"synapse"`
+	Path            string     `json:"path"`
+	Cron            string     `json:"cron"`
+	Jitter          float64    `json:"jitter,omitempty"` // seconds
+	CatchUp         string     `json:"catchUp"`          // "skip", "once" or "all"
+	Paused          bool       `json:"paused"`
+	NextRun         time.Time  `json:"nextRun"` // start of the next run, including jitter
+	LastRun         *time.Time `json:"lastRun,omitempty"`
+	LastStatus      string     `json:"lastStatus,omitempty"`
+	LastExecutionID string     `json:"lastExecutionId,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	MissedRuns      int        `json:"missedRuns,omitempty"`
 }
 
 // ExecuteResponse represents an execution response
@@ -109,7 +144,7 @@ type SystemMetrics struct {
 
 // WebSocketMessage represents a WebSocket message
 type WebSocketMessage struct {
-	Type      string      `json:"type"` // "log", "status", "metrics", "error"
+	Type      string      `json:"type"` // "log", "status", "metrics", "error", "approval", "neuron"
 	Timestamp time.Time   `json:"timestamp"`
 	Data      interface{} `json:"data"`
 }
@@ -121,6 +156,17 @@ type LogMessage struct {
 	Message     string `json:"message"`
 }
 
+// NeuronMessage reports a neuron of a running synapse starting, being
+// retried or finishing
+type NeuronMessage struct {
+	ExecutionID string  `json:"executionId"`
+	Neuron      string  `json:"neuron"`
+	Status      string  `json:"status"` // "running", "retrying", or the final neuron status
+	Attempt     int     `json:"attempt,omitempty"`
+	ExitCode    int     `json:"exitCode"`
+	Duration    float64 `json:"duration,omitempty"` // seconds, once finished
+}
+
 // StatusMessage represents a status update
 type StatusMessage struct {
 	ExecutionID string `json:"executionId"`
@@ -76,6 +76,11 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/api/execute", h.Execute).Methods("POST")
 	s.router.HandleFunc("/api/metrics", h.GetMetrics).Methods("GET")
 	s.router.HandleFunc("/api/executions", h.ListExecutions).Methods("GET")
+	s.router.HandleFunc("/api/executions/{id}/approval", h.DecideApproval).Methods("POST")
+	s.router.HandleFunc("/api/approvals", h.ListApprovals).Methods("GET")
+	s.router.HandleFunc("/api/schedules", h.ListSchedules).Methods("GET")
+	s.router.HandleFunc("/api/schedules/{name}/pause", h.PauseSchedule).Methods("POST")
+	s.router.HandleFunc("/api/schedules/{name}/resume", h.ResumeSchedule).Methods("POST")
 
 	// Serve frontend static files (must be last as it's a catch-all)
 	s.serveFrontend()
@@ -123,6 +128,11 @@ func (s *Server) serveFrontend() {
             <li>POST <code>/api/execute</code> - Execute neuron or synapse</li>
             <li>GET <code>/api/metrics</code> - System metrics</li>
             <li>GET <code>/api/executions</code> - Execution history</li>
+            <li>GET <code>/api/approvals</code> - Mutate neurons waiting for approval</li>
+            <li>POST <code>/api/executions/{id}/approval</code> - Approve or deny a mutate neuron</li>
+            <li>GET <code>/api/schedules</code> - Scheduled synapses</li>
+            <li>POST <code>/api/schedules/{name}/pause</code> - Pause a schedule</li>
+            <li>POST <code>/api/schedules/{name}/resume</code> - Resume a schedule</li>
             <li>WS <code>/ws</code> - WebSocket for real-time logs</li>
         </ul>
     </div>
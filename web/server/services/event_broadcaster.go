@@ -0,0 +1,72 @@
+package services
+
+import (
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	"github.com/anoop2811/cortex/web/server/models"
+)
+
+// EventBroadcaster forwards the events of a synapse execution to WebSocket
+// clients, tagged with the Web UI execution they belong to
+type EventBroadcaster struct {
+	hub         *WebSocketHub
+	executionID string
+}
+
+// NewEventBroadcaster creates a broadcaster for the given execution
+func NewEventBroadcaster(hub *WebSocketHub, executionID string) *EventBroadcaster {
+	return &EventBroadcaster{hub: hub, executionID: executionID}
+}
+
+// OnEvent sends neuron progress as "neuron" messages and everything the
+// console would print as "log" messages
+func (b *EventBroadcaster) OnEvent(event synapse.Event) {
+	switch ev := event.(type) {
+	case synapse.NeuronStarted:
+		b.send("neuron", models.NeuronMessage{
+			ExecutionID: b.executionID,
+			Neuron:      ev.Neuron,
+			Status:      "running",
+			Attempt:     ev.Attempt,
+		})
+	case synapse.RetryScheduled:
+		b.send("neuron", models.NeuronMessage{
+			ExecutionID: b.executionID,
+			Neuron:      ev.Neuron,
+			Status:      "retrying",
+			Attempt:     ev.Attempt,
+			ExitCode:    ev.ExitCode,
+		})
+	case synapse.NeuronFinished:
+		b.send("neuron", models.NeuronMessage{
+			ExecutionID: b.executionID,
+			Neuron:      ev.Result.Name,
+			Status:      ev.Result.Status,
+			ExitCode:    ev.Result.ExitCode,
+			Duration:    ev.Result.Duration.Seconds(),
+		})
+	}
+
+	text := synapse.EventText(event)
+	if text == "" {
+		return
+	}
+	level := "info"
+	if line, ok := event.(synapse.OutputLine); ok && line.Stream == synapse.StreamStderr {
+		level = "error"
+	}
+	b.send("log", models.LogMessage{
+		ExecutionID: b.executionID,
+		Level:       level,
+		Message:     text,
+	})
+}
+
+func (b *EventBroadcaster) send(msgType string, data interface{}) {
+	b.hub.Broadcast(models.WebSocketMessage{
+		Type:      msgType,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
@@ -0,0 +1,71 @@
+package services_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	"github.com/anoop2811/cortex/web/server/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventBroadcaster", func() {
+	var (
+		client      *services.WebSocketClient
+		broadcaster *services.EventBroadcaster
+	)
+
+	// received decodes the next message sent to the client
+	received := func() map[string]interface{} {
+		var data []byte
+		Eventually(client.Send).Should(Receive(&data))
+		var message map[string]interface{}
+		Expect(json.Unmarshal(data, &message)).To(Succeed())
+		return message
+	}
+
+	BeforeEach(func() {
+		hub := services.NewWebSocketHub()
+		go hub.Run()
+		client = &services.WebSocketClient{ID: "test", Send: make(chan []byte, 16)}
+		hub.RegisterClient(client)
+		broadcaster = services.NewEventBroadcaster(hub, "web-1")
+	})
+
+	It("sends neuron progress tagged with the web execution", func() {
+		broadcaster.OnEvent(synapse.NeuronFinished{
+			ExecutionID: "cli-1",
+			Result:      synapse.NeuronResult{Name: "check_disk", Status: "failed", ExitCode: 3, Duration: 2 * time.Second},
+		})
+
+		message := received()
+		Expect(message["type"]).To(Equal("neuron"))
+		Expect(message["data"]).To(Equal(map[string]interface{}{
+			"executionId": "web-1",
+			"neuron":      "check_disk",
+			"status":      "failed",
+			"exitCode":    float64(3),
+			"duration":    float64(2),
+		}))
+	})
+
+	It("sends the console text of events as logs", func() {
+		broadcaster.OnEvent(synapse.OutputLine{Neuron: "check_disk", Stream: synapse.StreamStderr, Line: "disk full"})
+
+		message := received()
+		Expect(message["type"]).To(Equal("log"))
+		Expect(message["data"]).To(Equal(map[string]interface{}{
+			"executionId": "web-1",
+			"level":       "error",
+			"message":     "disk full",
+		}))
+	})
+
+	It("sends both a neuron update and a log for retries", func() {
+		broadcaster.OnEvent(synapse.RetryScheduled{Neuron: "check_disk", Attempt: 2, MaxAttempts: 3, Delay: time.Second, ExitCode: 1})
+
+		Expect(received()["data"]).To(HaveKeyWithValue("status", "retrying"))
+		Expect(received()["data"]).To(HaveKeyWithValue("message", "Retry attempt 2/3 for check_disk (waiting 1s)"))
+	})
+})
@@ -2,13 +2,19 @@ package services
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/anoop2811/cortex/internal/secret"
+	"github.com/anoop2811/cortex/internal/synapse"
 	"github.com/anoop2811/cortex/logger"
 	"github.com/anoop2811/cortex/web/server/models"
 	"github.com/google/uuid"
@@ -20,20 +26,114 @@ type ExecutionService struct {
 	executions map[string]*models.Execution
 	mu         sync.RWMutex
 	wsHub      *WebSocketHub
+	masker     *secret.Masker              // masks stored secrets in streamed logs
+	stdins     map[string]io.WriteCloser   // stdin of running synapses, for approval answers
+	approvals  map[string]*models.Approval // pending approval of each execution
+	locks      *synapse.LockManager        // synapse locks shared with the CLI, nil if unavailable
+	running    map[string]string           // execution of each synapse started here and still running
 }
 
+// maxEventLineBytes bounds a line of synapse output; finished executions are
+// reported with their whole record on one line
+const maxEventLineBytes = 16 * 1024 * 1024
+
+// ErrNoPendingApproval is returned when deciding on an execution that is not
+// waiting for approval
+var ErrNoPendingApproval = errors.New("no approval pending")
+
+// ErrSynapseRunning is returned when executing a synapse that is already
+// running, here or in another cortex process
+var ErrSynapseRunning = errors.New("synapse is already running")
+
 // NewExecutionService creates a new ExecutionService
 func NewExecutionService(log *logger.StandardLogger, hub *WebSocketHub) *ExecutionService {
+	locks, err := synapse.NewDefaultLockManager()
+	if err != nil {
+		log.Errorf(err, "Synapse locks unavailable")
+	}
 	return &ExecutionService{
 		logger:     log,
 		executions: make(map[string]*models.Execution),
 		wsHub:      hub,
+		masker:     secret.NewMasker(),
+		stdins:     make(map[string]io.WriteCloser),
+		approvals:  make(map[string]*models.Approval),
+		locks:      locks,
+		running:    make(map[string]string),
 	}
 }
 
+// SetLocks sets where synapse locks are kept
+func (s *ExecutionService) SetLocks(locks *synapse.LockManager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks = locks
+}
+
+// reserveSynapse marks the synapse at path as running for executionID. It
+// fails if it is already running here or holds its lock in another process;
+// the cortex process started for it takes the lock itself.
+func (s *ExecutionService) reserveSynapse(executionID, path string) (string, error) {
+	syn, err := synapse.LoadFromDirectory(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to load synapse: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if running, ok := s.running[syn.Name]; ok {
+		return "", fmt.Errorf("%w: %s (execution %s)", ErrSynapseRunning, syn.Name, running)
+	}
+	if s.locks != nil {
+		holder, locked, err := s.locks.Holder(syn.Name)
+		if err != nil {
+			s.logger.Errorf(err, "Failed to check lock of synapse %s", syn.Name)
+		}
+		if locked {
+			return "", fmt.Errorf("%w: %s (locked by %s)", ErrSynapseRunning, syn.Name, holder)
+		}
+	}
+	s.running[syn.Name] = executionID
+	return syn.Name, nil
+}
+
+// releaseSynapse marks a synapse reserved by reserveSynapse as finished
+func (s *ExecutionService) releaseSynapse(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
+
+// loadSecrets adds the values of the local secret store to the masker, so
+// that they never reach WebSocket clients. Secrets referenced from files or
+// environment variables are masked by the cortex process that resolves them.
+func (s *ExecutionService) loadSecrets() {
+	store, err := secret.DefaultStore()
+	if err != nil {
+		return
+	}
+	values, err := store.Values()
+	if err != nil {
+		s.logger.Debugf("Not masking stored secrets: %v", err)
+		return
+	}
+	s.masker.Add(values...)
+}
+
 // Execute executes a neuron or synapse
 func (s *ExecutionService) Execute(req models.ExecuteRequest) (*models.ExecuteResponse, error) {
 	executionID := uuid.New().String()
+
+	var synapseName string
+	if req.Type == "synapse" {
+		name, err := s.reserveSynapse(executionID, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		synapseName = name
+	}
+
 	execution := &models.Execution{
 		ID:        executionID,
 		Type:      req.Type,
@@ -57,7 +157,12 @@ func (s *ExecutionService) Execute(req models.ExecuteRequest) (*models.ExecuteRe
 	})
 
 	// Execute asynchronously
-	go s.runExecution(executionID, req)
+	go func() {
+		if synapseName != "" {
+			defer s.releaseSynapse(synapseName)
+		}
+		s.runExecution(executionID, req)
+	}()
 
 	return &models.ExecuteResponse{
 		ID:        executionID,
@@ -74,6 +179,7 @@ func (s *ExecutionService) runExecution(executionID string, req models.ExecuteRe
 	s.mu.RUnlock()
 
 	s.logger.Infof("🚀 Starting execution %s for %s: %s", executionID, req.Type, req.Name)
+	s.loadSecrets()
 
 	// Send initial logs
 	s.sendLog(executionID, "info", fmt.Sprintf("📋 Executing %s: %s", req.Type, req.Name))
@@ -132,8 +238,26 @@ func (s *ExecutionService) runExecution(executionID string, req models.ExecuteRe
 		// For synapses, use cortex exec command
 		s.sendLog(executionID, "debug", fmt.Sprintf("Using cortex binary: %s", cortexBinary))
 		s.sendLog(executionID, "debug", fmt.Sprintf("Executing synapse path: %s", req.Path))
-		s.logger.Infof("Executing synapse with cortex: %s exec -p %s", cortexBinary, req.Path)
-		cmd = exec.Command(cortexBinary, "exec", "-p", req.Path)
+		args := []string{"exec", "-p", req.Path, "--events", "--fail-if-locked"}
+		if req.ReadOnly {
+			args = append(args, "--read-only")
+		}
+		if req.ApproveAll {
+			args = append(args, "--approve-all")
+		}
+		s.logger.Infof("Executing synapse with cortex: %s %s", cortexBinary, strings.Join(args, " "))
+		cmd = exec.Command(cortexBinary, args...)
+
+		// Approval prompts are answered on stdin through DecideApproval
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			s.logger.Errorf(err, "Failed to create stdin pipe")
+		} else {
+			s.mu.Lock()
+			s.stdins[executionID] = stdin
+			s.mu.Unlock()
+			defer s.endApprovals(executionID)
+		}
 	}
 
 	// Create pipes for stdout and stderr to stream output in real-time
@@ -179,12 +303,25 @@ func (s *ExecutionService) runExecution(executionID string, req models.ExecuteRe
 	s.logger.Infof("✅ Command started successfully, streaming output...")
 	s.sendLog(executionID, "info", "📡 Streaming output...")
 
-	// Stream stdout in real-time
+	// Stream stdout in real-time. Synapses print their events as JSON lines,
+	// which are forwarded to WebSocket clients as they happen.
+	broadcaster := NewEventBroadcaster(s.wsHub, executionID)
 	go func() {
 		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(nil, maxEventLineBytes)
 		for scanner.Scan() {
-			line := scanner.Text()
+			line := s.masker.Mask(scanner.Text())
 			s.logger.Infof("STDOUT: %s", line)
+			if event, err := synapse.DecodeEvent([]byte(line)); err == nil {
+				broadcaster.OnEvent(event)
+				if text := synapse.EventText(event); text != "" {
+					execution.Logs = append(execution.Logs, text)
+				}
+				continue
+			}
+			if request, ok := synapse.ParseApprovalPrompt(line); ok {
+				s.requestApproval(executionID, request)
+			}
 			s.sendLog(executionID, "info", line)
 			execution.Logs = append(execution.Logs, line)
 		}
@@ -197,7 +334,7 @@ func (s *ExecutionService) runExecution(executionID string, req models.ExecuteRe
 	go func() {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			line := scanner.Text()
+			line := s.masker.Mask(scanner.Text())
 			s.logger.Errorf(nil, "STDERR: %s", line)
 			s.sendLog(executionID, "error", line)
 			execution.Logs = append(execution.Logs, line)
@@ -236,6 +373,78 @@ func (s *ExecutionService) runExecution(executionID string, req models.ExecuteRe
 	})
 }
 
+// requestApproval records that an execution waits for a mutate neuron to be
+// approved and notifies WebSocket clients
+func (s *ExecutionService) requestApproval(executionID string, request synapse.ApprovalRequest) {
+	approval := &models.Approval{
+		ExecutionID: executionID,
+		Synapse:     request.Synapse,
+		Neuron:      request.Neuron,
+		RequestedAt: time.Now(),
+	}
+	s.mu.Lock()
+	s.approvals[executionID] = approval
+	s.mu.Unlock()
+
+	s.sendWebSocketMessage("approval", *approval)
+}
+
+// PendingApprovals returns the mutate neurons waiting for a decision
+func (s *ExecutionService) PendingApprovals() []models.Approval {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	approvals := make([]models.Approval, 0, len(s.approvals))
+	for _, approval := range s.approvals {
+		approvals = append(approvals, *approval)
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].RequestedAt.Before(approvals[j].RequestedAt)
+	})
+	return approvals
+}
+
+// DecideApproval approves or denies the mutate neuron an execution waits for.
+// The decision is recorded in the synapse's execution history as made by web.
+func (s *ExecutionService) DecideApproval(executionID string, approve bool) (*models.Approval, error) {
+	s.mu.Lock()
+	approval, ok := s.approvals[executionID]
+	stdin := s.stdins[executionID]
+	if ok {
+		delete(s.approvals, executionID)
+	}
+	s.mu.Unlock()
+
+	if !ok || stdin == nil {
+		return nil, fmt.Errorf("%w for execution %s", ErrNoPendingApproval, executionID)
+	}
+
+	answer, decision := "n web\n", synapse.ApprovalDenied
+	if approve {
+		answer, decision = "y web\n", synapse.ApprovalApproved
+	}
+	if _, err := io.WriteString(stdin, answer); err != nil {
+		return nil, fmt.Errorf("failed to answer approval: %w", err)
+	}
+
+	approval.Decision = decision
+	s.sendLog(executionID, "info", fmt.Sprintf("Mutate neuron %s %s", approval.Neuron, decision))
+	s.sendWebSocketMessage("approval", *approval)
+	return approval, nil
+}
+
+// endApprovals forgets the stdin and pending approval of a finished execution
+func (s *ExecutionService) endApprovals(executionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stdin, ok := s.stdins[executionID]; ok {
+		stdin.Close()
+		delete(s.stdins, executionID)
+	}
+	delete(s.approvals, executionID)
+}
+
 // ListExecutions returns all executions
 func (s *ExecutionService) ListExecutions() []models.Execution {
 	s.mu.RLock()
@@ -254,7 +463,7 @@ func (s *ExecutionService) sendLog(executionID, level, message string) {
 	s.sendWebSocketMessage("log", models.LogMessage{
 		ExecutionID: executionID,
 		Level:       level,
-		Message:     message,
+		Message:     s.masker.Mask(message),
 	})
 }
 
@@ -0,0 +1,51 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/anoop2811/cortex/internal/synapse"
+	"github.com/anoop2811/cortex/logger"
+	"github.com/anoop2811/cortex/web/server/models"
+	"github.com/anoop2811/cortex/web/server/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExecutionService", func() {
+	var (
+		service    *services.ExecutionService
+		locks      *synapse.LockManager
+		synapseDir string
+		lockDir    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		synapseDir, err = os.MkdirTemp("", "cortex-execution-service-*")
+		Expect(err).NotTo(HaveOccurred())
+		lockDir, err = os.MkdirTemp("", "cortex-execution-locks-*")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(synapseDir, synapse.ConfigFile), []byte("apiVersion: cortex/v1\nname: restart-pods\nneurons: [restart]\n"), 0644)).To(Succeed())
+
+		locks = synapse.NewLockManager(lockDir)
+		service = services.NewExecutionService(logger.NewLoggerWithWriter(0, GinkgoWriter), services.NewWebSocketHub())
+		service.SetLocks(locks)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(synapseDir)
+		os.RemoveAll(lockDir)
+	})
+
+	It("refuses to execute a synapse another process is running", func() {
+		lock, err := locks.TryAcquire("restart-pods")
+		Expect(err).NotTo(HaveOccurred())
+		defer lock.Release()
+
+		_, err = service.Execute(models.ExecuteRequest{Type: "synapse", Path: synapseDir})
+		Expect(err).To(MatchError(services.ErrSynapseRunning))
+		Expect(err.Error()).To(ContainSubstring("restart-pods (locked by pid"))
+		Expect(service.ListExecutions()).To(BeEmpty())
+	})
+})
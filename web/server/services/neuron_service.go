@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/anoop2811/cortex/internal/ai"
+	"github.com/anoop2811/cortex/internal/synapse"
 	"github.com/anoop2811/cortex/logger"
 	"github.com/anoop2811/cortex/web/server/models"
 	"gopkg.in/yaml.v2"
@@ -140,15 +141,25 @@ func (s *NeuronService) ListSynapses() ([]models.Synapse, error) {
 				return nil
 			}
 
-			if !info.IsDir() && info.Name() == "synapse.yaml" {
-				synapse, err := s.loadSynapse(path)
-				if err != nil {
-					s.logger.Error(err, "Failed to load synapse")
-					return nil
-				}
-				synapses = append(synapses, synapse)
+			if info.IsDir() || (info.Name() != synapse.ConfigFile && info.Name() != synapse.LegacyConfigFile) {
+				return nil
+			}
+			// A directory holding both layouts is listed once, by the config it runs
+			if configPath, err := synapse.FindConfig(filepath.Dir(path)); err != nil || configPath != path {
+				return nil
 			}
 
+			syn, err := s.loadSynapse(path)
+			if err != nil {
+				s.logger.Error(err, "Failed to load synapse")
+				return nil
+			}
+			if syn.Name == "" {
+				s.logger.Debugf("Skipping %s: not a synapse config", path)
+				return nil
+			}
+			synapses = append(synapses, syn)
+
 			return nil
 		})
 
@@ -306,33 +317,28 @@ func (s *NeuronService) GenerateNeuronWithAI(req *models.GenerateNeuronRequest)
 	}, nil
 }
 
-// loadSynapse loads a synapse from a YAML file
+// loadSynapse loads a synapse from a config.yml or legacy synapse.yaml file
 func (s *NeuronService) loadSynapse(path string) (models.Synapse, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return models.Synapse{}, err
 	}
 
-	var config struct {
-		Name       string `yaml:"name"`
-		Definition []struct {
-			Name string `yaml:"name"`
-		} `yaml:"definition"`
-	}
-
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	syn, err := synapse.Parse(data)
+	if err != nil {
 		return models.Synapse{}, err
 	}
 
 	neurons := []string{}
-	for _, def := range config.Definition {
-		neurons = append(neurons, def.Name)
+	for _, ref := range syn.Neurons {
+		neurons = append(neurons, ref.Name)
 	}
 
 	return models.Synapse{
-		Name:        config.Name,
-		Description: fmt.Sprintf("Synapse: %s", config.Name),
+		Name:        syn.Name,
+		Description: fmt.Sprintf("Synapse: %s", syn.Name),
 		Path:        filepath.Dir(path),
 		Neurons:     neurons,
+		Legacy:      filepath.Base(path) == synapse.LegacyConfigFile,
 	}, nil
 }
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	"github.com/anoop2811/cortex/logger"
+	"github.com/anoop2811/cortex/web/server/models"
+)
+
+// errNoSchedules is returned when the schedule store could not be opened
+var errNoSchedules = errors.New("schedules are not available")
+
+// ScheduleService lists, pauses and resumes the schedules run by the
+// scheduler
+type ScheduleService struct {
+	logger *logger.StandardLogger
+	store  *schedule.Store
+	mu     sync.Mutex
+}
+
+// NewScheduleService creates a service for the schedules in ~/.cortex
+func NewScheduleService(log *logger.StandardLogger) *ScheduleService {
+	s := &ScheduleService{logger: log}
+	store, err := schedule.DefaultStore()
+	if err != nil {
+		log.Error(err, "Failed to open schedules")
+	}
+	s.store = store
+	return s
+}
+
+// SetStore replaces the store schedules are kept in
+func (s *ScheduleService) SetStore(store *schedule.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+}
+
+func (s *ScheduleService) getStore() (*schedule.Store, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return nil, errNoSchedules
+	}
+	return s.store, nil
+}
+
+// ListSchedules returns every schedule
+func (s *ScheduleService) ListSchedules() ([]models.Schedule, error) {
+	store, err := s.getStore()
+	if err != nil {
+		return []models.Schedule{}, nil
+	}
+	schedules, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.Schedule, 0, len(schedules))
+	for _, sc := range schedules {
+		result = append(result, toScheduleModel(sc))
+	}
+	return result, nil
+}
+
+// PauseSchedule stops the named schedule from running. It returns an error
+// matching schedule.ErrNotFound for unknown schedules.
+func (s *ScheduleService) PauseSchedule(name string) (*models.Schedule, error) {
+	store, err := s.getStore()
+	if err != nil {
+		return nil, err
+	}
+	paused, err := store.Pause(name)
+	if err != nil {
+		return nil, err
+	}
+	model := toScheduleModel(*paused)
+	return &model, nil
+}
+
+// ResumeSchedule lets the named schedule run again from its next cron time
+func (s *ScheduleService) ResumeSchedule(name string) (*models.Schedule, error) {
+	store, err := s.getStore()
+	if err != nil {
+		return nil, err
+	}
+	resumed, err := store.Resume(name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	model := toScheduleModel(*resumed)
+	return &model, nil
+}
+
+// toScheduleModel converts a stored schedule to its API form
+func toScheduleModel(sc schedule.Schedule) models.Schedule {
+	model := models.Schedule{
+		Name:            sc.Name,
+		Synapse:         sc.Synapse,
+		Path:            sc.Path,
+		Cron:            sc.Cron,
+		Jitter:          sc.Jitter.Seconds(),
+		CatchUp:         string(sc.CatchUp),
+		Paused:          sc.Paused,
+		NextRun:         sc.NextStart(),
+		LastStatus:      sc.LastStatus,
+		LastExecutionID: sc.LastExecutionID,
+		LastError:       sc.LastError,
+		MissedRuns:      sc.MissedRuns,
+	}
+	if !sc.LastRun.IsZero() {
+		lastRun := sc.LastRun
+		model.LastRun = &lastRun
+	}
+	return model
+}
@@ -0,0 +1,68 @@
+package services_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/anoop2811/cortex/internal/schedule"
+	"github.com/anoop2811/cortex/logger"
+	"github.com/anoop2811/cortex/web/server/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ScheduleService", func() {
+	var (
+		service *services.ScheduleService
+		store   *schedule.Store
+		dir     string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cortex-schedule-service-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		store = schedule.NewStore(dir)
+		_, err = store.Add(schedule.Schedule{Name: "disk-check", Synapse: "disk-check", Path: dir, Cron: "*/5 * * * *", Jitter: 30 * time.Second}, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		service = services.NewScheduleService(logger.NewLoggerWithWriter(0, GinkgoWriter))
+		service.SetStore(store)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("lists schedules with the start of their next run", func() {
+		schedules, err := service.ListSchedules()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedules).To(HaveLen(1))
+		Expect(schedules[0].Name).To(Equal("disk-check"))
+		Expect(schedules[0].CatchUp).To(Equal("skip"))
+		Expect(schedules[0].Jitter).To(Equal(30.0))
+		Expect(schedules[0].NextRun).To(BeTemporally("~", time.Now(), 6*time.Minute))
+		Expect(schedules[0].LastRun).To(BeNil())
+	})
+
+	It("pauses and resumes schedules", func() {
+		paused, err := service.PauseSchedule("disk-check")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paused.Paused).To(BeTrue())
+
+		stored, err := store.Get("disk-check")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.Paused).To(BeTrue())
+
+		resumed, err := service.ResumeSchedule("disk-check")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resumed.Paused).To(BeFalse())
+		Expect(resumed.NextRun).To(BeTemporally(">", time.Now()))
+	})
+
+	It("reports unknown schedules", func() {
+		_, err := service.PauseSchedule("missing")
+		Expect(err).To(MatchError(schedule.ErrNotFound))
+	})
+})